@@ -0,0 +1,227 @@
+package config
+
+// AuthConfig 认证模块配置
+type AuthConfig struct {
+	RequireEmailVerified bool `mapstructure:"require_email_verified"` // 邮箱验证后才允许登录
+
+	Lockout        LockoutConfig        `mapstructure:"lockout"`         // 登录失败锁定策略
+	Revocation     RevocationConfig     `mapstructure:"revocation"`      // 访问令牌吊销
+	TwoFA          TwoFAConfig          `mapstructure:"two_fa"`          // 两步验证
+	Passkey        PasskeyConfig        `mapstructure:"passkey"`         // 通行密钥（WebAuthn）
+	EmailCode      EmailCodeConfig      `mapstructure:"email_code"`      // 邮箱验证码
+	SMSCode        SMSCodeConfig        `mapstructure:"sms_code"`        // 短信验证码
+	PasswordPolicy PasswordPolicyConfig `mapstructure:"password_policy"` // 密码策略
+	PasswordReset  PasswordResetConfig  `mapstructure:"password_reset"`  // 找回密码
+	OAuth2         OAuth2Config         `mapstructure:"oauth2"`          // OAuth2 授权服务
+	Federation     FederationConfig     `mapstructure:"federation"`      // 第三方身份提供方登录
+}
+
+// LockoutConfig 登录失败锁定策略，所有时长单位均为秒
+//
+// 账号在 Window 内连续失败 MaxFailures 次后锁定 LockDuration，
+// 之后每次再被锁定时长翻倍（指数退避），最长不超过 MaxLockDuration。
+// 登录成功或管理员解锁后退避重新计算，超过 BackoffReset 没有新的锁定也会重新计算。
+// IP 维度独立统计：单个 IP 在 IPWindow 内失败 IPMaxFailures 次后拒绝该 IP 的登录请求。
+type LockoutConfig struct {
+	Enabled         bool `mapstructure:"enabled"`           // 是否启用
+	MaxFailures     int  `mapstructure:"max_failures"`      // 账号在窗口内允许的最大失败次数
+	Window          int  `mapstructure:"window"`            // 账号失败统计窗口
+	LockDuration    int  `mapstructure:"lock_duration"`     // 首次锁定时长
+	MaxLockDuration int  `mapstructure:"max_lock_duration"` // 最大锁定时长
+	BackoffReset    int  `mapstructure:"backoff_reset"`     // 退避重置时长
+	IPMaxFailures   int  `mapstructure:"ip_max_failures"`   // 单个 IP 在窗口内允许的最大失败次数
+	IPWindow        int  `mapstructure:"ip_window"`         // IP 失败统计窗口
+}
+
+// RevocationConfig 访问令牌吊销（jti 黑名单）配置
+//
+// Store 为 memory 时吊销记录只保存在当前进程内，多实例部署请使用 db。
+type RevocationConfig struct {
+	Store         string `mapstructure:"store"`          // 存储方式：db / memory
+	PurgeInterval int    `mapstructure:"purge_interval"` // 清理过期记录的间隔（秒）
+}
+
+// TwoFAConfig 两步验证（TOTP）配置
+//
+// 启用了 2FA 的用户密码校验通过后只拿到挑战令牌，需在 ChallengeTTL 内提交验证码换取正式令牌，
+// 同一挑战最多验证 ChallengeMaxAttempts 次。
+type TwoFAConfig struct {
+	Issuer               string `mapstructure:"issuer"`                 // 身份验证器 App 中显示的签发者名称
+	ChallengeTTL         int    `mapstructure:"challenge_ttl"`          // 登录挑战有效期（秒）
+	ChallengeMaxAttempts int    `mapstructure:"challenge_max_attempts"` // 登录挑战最大验证次数
+}
+
+// PasskeyConfig 通行密钥（WebAuthn）配置
+//
+// RPID 为依赖方ID，通常是不带协议和端口的域名；RPOrigins 为允许发起认证的前端来源（带协议和端口）。
+type PasskeyConfig struct {
+	RPID          string   `mapstructure:"rp_id"`           // 依赖方ID
+	RPDisplayName string   `mapstructure:"rp_display_name"` // 依赖方显示名称
+	RPOrigins     []string `mapstructure:"rp_origins"`      // 允许的来源
+	Timeout       int      `mapstructure:"timeout"`         // 注册/登录流程的有效期（秒）
+}
+
+// EmailCodeConfig 邮箱验证码配置，所有时长单位均为秒
+type EmailCodeConfig struct {
+	TTL          int `mapstructure:"ttl"`           // 验证码有效期
+	SendInterval int `mapstructure:"send_interval"` // 同一邮箱两次发送的最小间隔
+	HourlyLimit  int `mapstructure:"hourly_limit"`  // 同一邮箱每小时最多发送次数
+	MaxAttempts  int `mapstructure:"max_attempts"`  // 同一验证码最多校验次数，超过后需重新获取
+}
+
+// SMSCodeConfig 短信验证码配置，所有时长单位均为秒
+type SMSCodeConfig struct {
+	TTL          int    `mapstructure:"ttl"`           // 验证码有效期
+	SendInterval int    `mapstructure:"send_interval"` // 同一手机号两次发送的最小间隔
+	DailyLimit   int    `mapstructure:"daily_limit"`   // 同一手机号 24 小时内最多发送次数
+	MaxAttempts  int    `mapstructure:"max_attempts"`  // 同一验证码最多校验次数，超过后需重新获取
+	Template     string `mapstructure:"template"`      // 短信服务商的验证码模板编号，模板参数为 code 和 minutes
+}
+
+// PasswordPolicyConfig 密码策略，注册、修改密码和重置密码时校验
+type PasswordPolicyConfig struct {
+	MinLength     int  `mapstructure:"min_length"`     // 最小长度
+	MaxLength     int  `mapstructure:"max_length"`     // 最大长度（字节），bcrypt 只使用前 72 字节
+	RequireLetter bool `mapstructure:"require_letter"` // 必须包含字母
+	RequireUpper  bool `mapstructure:"require_upper"`  // 必须同时包含大小写字母
+	RequireDigit  bool `mapstructure:"require_digit"`  // 必须包含数字
+	RequireSymbol bool `mapstructure:"require_symbol"` // 必须包含特殊字符
+}
+
+// PasswordResetConfig 找回密码配置，所有时长单位均为秒
+type PasswordResetConfig struct {
+	TokenTTL     int    `mapstructure:"token_ttl"`     // 重置令牌有效期
+	SendInterval int    `mapstructure:"send_interval"` // 同一用户两次发送重置邮件的最小间隔
+	LinkURL      string `mapstructure:"link_url"`      // 前端重置密码页面地址，令牌以 token 查询参数附加
+}
+
+// OAuth2Config OAuth2 授权服务配置，所有时长单位均为秒
+type OAuth2Config struct {
+	ConsentURL  string `mapstructure:"consent_url"`   // 前端授权确认页面，浏览器直接访问授权端点时携带原始参数跳转到此页面
+	CodeTTL     int    `mapstructure:"code_ttl"`      // 授权码有效期
+	Issuer      string `mapstructure:"issuer"`        // OpenID Connect 签发者，即服务对外访问地址，同时作为元数据中各端点地址的前缀
+	IDTokenTTL  int    `mapstructure:"id_token_ttl"`  // id_token 有效期
+	LoginMaxAge int    `mapstructure:"login_max_age"` // prompt=login 时要求用户在该时长内重新登录过
+
+	Scopes []ScopeConfig `mapstructure:"scopes"` // 业务权限范围，OpenID Connect 标准权限范围无需配置
+
+	RedirectSchemes []string `mapstructure:"redirect_schemes"` // 管理员创建的客户端允许使用的自定义回调协议（RFC 8252 7.1），如 com.example.app
+
+	Registration ClientRegistrationConfig `mapstructure:"registration"` // 动态客户端注册
+
+	// 设备授权模式（RFC 8628）
+	DeviceVerificationURL string `mapstructure:"device_verification_url"` // 前端设备验证页面，用户登录后在此输入用户码
+	DeviceCodeTTL         int    `mapstructure:"device_code_ttl"`         // 设备码有效期
+	DeviceInterval        int    `mapstructure:"device_interval"`         // 设备轮询令牌端点的最小间隔
+}
+
+// ScopeConfig 权限范围，客户端只能申请已登记且被授予的权限范围
+type ScopeConfig struct {
+	Name        string `mapstructure:"name"`        // 如 users:read
+	Description string `mapstructure:"description"` // 展示在授权确认页
+}
+
+// ClientRegistrationConfig 动态客户端注册（RFC 7591/7592）
+type ClientRegistrationConfig struct {
+	Enabled             bool     `mapstructure:"enabled"`               // 是否开放注册端点
+	InitialAccessTokens []string `mapstructure:"initial_access_tokens"` // 允许注册的初始访问令牌，以 Bearer 方式携带，为空时拒绝所有注册请求
+	AllowedScopes       []string `mapstructure:"allowed_scopes"`        // 注册的客户端可申请的业务权限范围，OIDC 标准权限范围始终允许
+	AllowedGrantTypes   []string `mapstructure:"allowed_grant_types"`   // 注册的客户端可使用的授权类型，为空时只允许授权码和刷新令牌，密码模式始终不允许
+}
+
+// FederationConfig 第三方身份提供方登录配置
+//
+// 本服务作为 OAuth2 客户端：浏览器访问登录地址后跳转到提供方授权，提供方回调 RedirectURL 指向的前端页面，
+// 前端再将 code 和 state 提交到回调接口，换取与密码登录相同的令牌。
+type FederationConfig struct {
+	StateTTL  int                      `mapstructure:"state_ttl"` // 授权请求有效期（秒），超时后 state 失效
+	Providers []IdentityProviderConfig `mapstructure:"providers"` // 身份提供方，为空时不开放第三方登录
+}
+
+// IdentityProviderConfig 身份提供方配置
+//
+// Type 为 github、google 或 oidc。google 和 oidc 通过 Issuer 自动发现端点并校验 id_token；
+// github 默认使用 github.com 的端点，GitHub Enterprise 可通过 AuthURL、TokenURL、APIURL 覆盖。
+type IdentityProviderConfig struct {
+	Name          string   `mapstructure:"name"`           // 提供方标识，用于登录地址和身份关联记录，如 github
+	Type          string   `mapstructure:"type"`           // 提供方类型：github / google / oidc
+	DisplayName   string   `mapstructure:"display_name"`   // 登录页显示的名称
+	ClientID      string   `mapstructure:"client_id"`      // 在提供方登记的客户端ID
+	ClientSecret  string   `mapstructure:"client_secret"`  // 在提供方登记的客户端密钥
+	RedirectURL   string   `mapstructure:"redirect_url"`   // 在提供方登记的回调地址（前端回调页面）
+	Issuer        string   `mapstructure:"issuer"`         // OIDC 签发者，oidc 类型必填，google 默认 https://accounts.google.com
+	AuthURL       string   `mapstructure:"auth_url"`       // github 类型的授权端点
+	TokenURL      string   `mapstructure:"token_url"`      // github 类型的令牌端点
+	APIURL        string   `mapstructure:"api_url"`        // github 类型的 API 地址
+	Scopes        []string `mapstructure:"scopes"`         // 请求的权限范围，为空时按类型取默认值
+	AutoProvision bool     `mapstructure:"auto_provision"` // 没有关联账号时自动创建用户
+	LinkByEmail   bool     `mapstructure:"link_by_email"`  // 按双方都已验证的邮箱自动关联已有用户
+}
+
+// DefaultAuthConfig 返回默认的认证配置
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		Lockout: LockoutConfig{
+			Enabled:         true,
+			MaxFailures:     5,
+			Window:          15 * 60,
+			LockDuration:    5 * 60,
+			MaxLockDuration: 24 * 60 * 60,
+			BackoffReset:    24 * 60 * 60,
+			IPMaxFailures:   50,
+			IPWindow:        60 * 60,
+		},
+		Revocation: RevocationConfig{
+			Store:         "db",
+			PurgeInterval: 10 * 60,
+		},
+		TwoFA: TwoFAConfig{
+			Issuer:               "King Starter",
+			ChallengeTTL:         5 * 60,
+			ChallengeMaxAttempts: 5,
+		},
+		Passkey: PasskeyConfig{
+			RPID:          "localhost",
+			RPDisplayName: "King Starter",
+			RPOrigins:     []string{"http://localhost:8080"},
+			Timeout:       5 * 60,
+		},
+		EmailCode: EmailCodeConfig{
+			TTL:          10 * 60,
+			SendInterval: 60,
+			HourlyLimit:  5,
+			MaxAttempts:  5,
+		},
+		SMSCode: SMSCodeConfig{
+			TTL:          5 * 60,
+			SendInterval: 60,
+			DailyLimit:   10,
+			MaxAttempts:  5,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:     8,
+			MaxLength:     72,
+			RequireLetter: true,
+			RequireDigit:  true,
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenTTL:     30 * 60,
+			SendInterval: 60,
+			LinkURL:      "http://localhost:8080/reset-password",
+		},
+		OAuth2: OAuth2Config{
+			ConsentURL:  "http://localhost:8080/oauth/consent",
+			CodeTTL:     10 * 60,
+			Issuer:      "http://localhost:8080",
+			IDTokenTTL:  60 * 60,
+			LoginMaxAge: 60,
+
+			DeviceVerificationURL: "http://localhost:8080/oauth/device",
+			DeviceCodeTTL:         10 * 60,
+			DeviceInterval:        5,
+		},
+		Federation: FederationConfig{
+			StateTTL: 10 * 60,
+		},
+	}
+}
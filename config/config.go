@@ -7,6 +7,8 @@ import (
 	"king-starter/pkg/http"
 	"king-starter/pkg/jwt"
 	"king-starter/pkg/logx"
+	"king-starter/pkg/mail"
+	"king-starter/pkg/sms"
 )
 
 type Config struct {
@@ -15,7 +17,10 @@ type Config struct {
 	Database struct {
 		Default *database.DatabaseConfig
 	}
-	Jwt *jwt.JwtConfig
+	Jwt  *jwt.JwtConfig
+	Auth *AuthConfig
+	Mail *mail.MailConfig
+	SMS  *sms.SMSConfig
 }
 
 // DefaultConfig 返回默认的日志配置
@@ -25,10 +30,16 @@ func DefaultConfig() Config {
 	defaultHttpConfig := http.DefaultHttpConfig()
 	defaultDatabaseConfig := database.DefaultDatabaseConfig()
 	defaultJwtConfig := jwt.DefaultJwtConfig()
+	defaultAuthConfig := DefaultAuthConfig()
+	defaultMailConfig := mail.DefaultMailConfig()
+	defaultSMSConfig := sms.DefaultSMSConfig()
 	c.Logger = &defaultLoggerConfig
 	c.Http = &defaultHttpConfig
 	c.Database.Default = &defaultDatabaseConfig
 	c.Jwt = &defaultJwtConfig
+	c.Auth = &defaultAuthConfig
+	c.Mail = &defaultMailConfig
+	c.SMS = &defaultSMSConfig
 	return c
 }
 
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	gohttp "net/http"
 	"time"
@@ -10,6 +11,8 @@ import (
 	"king-starter/pkg/http"
 	"king-starter/pkg/jwt"
 	"king-starter/pkg/logx"
+	"king-starter/pkg/mail"
+	"king-starter/pkg/sms"
 )
 
 // 全局唯一的 App 实例
@@ -26,6 +29,13 @@ type App struct {
 	Jwt *jwt.JWT
 	// Http 服务实例
 	Server *http.Server
+	// 邮件发送实例
+	Mailer mail.Mailer
+	SMS    sms.SMSSender
+
+	// 应用生命周期上下文，Shutdown 时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New 初始化 App 实例
@@ -43,14 +53,27 @@ func New(cfg *config.Config) *App {
 	jwtIns := Must(jwt.NewWithConfig(cfg.Jwt))
 	logx.Info("jwt initialized")
 
+	// 初始化邮件发送
+	mailer := Must(mail.New(cfg.Mail))
+	logx.Info("mailer initialized", "driver", cfg.Mail.Driver)
+
+	// 初始化短信发送
+	smsSender := Must(sms.New(cfg.SMS))
+	logx.Info("sms sender initialized", "driver", cfg.SMS.Driver)
+
 	// 初始化 HTTP 服务
 	server := Must(http.New(cfg.Http))
 
+	ctx, cancel := context.WithCancel(context.Background())
 	globalApp = &App{
 		Config: cfg,
 		Db:     defaultDB,
 		Jwt:    jwtIns,
 		Server: server,
+		Mailer: mailer,
+		SMS:    smsSender,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 	logx.Info("globalApp initialized")
 	return globalApp
@@ -66,11 +89,24 @@ func (c *App) Start() {
 	}
 }
 
+// Context 应用生命周期上下文，Shutdown 时取消，后台任务据此退出
+// 未通过 New 创建的 App（如测试中直接构造）返回永不取消的上下文
+func (c *App) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // Shutdown 资源清理
 func (c *App) Shutdown() {
 	if c == nil {
 		return
 	}
+	// 通知后台任务退出
+	if c.cancel != nil {
+		c.cancel()
+	}
 	time.Sleep(3 * time.Second)
 	// 关闭数据库连接
 	c.Db.Close()
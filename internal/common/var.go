@@ -4,3 +4,8 @@ const (
 	UserIDKey   string = "userId"
 	UsernameKey string = "username"
 )
+
+const (
+	// AdminRoleCode 管理员角色编码，拥有该角色的用户可以访问管理类接口
+	AdminRoleCode string = "admin"
+)
@@ -1,10 +1,16 @@
 package auth_2fa
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
 	"net/http"
 
 	"king-starter/internal/response"
 	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/echoutil"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -12,211 +18,258 @@ import (
 	"gorm.io/gorm"
 )
 
+// qrCodeSize 二维码图片边长（像素）
+const qrCodeSize = 256
+
 // TwoFAHandler 2FA 认证处理器
 type TwoFAHandler struct {
-	repo *Repository
+	repo     *Repository
+	userRepo *user.Repository
+	verifier *Verifier
+	issuer   string
 }
 
 // NewTwoFAHandler 创建 2FA 认证处理器实例
-func NewTwoFAHandler(repo *Repository) *TwoFAHandler {
+func NewTwoFAHandler(repo *Repository, userRepo *user.Repository, issuer string) *TwoFAHandler {
 	return &TwoFAHandler{
-		repo: repo,
+		repo:     repo,
+		userRepo: userRepo,
+		verifier: NewVerifier(repo),
+		issuer:   issuer,
 	}
 }
 
-// EnableTwoFA 启用 2FA
-func (h *TwoFAHandler) EnableTwoFA(c echo.Context) error {
-	var req EnableTwoFAReq
-	if err := c.Bind(&req); err != nil {
-		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+// BeginEnrollment 开始绑定 2FA
+// 生成新的 TOTP 密钥（未启用状态），返回 otpauth:// URI 和二维码，用户使用身份验证器扫码后调用 ConfirmEnrollment 完成绑定
+func (h *TwoFAHandler) BeginEnrollment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByID(ctx, echoutil.GetUserID(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
 	}
 
-	// 检查用户是否已有 2FA 配置
-	existingTwoFA, err := h.repo.GetTwoFAByUserID(c.Request().Context(), req.UserID)
-	if err != nil && err != gorm.ErrRecordNotFound {
+	existingTwoFA, err := h.repo.GetTwoFAByUserID(ctx, u.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return response.Error(c, http.StatusInternalServerError, "查询 2FA 配置失败")
 	}
-
-	var secret string
-	if existingTwoFA == nil {
-		// 生成新的 TOTP 密钥
-		key, err := totp.Generate(totp.GenerateOpts{
-			Issuer:      "King Starter",
-			AccountName: "user@example.com", // 实际应该使用用户邮箱
-		})
-		if err != nil {
-			return response.Error(c, http.StatusInternalServerError, "生成密钥失败")
-		}
-		secret = key.Secret()
-	} else {
-		secret = existingTwoFA.Secret
+	if existingTwoFA != nil && existingTwoFA.Status == 1 {
+		return response.Error(c, http.StatusBadRequest, "2FA 已启用")
 	}
 
-	// 验证 TOTP 码
-	valid := totp.Validate(req.Code, secret)
+	// 身份验证器中显示的账号，优先使用邮箱
+	accountName := u.Email
+	if accountName == "" {
+		accountName = u.Username
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      h.issuer,
+		AccountName: accountName,
+	})
 	if err != nil {
-		return response.Error(c, http.StatusInternalServerError, "验证失败")
+		return response.Error(c, http.StatusInternalServerError, "生成密钥失败")
 	}
-	if !valid {
-		// 记录验证失败日志
-		log := &auth_password.CoreLoginLog{
-			ID:        uuid.New().String(),
-			UserID:    req.UserID,
-			Username:  "", // 暂时为空，后续可以优化
-			AuthType:  AuthType2FA,
-			LoginType: LoginTypeFailed,
-			IP:        c.RealIP(),
-			UserAgent: c.Request().UserAgent(),
-			Message:   "验证码错误",
-		}
-		h.repo.CreateLoginLog(c.Request().Context(), log)
 
-		return response.Error(c, http.StatusBadRequest, "验证码错误")
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成二维码失败")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成二维码失败")
 	}
 
-	// 保存 2FA 配置
+	// 保存待确认的密钥，重复发起绑定时覆盖旧密钥
 	if existingTwoFA == nil {
 		twoFA := &TwoFAConfig{
 			ID:     uuid.New().String(),
-			UserID: req.UserID,
-			Secret: secret,
-			Status: 1,
+			UserID: u.ID,
+			Secret: key.Secret(),
+			Status: 0,
 		}
-		if err := h.repo.CreateTwoFA(c.Request().Context(), twoFA); err != nil {
+		if err := h.repo.CreateTwoFA(ctx, twoFA); err != nil {
 			return response.Error(c, http.StatusInternalServerError, "保存 2FA 配置失败")
 		}
 	} else {
-		existingTwoFA.Status = 1
-		if err := h.repo.UpdateTwoFA(c.Request().Context(), existingTwoFA); err != nil {
+		existingTwoFA.Secret = key.Secret()
+		existingTwoFA.LastUsedStep = 0 // 新密钥的验证码尚未使用过
+		if err := h.repo.UpdateTwoFA(ctx, existingTwoFA); err != nil {
 			return response.Error(c, http.StatusInternalServerError, "更新 2FA 配置失败")
 		}
 	}
 
-	// 记录验证成功日志
-	log := &auth_password.CoreLoginLog{
-		ID:        uuid.New().String(),
-		UserID:    req.UserID,
-		Username:  "", // 暂时为空，后续可以优化
-		AuthType:  AuthType2FA,
-		LoginType: LoginTypeSuccess,
-		IP:        c.RealIP(),
-		UserAgent: c.Request().UserAgent(),
-		Message:   "启用 2FA 成功",
-	}
-	h.repo.CreateLoginLog(c.Request().Context(), log)
-
-	return response.SuccessWithMsg[any](c, "启用 2FA 成功", nil)
+	return response.Success[any](c, map[string]interface{}{
+		"otpauth_url": key.URL(),
+		"secret":      key.Secret(), // 无法扫码时手动输入
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
 }
 
-// VerifyTwoFA 验证 2FA
-func (h *TwoFAHandler) VerifyTwoFA(c echo.Context) error {
-	var req VerifyTwoFAReq
+// ConfirmEnrollment 确认绑定 2FA，验证码正确后启用
+func (h *TwoFAHandler) ConfirmEnrollment(c echo.Context) error {
+	var req ConfirmEnrollmentReq
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
 
-	// 获取用户 2FA 配置
-	twoFA, err := h.repo.GetTwoFAByUserID(c.Request().Context(), req.UserID)
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	twoFA, err := h.repo.GetTwoFAByUserID(ctx, userID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusNotFound, "2FA 配置不存在")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusBadRequest, "请先发起 2FA 绑定")
 		}
 		return response.Error(c, http.StatusInternalServerError, "查询 2FA 配置失败")
 	}
-
-	// 检查 2FA 是否已启用
-	if twoFA.Status != 1 {
-		return response.Error(c, http.StatusBadRequest, "2FA 未启用")
+	if twoFA.Status == 1 {
+		return response.Error(c, http.StatusBadRequest, "2FA 已启用")
 	}
 
 	// 验证 TOTP 码
-	valid := totp.Validate(req.Code, twoFA.Secret)
+	valid, err := h.verifier.validateTOTP(ctx, twoFA, req.Code)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "验证失败")
+	}
 	if !valid {
-		// 记录验证失败日志
-		log := &auth_password.CoreLoginLog{
-			ID:        uuid.New().String(),
-			UserID:    req.UserID,
-			Username:  "", // 暂时为空，后续可以优化
-			AuthType:  AuthType2FA,
-			LoginType: LoginTypeFailed,
-			IP:        c.RealIP(),
-			UserAgent: c.Request().UserAgent(),
-			Message:   "验证码错误",
-		}
-		h.repo.CreateLoginLog(c.Request().Context(), log)
+		h.createLoginLog(c, userID, AuthType2FA, auth_password.LoginTypeFailed, "启用 2FA 验证码错误")
+		return response.Error(c, http.StatusBadRequest, "验证码错误")
+	}
+
+	// 同时生成恢复码，明文只在此时返回一次
+	records, recoveryCodes := newRecoveryCodes(userID)
+	if err := h.repo.ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成恢复码失败")
+	}
+
+	twoFA.Status = 1
+	if err := h.repo.UpdateTwoFA(ctx, twoFA); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新 2FA 配置失败")
+	}
 
+	h.createLoginLog(c, userID, AuthType2FA, auth_password.LoginTypeSuccess, "启用 2FA 成功")
+
+	return response.SuccessWithMsg[any](c, "启用 2FA 成功，请妥善保存恢复码", map[string]interface{}{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// GetRecoveryCodes 查询当前用户剩余可用的恢复码数量
+func (h *TwoFAHandler) GetRecoveryCodes(c echo.Context) error {
+	count, err := h.repo.CountUnusedRecoveryCodes(c.Request().Context(), echoutil.GetUserID(c))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询恢复码失败")
+	}
+	return response.Success[any](c, map[string]interface{}{
+		"remaining": count,
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，原有恢复码全部作废
+func (h *TwoFAHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	var req RegenerateRecoveryCodesReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	twoFA, err := h.repo.GetTwoFAByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询 2FA 配置失败")
+	}
+	if twoFA == nil || twoFA.Status != 1 {
+		return response.Error(c, http.StatusBadRequest, "2FA 未启用")
+	}
+
+	// 需要身份验证器中的验证码，防止令牌泄露后恢复码被重置
+	valid, err := h.verifier.validateTOTP(ctx, twoFA, req.Code)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "验证失败")
+	}
+	if !valid {
+		h.createLoginLog(c, userID, AuthType2FA, auth_password.LoginTypeFailed, "重新生成恢复码验证码错误")
 		return response.Error(c, http.StatusBadRequest, "验证码错误")
 	}
 
-	// 记录验证成功日志
-	log := &auth_password.CoreLoginLog{
-		ID:        uuid.New().String(),
-		UserID:    req.UserID,
-		Username:  "", // 暂时为空，后续可以优化
-		AuthType:  AuthType2FA,
-		LoginType: LoginTypeSuccess,
-		IP:        c.RealIP(),
-		UserAgent: c.Request().UserAgent(),
-		Message:   "验证成功",
+	records, recoveryCodes := newRecoveryCodes(userID)
+	if err := h.repo.ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成恢复码失败")
 	}
-	h.repo.CreateLoginLog(c.Request().Context(), log)
 
-	return response.SuccessWithMsg[any](c, "验证成功", nil)
+	return response.SuccessWithMsg[any](c, "恢复码已重新生成，原恢复码已失效", map[string]interface{}{
+		"recovery_codes": recoveryCodes,
+	})
 }
 
-// DisableTwoFA 禁用 2FA
+// DisableTwoFA 禁用当前用户的 2FA
 func (h *TwoFAHandler) DisableTwoFA(c echo.Context) error {
 	var req DisableTwoFAReq
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
 
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
 	// 获取用户 2FA 配置
-	twoFA, err := h.repo.GetTwoFAByUserID(c.Request().Context(), req.UserID)
+	twoFA, err := h.repo.GetTwoFAByUserID(ctx, userID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return response.Error(c, http.StatusNotFound, "2FA 配置不存在")
 		}
 		return response.Error(c, http.StatusInternalServerError, "查询 2FA 配置失败")
 	}
+	if twoFA.Status != 1 {
+		return response.Error(c, http.StatusBadRequest, "2FA 未启用")
+	}
 
-	// 验证 TOTP 码
-	valid := totp.Validate(req.Code, twoFA.Secret)
+	// 验证 TOTP 码或恢复码
+	authType, valid, err := h.verifier.Verify(ctx, userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "验证失败")
+	}
 	if !valid {
 		// 记录验证失败日志
-		log := &auth_password.CoreLoginLog{
-			ID:        uuid.New().String(),
-			UserID:    req.UserID,
-			Username:  "", // 暂时为空，后续可以优化
-			AuthType:  AuthType2FA,
-			LoginType: LoginTypeFailed,
-			IP:        c.RealIP(),
-			UserAgent: c.Request().UserAgent(),
-			Message:   "验证码错误",
-		}
-		h.repo.CreateLoginLog(c.Request().Context(), log)
-
+		h.createLoginLog(c, userID, authType, auth_password.LoginTypeFailed, "禁用 2FA 验证码错误")
 		return response.Error(c, http.StatusBadRequest, "验证码错误")
 	}
 
-	// 禁用 2FA
+	// 禁用 2FA，剩余的恢复码一并作废
 	twoFA.Status = 0
-	if err := h.repo.UpdateTwoFA(c.Request().Context(), twoFA); err != nil {
+	if err := h.repo.UpdateTwoFA(ctx, twoFA); err != nil {
 		return response.Error(c, http.StatusInternalServerError, "更新 2FA 配置失败")
 	}
+	if err := h.repo.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "清除恢复码失败")
+	}
 
 	// 记录验证成功日志
+	h.createLoginLog(c, userID, authType, auth_password.LoginTypeSuccess, "禁用 2FA 成功")
+
+	return response.SuccessWithMsg[any](c, "禁用 2FA 成功", nil)
+}
+
+// createLoginLog 记录 2FA 相关的登录日志
+func (h *TwoFAHandler) createLoginLog(c echo.Context, userID, authType, loginType, message string) {
+	ctx := c.Request().Context()
+	var username string
+	if u, err := h.userRepo.GetByID(ctx, userID); err == nil {
+		username = u.Username
+	}
 	log := &auth_password.CoreLoginLog{
 		ID:        uuid.New().String(),
-		UserID:    req.UserID,
-		Username:  "", // 暂时为空，后续可以优化
-		AuthType:  AuthType2FA,
-		LoginType: LoginTypeSuccess,
+		UserID:    userID,
+		Username:  username,
+		AuthType:  authType,
+		LoginType: loginType,
 		IP:        c.RealIP(),
 		UserAgent: c.Request().UserAgent(),
-		Message:   "禁用 2FA 成功",
+		Message:   message,
 	}
-	h.repo.CreateLoginLog(c.Request().Context(), log)
-
-	return response.SuccessWithMsg[any](c, "禁用 2FA 成功", nil)
+	h.repo.CreateLoginLog(ctx, log)
 }
@@ -0,0 +1,219 @@
+package auth_2fa
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	repo    *Repository
+	handler *TwoFAHandler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&auth_password.CoreLoginLog{}, &auth_password.CoreRefreshToken{}, &auth_password.CoreLoginChallenge{},
+		&TwoFAConfig{}, &CoreRecoveryCode{},
+	)
+	hash, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Password: string(hash), Email: "alice@example.com", Phone: "13800000001", Status: 1}).Error)
+
+	repo := NewRepository(db)
+	return &testEnv{db: db, repo: repo, handler: NewTwoFAHandler(repo, user.NewRepository(db), "King Starter")}
+}
+
+// code 生成当前有效的 TOTP 验证码
+func code(t *testing.T, secret string) string {
+	c, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return c
+}
+
+// nextCode 生成下一个时间步的验证码，同一时间步的验证码只能使用一次
+func nextCode(t *testing.T, secret string) string {
+	c, err := totp.GenerateCode(secret, time.Now().Add(totpPeriod*time.Second))
+	require.NoError(t, err)
+	return c
+}
+
+// wrongCode 生成一个与当前验证码不同的六位数字
+func wrongCode(t *testing.T, secret string) string {
+	c := []byte(code(t, secret))
+	c[0] = '0' + (c[0]-'0'+5)%10
+	return string(c)
+}
+
+func recoveryCodes(resp testutil.Response) []string {
+	var codes []string
+	for _, c := range resp.Data.(map[string]interface{})["recovery_codes"].([]interface{}) {
+		codes = append(codes, c.(string))
+	}
+	return codes
+}
+
+// TestEnrollment 发起绑定返回 otpauth URI 和二维码，验证码正确后才启用
+func TestEnrollment(t *testing.T) {
+	env := newTestEnv(t)
+	verifier := NewVerifier(env.repo)
+	ctx := context.Background()
+
+	resp := testutil.Call(t, env.handler.BeginEnrollment, "u1", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.True(t, strings.HasPrefix(data["otpauth_url"].(string), "otpauth://totp/King%20Starter:alice@example.com?"))
+	assert.True(t, strings.HasPrefix(data["qr_code"].(string), "data:image/png;base64,"))
+	secret := data["secret"].(string)
+
+	// 未确认前不启用
+	enabled, err := verifier.Enabled(ctx, "u1")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.ConfirmEnrollment, "u1", map[string]string{"code": wrongCode(t, secret)}).Code)
+
+	// 重新发起绑定会替换密钥，旧密钥生成的验证码失效
+	resp = testutil.Call(t, env.handler.BeginEnrollment, "u1", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	newSecret := resp.Data.(map[string]interface{})["secret"].(string)
+	require.NotEqual(t, secret, newSecret)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.ConfirmEnrollment, "u1", map[string]string{"code": wrongCode(t, newSecret)}).Code)
+
+	resp = testutil.Call(t, env.handler.ConfirmEnrollment, "u1", map[string]string{"code": code(t, newSecret)})
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Len(t, recoveryCodes(resp), recoveryCodeCount)
+
+	enabled, err = verifier.Enabled(ctx, "u1")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	// 已启用时不能重复绑定
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.BeginEnrollment, "u1", nil).Code)
+}
+
+// enroll 完成 2FA 绑定，返回密钥和恢复码
+func (env *testEnv) enroll(t *testing.T) (string, []string) {
+	resp := testutil.Call(t, env.handler.BeginEnrollment, "u1", nil)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	secret := resp.Data.(map[string]interface{})["secret"].(string)
+
+	resp = testutil.Call(t, env.handler.ConfirmEnrollment, "u1", map[string]string{"code": code(t, secret)})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	return secret, recoveryCodes(resp)
+}
+
+// TestRecoveryCodes 恢复码只能使用一次，重新生成后原恢复码作废
+func TestRecoveryCodes(t *testing.T) {
+	env := newTestEnv(t)
+	verifier := NewVerifier(env.repo)
+	ctx := context.Background()
+	secret, codes := env.enroll(t)
+
+	// 输入时忽略大小写、空格和连字符
+	authType, ok, err := verifier.Verify(ctx, "u1", "", " "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" ")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, AuthTypeRecovery, authType)
+
+	_, ok, err = verifier.Verify(ctx, "u1", "", codes[0])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	resp := testutil.Call(t, env.handler.GetRecoveryCodes, "u1", nil)
+	assert.EqualValues(t, recoveryCodeCount-1, resp.Data.(map[string]interface{})["remaining"])
+
+	// 重新生成需要身份验证器中的验证码
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.RegenerateRecoveryCodes, "u1", map[string]string{"code": wrongCode(t, secret)}).Code)
+	resp = testutil.Call(t, env.handler.RegenerateRecoveryCodes, "u1", map[string]string{"code": nextCode(t, secret)})
+	require.Equal(t, http.StatusOK, resp.Code)
+	newCodes := recoveryCodes(resp)
+	assert.Len(t, newCodes, recoveryCodeCount)
+
+	_, ok, err = verifier.Verify(ctx, "u1", "", codes[1])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 使用恢复码禁用 2FA，剩余恢复码一并作废
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.DisableTwoFA, "u1", map[string]string{"recovery_code": codes[2]}).Code)
+	require.Equal(t, http.StatusOK, testutil.Call(t, env.handler.DisableTwoFA, "u1", map[string]string{"recovery_code": newCodes[0]}).Code)
+	enabled, err := verifier.Enabled(ctx, "u1")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	count, err := env.repo.CountUnusedRecoveryCodes(ctx, "u1")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+// TestPasswordLoginWithTwoFA 启用 2FA 后密码登录需要通过 /login/2fa 提交验证码或恢复码
+func TestPasswordLoginWithTwoFA(t *testing.T) {
+	env := newTestEnv(t)
+	secret, codes := env.enroll(t)
+
+	cfg := config.DefaultAuthConfig()
+	passwordRepo := auth_password.NewRepository(env.db)
+	login := auth_password.NewLoginHandler(passwordRepo, user.NewRepository(env.db), role.NewRoleRepo(env.db), testutil.NewJWT(),
+		auth_password.NewLockout(passwordRepo, cfg.Lockout), auth_password.NewPasswordPolicy(cfg.PasswordPolicy), false, NewVerifier(env.repo), cfg.TwoFA)
+
+	challenge := func() string {
+		resp := testutil.Call(t, login.Login, "", map[string]string{"username": "alice", "password": "Passw0rd!"})
+		require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+		data := resp.Data.(map[string]interface{})
+		require.Nil(t, data["access_token"])
+		return data["challenge_token"].(string)
+	}
+
+	challengeToken := challenge()
+	assert.Equal(t, http.StatusUnauthorized, testutil.Call(t, login.LoginTwoFA, "", map[string]string{"challenge_token": challengeToken, "code": wrongCode(t, secret)}).Code)
+	resp := testutil.Call(t, login.LoginTwoFA, "", map[string]string{"challenge_token": challengeToken, "code": nextCode(t, secret)})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	assert.NotEmpty(t, resp.Data.(map[string]interface{})["access_token"])
+
+	resp = testutil.Call(t, login.LoginTwoFA, "", map[string]string{"challenge_token": challenge(), "recovery_code": codes[0]})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	// 已使用的恢复码不能再次登录
+	assert.Equal(t, http.StatusUnauthorized, testutil.Call(t, login.LoginTwoFA, "", map[string]string{"challenge_token": challenge(), "recovery_code": codes[0]}).Code)
+}
+
+// TestTOTPReplay 同一时间步的验证码只能使用一次，登录、重新生成恢复码和禁用 2FA 共用
+func TestTOTPReplay(t *testing.T) {
+	env := newTestEnv(t)
+	secret, _ := env.enroll(t)
+	ctx := context.Background()
+	verifier := NewVerifier(env.repo)
+
+	// 绑定时使用过的验证码
+	_, ok, err := verifier.Verify(ctx, "u1", code(t, secret), "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	next := nextCode(t, secret)
+	require.Equal(t, http.StatusOK, testutil.Call(t, env.handler.RegenerateRecoveryCodes, "u1", map[string]string{"code": next}).Code)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.RegenerateRecoveryCodes, "u1", map[string]string{"code": next}).Code)
+	_, ok, err = verifier.Verify(ctx, "u1", next, "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.DisableTwoFA, "u1", map[string]string{"code": next}).Code)
+
+	// 早于最近一次使用的时间步的验证码同样拒绝
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.DisableTwoFA, "u1", map[string]string{"code": code(t, secret)}).Code)
+}
@@ -6,15 +6,30 @@ import (
 
 // TwoFAConfig 2FA 配置模型
 type TwoFAConfig struct {
-	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	UserID    string    `gorm:"type:varchar(36);uniqueIndex" json:"user_id"`
-	Secret    string    `gorm:"type:varchar(255)" json:"secret"`
-	Status    int       `gorm:"type:tinyint;default:0" json:"status"` // 0: 禁用, 1: 启用
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID           string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID       string    `gorm:"type:varchar(36);uniqueIndex" json:"user_id"`
+	Secret       string    `gorm:"type:varchar(255)" json:"-"`           // TOTP 密钥
+	Status       int       `gorm:"type:tinyint;default:0" json:"status"` // 0: 禁用/待确认, 1: 启用
+	LastUsedStep int64     `gorm:"default:0" json:"-"`                   // 最近一次使用的 TOTP 时间步，不晚于它的验证码不再接受，防止重放
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
 func (TwoFAConfig) TableName() string {
 	return "core_user_twofa"
 }
+
+// CoreRecoveryCode 2FA 恢复码模型，每个恢复码只能使用一次
+type CoreRecoveryCode struct {
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string     `gorm:"type:varchar(36);index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);uniqueIndex" json:"-"` // 恢复码的 SHA-256 哈希
+	UsedAt    *time.Time `json:"used_at,omitempty"`                     // 使用时间
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CoreRecoveryCode) TableName() string {
+	return "core_user_twofa_recovery_codes"
+}
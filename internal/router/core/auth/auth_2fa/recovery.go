@@ -0,0 +1,39 @@
+package auth_2fa
+
+import (
+	"strings"
+
+	"king-starter/pkg/goutils/cryptoutil"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet 恢复码字符集，去掉了容易混淆的 0/1/i/l/o
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+)
+
+// newRecoveryCodes 生成一批恢复码，返回待保存的记录和展示给用户的明文（xxxxx-xxxxx）
+func newRecoveryCodes(userID string) ([]CoreRecoveryCode, []string) {
+	records := make([]CoreRecoveryCode, 0, recoveryCodeCount)
+	plains := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code := cryptoutil.RandomString(recoveryCodeAlphabet, 10)
+		plains = append(plains, code[:5]+"-"+code[5:])
+		records = append(records, CoreRecoveryCode{
+			ID:       uuid.New().String(),
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+	return records, plains
+}
+
+// hashRecoveryCode 规范化后计算恢复码哈希，输入时忽略大小写、空格和连字符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return cryptoutil.SHA256Hex(code)
+}
@@ -2,6 +2,7 @@ package auth_2fa
 
 import (
 	"context"
+	"time"
 
 	"king-starter/internal/router/core/auth/auth_password"
 
@@ -38,7 +39,45 @@ func (r *Repository) UpdateTwoFA(ctx context.Context, config *TwoFAConfig) error
 	return r.db.WithContext(ctx).Save(config).Error
 }
 
+// UseTOTPStep 记录已使用的 TOTP 时间步，时间步不晚于上次使用的时间步时返回 false（验证码被重放）
+func (r *Repository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&TwoFAConfig{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
 // CreateLoginLog 创建登录日志
 func (r *Repository) CreateLoginLog(ctx context.Context, log *auth_password.CoreLoginLog) error {
 	return r.db.WithContext(ctx).Create(log).Error
 }
+
+// ReplaceRecoveryCodes 删除用户原有的恢复码并保存新的恢复码
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []CoreRecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&CoreRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode 使用恢复码，返回是否使用成功（恢复码不存在或已使用时返回 false）
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&CoreRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// CountUnusedRecoveryCodes 统计用户未使用的恢复码数量
+func (r *Repository) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&CoreRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -1,19 +1,17 @@
 package auth_2fa
 
-// EnableTwoFAReq 启用 2FA 请求参数
-type EnableTwoFAReq struct {
-	UserID string `json:"user_id" validate:"required"`
-	Code   string `json:"code" validate:"required,len=6"`
+// ConfirmEnrollmentReq 确认绑定 2FA 请求参数
+type ConfirmEnrollmentReq struct {
+	Code string `json:"code" validate:"required,len=6"`
 }
 
-// VerifyTwoFAReq 验证 2FA 请求参数
-type VerifyTwoFAReq struct {
-	UserID string `json:"user_id" validate:"required"`
-	Code   string `json:"code" validate:"required,len=6"`
+// DisableTwoFAReq 禁用 2FA 请求参数，Code 和 RecoveryCode 二选一
+type DisableTwoFAReq struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
 }
 
-// DisableTwoFAReq 禁用 2FA 请求参数
-type DisableTwoFAReq struct {
-	UserID string `json:"user_id" validate:"required"`
-	Code   string `json:"code" validate:"required,len=6"`
+// RegenerateRecoveryCodesReq 重新生成恢复码请求参数
+type RegenerateRecoveryCodesReq struct {
+	Code string `json:"code" validate:"required,len=6"`
 }
@@ -2,20 +2,31 @@ package auth_2fa
 
 import (
 	"king-starter/internal/app"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
 )
 
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&TwoFAConfig{},
+		&CoreRecoveryCode{},
+	)
+}
+
 // RegisterRoutes 注册 2FA 认证路由
 func RegisterRoutes(app *app.App) {
 	repo := NewRepository(app.Db.DB)
-	handler := NewTwoFAHandler(repo)
+	handler := NewTwoFAHandler(repo, user.NewRepository(app.Db.DB), app.Config.Auth.TwoFA.Issuer)
 
 	e := app.Server.Engine()
 
-	// 2FA 认证路由组
-	authGroup := e.Group("/api/core/auth")
+	// 当前用户管理自己的 2FA
+	twoFAGroup := e.Group("/api/core/auth/2fa", middleware.JWTAuthMiddleware(app.Jwt))
 	{
-		authGroup.POST("/2fa/verify", handler.VerifyTwoFA)   // 2FA验证
-		authGroup.POST("/2fa/enable", handler.EnableTwoFA)   // 启用2FA
-		authGroup.POST("/2fa/disable", handler.DisableTwoFA) // 禁用2FA
+		twoFAGroup.POST("/enroll", handler.BeginEnrollment)                 // 开始绑定，返回二维码
+		twoFAGroup.POST("/enroll/confirm", handler.ConfirmEnrollment)       // 确认绑定并启用
+		twoFAGroup.POST("/disable", handler.DisableTwoFA)                   // 禁用2FA
+		twoFAGroup.GET("/recovery-codes", handler.GetRecoveryCodes)         // 剩余恢复码数量
+		twoFAGroup.POST("/recovery-codes", handler.RegenerateRecoveryCodes) // 重新生成恢复码
 	}
-}
\ No newline at end of file
+}
@@ -5,6 +5,7 @@ const (
 	AuthTypeEmail    string = "email"    // 邮箱认证
 	AuthTypePhone    string = "phone"    // 手机认证
 	AuthType2FA      string = "2fa"      // 两步验证
+	AuthTypeRecovery string = "recovery" // 2FA 恢复码
 	AuthTypeOAuth2   string = "oauth2"   // OAuth2 认证
 	AuthTypeSSO      string = "sso"      // 单点登录
 )
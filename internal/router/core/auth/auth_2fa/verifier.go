@@ -0,0 +1,94 @@
+package auth_2fa
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+// totpPeriod TOTP 时间步长（秒），与 totp.Generate 的默认值一致
+const totpPeriod = 30
+
+// Verifier 2FA 校验，作为第二因素挂载到密码登录流程
+type Verifier struct {
+	repo *Repository
+}
+
+var _ auth_password.SecondFactor = (*Verifier)(nil)
+
+// NewVerifier 创建 2FA 校验实例
+func NewVerifier(repo *Repository) *Verifier {
+	return &Verifier{repo: repo}
+}
+
+// Enabled 用户是否已启用 2FA
+func (v *Verifier) Enabled(ctx context.Context, userID string) (bool, error) {
+	twoFA, err := v.repo.GetTwoFAByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return twoFA.Status == 1, nil
+}
+
+// Verify 校验 TOTP 验证码或恢复码，返回实际使用的认证类型
+// 恢复码校验成功即被消耗，不能重复使用
+func (v *Verifier) Verify(ctx context.Context, userID, code, recoveryCode string) (string, bool, error) {
+	if recoveryCode != "" {
+		used, err := v.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode))
+		return AuthTypeRecovery, used, err
+	}
+
+	twoFA, err := v.repo.GetTwoFAByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return AuthType2FA, false, nil
+		}
+		return AuthType2FA, false, err
+	}
+	if twoFA.Status != 1 {
+		return AuthType2FA, false, nil
+	}
+	valid, err := v.validateTOTP(ctx, twoFA, code)
+	return AuthType2FA, valid, err
+}
+
+// validateTOTP 校验 TOTP 验证码，每个时间步的验证码只能使用一次
+// 校验成功后同时更新 twoFA.LastUsedStep，调用方随后保存 twoFA 时不会覆盖为旧值
+func (v *Verifier) validateTOTP(ctx context.Context, twoFA *TwoFAConfig, code string) (bool, error) {
+	step, ok := matchTOTP(code, twoFA.Secret, time.Now())
+	if !ok || step <= twoFA.LastUsedStep {
+		return false, nil
+	}
+	used, err := v.repo.UseTOTPStep(ctx, twoFA.UserID, step)
+	if err != nil || !used {
+		return false, err
+	}
+	twoFA.LastUsedStep = step
+	return true, nil
+}
+
+// matchTOTP 校验验证码，与 totp.Validate 一样允许前后各一个时间步的时钟偏差，返回验证码所在的时间步
+func matchTOTP(code, secret string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for _, step := range []int64{current - 1, current, current + 1} {
+		expected, err := totp.GenerateCode(secret, time.Unix(step*totpPeriod, 0))
+		if err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package auth_code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"king-starter/pkg/goutils/cryptoutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCodeInvalid     = errors.New("verify code invalid")
+	ErrCodeExpired     = errors.New("verify code expired")
+	ErrTooManyAttempts = errors.New("verify code attempts exceeded")
+)
+
+// RateLimitError 发送过于频繁
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("verify code rate limited, retry after %s", e.RetryAfter)
+}
+
+// Deliverer 将验证码发送给接收方，按用途决定消息内容
+type Deliverer interface {
+	Deliver(ctx context.Context, target, purpose, code string, ttl time.Duration) error
+}
+
+// Limits 验证码有效期和发送频率限制
+type Limits struct {
+	TTL          time.Duration // 验证码有效期
+	SendInterval time.Duration // 同一接收方同一用途两次发送的最小间隔
+	SendWindow   time.Duration // 发送次数统计窗口，如 1 小时或 24 小时
+	SendLimit    int           // 窗口内同一接收方最多发送次数（不区分用途），0 表示不限制
+	MaxAttempts  int           // 同一验证码最多校验次数，超过后需重新获取
+}
+
+// Manager 生成、发送并校验一次性验证码
+// 验证码为 6 位数字，只保存哈希；同一接收方同一用途只有最近发送的验证码有效
+type Manager struct {
+	repo      *Repository
+	channel   string
+	limits    Limits
+	deliverer Deliverer
+}
+
+// NewManager 创建验证码管理器，每个发送渠道一个实例
+func NewManager(repo *Repository, channel string, limits Limits, deliverer Deliverer) *Manager {
+	return &Manager{repo: repo, channel: channel, limits: limits, deliverer: deliverer}
+}
+
+// Send 生成验证码并发送，超过发送频率限制时返回 *RateLimitError
+func (m *Manager) Send(ctx context.Context, target, purpose, ip string) error {
+	if err := m.checkRate(ctx, target, purpose); err != nil {
+		return err
+	}
+
+	plain := cryptoutil.RandomString("0123456789", 6)
+	code := &CoreVerifyCode{
+		ID:        uuid.New().String(),
+		Channel:   m.channel,
+		Target:    target,
+		Purpose:   purpose,
+		CodeHash:  cryptoutil.SHA256Hex(m.subject(target, purpose, plain)),
+		ExpiresAt: time.Now().Add(m.limits.TTL),
+		IP:        ip,
+	}
+	// 发送记录需保留到统计窗口结束
+	retention := max(m.limits.SendWindow, m.limits.TTL, 24*time.Hour)
+	if err := m.repo.CreateCode(ctx, code, retention); err != nil {
+		return err
+	}
+
+	if err := m.deliverer.Deliver(ctx, target, purpose, plain, m.limits.TTL); err != nil {
+		// 发送失败不占用发送次数
+		m.repo.DeleteCode(ctx, code.ID)
+		return err
+	}
+	return nil
+}
+
+// checkRate 检查发送间隔和窗口内发送次数
+func (m *Manager) checkRate(ctx context.Context, target, purpose string) error {
+	latest, err := m.repo.GetLatestCode(ctx, m.channel, target, purpose)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if latest != nil {
+		if wait := time.Until(latest.CreatedAt.Add(m.limits.SendInterval)); wait > 0 {
+			return &RateLimitError{RetryAfter: wait}
+		}
+	}
+
+	if m.limits.SendLimit <= 0 {
+		return nil
+	}
+	count, err := m.repo.CountCodesSince(ctx, m.channel, target, time.Now().Add(-m.limits.SendWindow))
+	if err != nil {
+		return err
+	}
+	if count >= int64(m.limits.SendLimit) {
+		return &RateLimitError{RetryAfter: m.limits.SendWindow}
+	}
+	return nil
+}
+
+// Verify 校验验证码，成功后验证码失效
+func (m *Manager) Verify(ctx context.Context, target, purpose, plain string) error {
+	code, err := m.repo.GetLatestCode(ctx, m.channel, target, purpose)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCodeInvalid
+		}
+		return err
+	}
+	if code.UsedAt != nil {
+		return ErrCodeInvalid
+	}
+	if code.ExpiresAt.Before(time.Now()) {
+		return ErrCodeExpired
+	}
+
+	// 比较之前先占用校验次数，避免并发猜测绕过次数限制
+	claimed, err := m.repo.ClaimCodeAttempt(ctx, code.ID, m.limits.MaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrTooManyAttempts
+	}
+
+	if !cryptoutil.EqualHash(m.subject(target, purpose, plain), code.CodeHash) {
+		if code.Attempts+1 >= m.limits.MaxAttempts {
+			return ErrTooManyAttempts
+		}
+		return ErrCodeInvalid
+	}
+
+	used, err := m.repo.UseCode(ctx, code.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrCodeInvalid
+	}
+	return nil
+}
+
+// subject 验证码只有 6 位，哈希时带上渠道、接收方和用途，避免不同记录出现相同哈希
+// 验证码熵值低，安全性依赖有效期和校验次数限制
+func (m *Manager) subject(target, purpose, code string) string {
+	return m.channel + ":" + purpose + ":" + target + ":" + code
+}
+
+// Describe 将 Send/Verify 返回的错误转换为状态码和可直接展示给用户的提示
+// 未知错误返回 http.StatusInternalServerError，调用方需自行记录日志
+func Describe(err error) (int, string) {
+	var rateErr *RateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		return http.StatusTooManyRequests, fmt.Sprintf("发送过于频繁，请 %d 秒后重试", int(math.Ceil(rateErr.RetryAfter.Seconds())))
+	case errors.Is(err, ErrTooManyAttempts):
+		return http.StatusUnauthorized, "验证码错误次数过多，请重新获取"
+	case errors.Is(err, ErrCodeExpired):
+		return http.StatusUnauthorized, "验证码已过期，请重新获取"
+	case errors.Is(err, ErrCodeInvalid):
+		return http.StatusUnauthorized, "验证码错误"
+	default:
+		return http.StatusInternalServerError, "验证码服务异常"
+	}
+}
@@ -0,0 +1,86 @@
+package auth_code
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"king-starter/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// recordingDeliverer 记录最近发送的验证码
+type recordingDeliverer struct {
+	code string
+}
+
+func (d *recordingDeliverer) Deliver(_ context.Context, _, _, code string, _ time.Duration) error {
+	d.code = code
+	return nil
+}
+
+// TestVerifyConcurrentAttempts 并发猜测同一验证码时，校验次数不超过上限
+func TestVerifyConcurrentAttempts(t *testing.T) {
+	// 数据库固定为单连接；读取阶段的等待发生在查询返回之后，不占用连接
+	db := testutil.NewDB(t, &CoreVerifyCode{})
+
+	ctx := context.Background()
+	deliverer := &recordingDeliverer{}
+	m := NewManager(NewRepository(db), "email", Limits{TTL: time.Minute, MaxAttempts: 3}, deliverer)
+	require.NoError(t, m.Send(ctx, "alice@example.com", "login", "127.0.0.1"))
+	wrong := "000000"
+	if deliverer.code == wrong {
+		wrong = "111111"
+	}
+
+	// 所有请求都读取到验证码后才继续，模拟并发请求同时通过读取阶段
+	const concurrency = 10
+	var (
+		mu      sync.Mutex
+		reads   int
+		allRead = make(chan struct{})
+	)
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:barrier", func(*gorm.DB) {
+		mu.Lock()
+		reads++
+		if reads == concurrency {
+			close(allRead)
+		}
+		mu.Unlock()
+		select {
+		case <-allRead:
+		case <-time.After(time.Second):
+		}
+	}))
+
+	var (
+		wg      sync.WaitGroup
+		checked int
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := m.Verify(ctx, "alice@example.com", "login", wrong)
+			if errors.Is(err, ErrCodeInvalid) {
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, db.Callback().Query().Remove("test:barrier"))
+
+	// 最多只有 MaxAttempts 个请求比较了验证码，其余请求直接返回次数过多
+	assert.LessOrEqual(t, checked, 3)
+	var code CoreVerifyCode
+	require.NoError(t, db.First(&code).Error)
+	assert.Equal(t, 3, code.Attempts)
+	assert.ErrorIs(t, m.Verify(ctx, "alice@example.com", "login", deliverer.code), ErrTooManyAttempts)
+}
@@ -0,0 +1,25 @@
+package auth_code
+
+import (
+	"time"
+)
+
+// CoreVerifyCode 一次性验证码模型
+// 同一接收方同一用途只有最近发送的验证码有效
+type CoreVerifyCode struct {
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Channel   string     `gorm:"type:varchar(10);index:idx_verify_code_target" json:"channel"` // 发送渠道
+	Target    string     `gorm:"type:varchar(100);index:idx_verify_code_target" json:"target"` // 接收方：邮箱或手机号
+	Purpose   string     `gorm:"type:varchar(20);index:idx_verify_code_target" json:"purpose"` // 用途
+	CodeHash  string     `gorm:"type:varchar(64)" json:"-"`                                    // 验证码的 SHA-256 哈希
+	Attempts  int        `gorm:"default:0" json:"attempts"`                                    // 已校验次数
+	ExpiresAt time.Time  `json:"expires_at"`                                                   // 过期时间
+	UsedAt    *time.Time `json:"used_at,omitempty"`                                            // 使用时间
+	IP        string     `gorm:"type:varchar(50)" json:"ip"`                                   // 请求发送的IP
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CoreVerifyCode) TableName() string {
+	return "core_verify_codes"
+}
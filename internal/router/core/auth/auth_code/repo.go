@@ -0,0 +1,71 @@
+package auth_code
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository 验证码仓库
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建验证码仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateCode 保存验证码，顺便清理超过保留期的验证码
+func (r *Repository) CreateCode(ctx context.Context, code *CoreVerifyCode, retention time.Duration) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("created_at < ?", time.Now().Add(-retention)).Delete(&CoreVerifyCode{}).Error; err != nil {
+		return err
+	}
+	return db.Create(code).Error
+}
+
+// DeleteCode 删除验证码
+func (r *Repository) DeleteCode(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&CoreVerifyCode{}).Error
+}
+
+// GetLatestCode 查询接收方最近一次发送的验证码
+func (r *Repository) GetLatestCode(ctx context.Context, channel, target, purpose string) (*CoreVerifyCode, error) {
+	var code CoreVerifyCode
+	err := r.db.WithContext(ctx).
+		Where("channel = ? AND target = ? AND purpose = ?", channel, target, purpose).
+		Order("created_at DESC").
+		First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// CountCodesSince 统计接收方在指定时间之后发送的验证码数量（不区分用途）
+func (r *Repository) CountCodesSince(ctx context.Context, channel, target string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&CoreVerifyCode{}).
+		Where("channel = ? AND target = ? AND created_at >= ?", channel, target, since).
+		Count(&count).Error
+	return count, err
+}
+
+// ClaimCodeAttempt 占用一次校验次数，返回 false 表示校验次数已用完
+// 以条件更新原子地计数，并发校验同一验证码时总次数不会超过 maxAttempts
+func (r *Repository) ClaimCodeAttempt(ctx context.Context, id string, maxAttempts int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&CoreVerifyCode{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseCode 标记验证码已使用，返回 false 表示已被并发请求使用
+func (r *Repository) UseCode(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&CoreVerifyCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
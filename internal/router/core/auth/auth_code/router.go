@@ -0,0 +1,11 @@
+package auth_code
+
+import (
+	"king-starter/internal/app"
+)
+
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&CoreVerifyCode{},
+	)
+}
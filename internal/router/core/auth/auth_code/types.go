@@ -0,0 +1,11 @@
+package auth_code
+
+const (
+	ChannelEmail string = "email" // 邮件
+	ChannelSMS   string = "sms"   // 短信
+)
+
+const (
+	PurposeLogin  string = "login"  // 验证码登录
+	PurposeVerify string = "verify" // 验证邮箱/手机号
+)
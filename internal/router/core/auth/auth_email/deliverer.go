@@ -0,0 +1,48 @@
+package auth_email
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+// MailDeliverer 通过邮件发送验证码
+type MailDeliverer struct {
+	mailer mail.Mailer
+}
+
+// NewMailDeliverer 创建邮件验证码发送器
+func NewMailDeliverer(mailer mail.Mailer) *MailDeliverer {
+	return &MailDeliverer{mailer: mailer}
+}
+
+// Deliver 按用途生成邮件内容并发送
+func (d *MailDeliverer) Deliver(ctx context.Context, email, purpose, code string, ttl time.Duration) error {
+	subject, action := "登录验证码", "登录"
+	if purpose == auth_code.PurposeVerify {
+		subject, action = "邮箱验证码", "验证邮箱"
+	}
+	return d.mailer.Send(ctx, &mail.Message{
+		To:      []string{email},
+		Subject: subject,
+		Text:    fmt.Sprintf("您正在%s，验证码为 %s，%d 分钟内有效。\n\n如非本人操作，请忽略本邮件。", action, code, int(ttl.Minutes())),
+	})
+}
+
+// NewCodeManager 创建邮箱验证码管理器，邮箱登录和邮箱验证共用发送频率限制
+func NewCodeManager(db *gorm.DB, mailer mail.Mailer, cfg config.EmailCodeConfig) *auth_code.Manager {
+	limits := auth_code.Limits{
+		TTL:          time.Duration(cfg.TTL) * time.Second,
+		SendInterval: time.Duration(cfg.SendInterval) * time.Second,
+		SendWindow:   time.Hour,
+		SendLimit:    cfg.HourlyLimit,
+		MaxAttempts:  cfg.MaxAttempts,
+	}
+	return auth_code.NewManager(auth_code.NewRepository(db), auth_code.ChannelEmail, limits, NewMailDeliverer(mailer))
+}
@@ -1,64 +1,156 @@
 package auth_email
 
 import (
+	"errors"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 
 	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/logx"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
-// EmailAuthReq 邮箱认证请求参数
-type EmailAuthReq struct {
-	Email string `json:"email" validate:"required,email"`
-	Code  string `json:"code" validate:"required,len=6"`
-}
-
 // EmailHandler 邮箱认证处理器
 type EmailHandler struct {
-	// repo *Repository
+	repo     *Repository
+	codes    *auth_code.Manager
+	userRepo *user.Repository
+	issuer   *auth_password.TokenIssuer
 }
 
 // NewEmailHandler 创建邮箱认证处理器实例
-func NewEmailHandler() *EmailHandler {
-	return &EmailHandler{}
+func NewEmailHandler(repo *Repository, codes *auth_code.Manager, userRepo *user.Repository, issuer *auth_password.TokenIssuer) *EmailHandler {
+	return &EmailHandler{
+		repo:     repo,
+		codes:    codes,
+		userRepo: userRepo,
+		issuer:   issuer,
+	}
 }
 
-// SendVerificationCode 发送验证码
+// SendVerificationCode 发送登录验证码
 func (h *EmailHandler) SendVerificationCode(c echo.Context) error {
-	email := c.QueryParam("email")
-	if email == "" {
+	var req SendCodeReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	// 兼容通过查询参数传递邮箱的旧客户端
+	if req.Email == "" {
+		req.Email = c.QueryParam("email")
+	}
+	if req.Email == "" {
 		return response.Error(c, http.StatusBadRequest, "邮箱不能为空")
 	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "邮箱格式错误")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	// 邮箱未注册或用户已禁用时同样返回成功，避免泄露账号是否存在
+	if u == nil || u.Status == 0 {
+		return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+	}
 
-	// 这里应该是发送邮件验证码的逻辑
-	// 为了示例，我们只返回成功
+	if err := h.codes.Send(ctx, email, auth_code.PurposeLogin, c.RealIP()); err != nil {
+		// 发送频率限制只会作用于已注册的邮箱，同样返回成功，避免通过 429 判断账号是否存在
+		var rateErr *auth_code.RateLimitError
+		if errors.As(err, &rateErr) {
+			return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+		}
+		status, msg := auth_code.Describe(err)
+		if status == http.StatusInternalServerError {
+			logx.Error("send email code failed", "email", email, "error", err)
+		}
+		return response.Error(c, status, msg)
+	}
 
 	return response.SuccessWithMsg[any](c, "验证码已发送", nil)
 }
 
-// VerifyEmail 验证邮箱
+// VerifyEmail 校验邮箱验证码并登录，成功后签发与密码登录相同的令牌
 func (h *EmailHandler) VerifyEmail(c echo.Context) error {
 	var req EmailAuthReq
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
+	if req.Email == "" || req.Code == "" {
+		return response.Error(c, http.StatusBadRequest, "邮箱和验证码不能为空")
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "邮箱格式错误")
+	}
 
-	// 这里应该是验证邮箱验证码的逻辑
-	// 为了示例，我们假设验证成功
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+		}
+		h.createLoginLog(c, "", email, LoginTypeFailed, "用户不存在")
+		return response.Error(c, http.StatusUnauthorized, "验证码错误")
+	}
 
-	// 记录邮箱认证成功日志
-	// log := &core.LoginLog{
-	// 	ID:        uuid.New().String(),
-	// 	UserID:    "1", // 示例用户ID
-	// 	Username:  req.Email,
-	// 	AuthType:  core.AuthTypeEmail,
-	// 	LoginType: core.LoginTypeSuccess,
-	// 	IP:        c.RealIP(),
-	// 	UserAgent: c.Request().UserAgent(),
-	// 	Message:   "邮箱验证成功",
-	// }
-	// h.repo.CreateLoginLog(c.Request().Context(), log) // 暂时注释掉，因为repo未初始化
+	if err := h.codes.Verify(ctx, email, auth_code.PurposeLogin, req.Code); err != nil {
+		status, msg := auth_code.Describe(err)
+		if status == http.StatusInternalServerError {
+			return response.Error(c, status, "校验验证码失败")
+		}
+		h.createLoginLog(c, u.ID, u.Username, LoginTypeFailed, msg)
+		return response.Error(c, status, msg)
+	}
+
+	// 能收到验证码即证明拥有该邮箱
+	if u.EmailVerifiedAt == nil {
+		if verified, err := h.userRepo.MarkEmailVerified(ctx, u.ID, u.Email); err != nil {
+			logx.Error("mark email verified failed", "user_id", u.ID, "error", err)
+		} else if verified {
+			now := time.Now()
+			u.EmailVerifiedAt = &now
+		}
+	}
 
-	return response.SuccessWithMsg[any](c, "邮箱验证成功", nil)
+	return h.issuer.Complete(c, u, func(loginType, message string) {
+		h.createLoginLog(c, u.ID, u.Username, loginType, message)
+	})
+}
+
+// normalizeEmail 校验邮箱格式并统一转为小写
+func normalizeEmail(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", false
+	}
+	return s, true
+}
+
+// createLoginLog 记录邮箱验证码登录日志
+func (h *EmailHandler) createLoginLog(c echo.Context, userID, username, loginType, message string) {
+	log := &auth_password.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Username:  username,
+		AuthType:  auth_password.AuthTypeEmail,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
 }
@@ -0,0 +1,150 @@
+package auth_email
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	mailer  *testutil.Mailer
+	handler *EmailHandler
+}
+
+func newTestEnv(t *testing.T, cfg config.EmailCodeConfig) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&auth_password.CoreLoginLog{}, &auth_password.CoreRefreshToken{}, &auth_password.CoreLoginChallenge{},
+		&auth_code.CoreVerifyCode{},
+	)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Email: "alice@example.com", Status: 1}).Error)
+
+	mailer := &testutil.Mailer{}
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(db), role.NewRoleRepo(db), testutil.NewJWT(), false, nil, 5*time.Minute)
+	return &testEnv{
+		db:      db,
+		mailer:  mailer,
+		handler: NewEmailHandler(NewRepository(db), NewCodeManager(db, mailer, cfg), user.NewRepository(db), issuer),
+	}
+}
+
+func (env *testEnv) call(t *testing.T, h echo.HandlerFunc, body interface{}) (int, interface{}) {
+	resp := testutil.Call(t, h, "", body)
+	return resp.Code, resp.Data
+}
+
+func (env *testEnv) send(t *testing.T, email string) int {
+	code, _ := env.call(t, env.handler.SendVerificationCode, map[string]string{"email": email})
+	return code
+}
+
+func (env *testEnv) verify(t *testing.T, email, code string) (int, interface{}) {
+	return env.call(t, env.handler.VerifyEmail, map[string]string{"email": email, "code": code})
+}
+
+func TestEmailCodeLogin(t *testing.T) {
+	env := newTestEnv(t, config.DefaultAuthConfig().EmailCode)
+
+	require.Equal(t, http.StatusOK, env.send(t, "Alice@Example.com"))
+	code := env.mailer.LastCode(t)
+	require.Len(t, code, 6)
+
+	var stored auth_code.CoreVerifyCode
+	require.NoError(t, env.db.First(&stored).Error)
+	assert.Equal(t, "alice@example.com", stored.Target)
+	assert.NotContains(t, stored.CodeHash, code)
+
+	status, data := env.verify(t, "alice@example.com", code)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data.(map[string]interface{})["access_token"])
+	assert.NotEmpty(t, data.(map[string]interface{})["refresh_token"])
+
+	var log auth_password.CoreLoginLog
+	require.NoError(t, env.db.Where("login_type = ?", LoginTypeSuccess).First(&log).Error)
+	assert.Equal(t, auth_password.AuthTypeEmail, log.AuthType)
+
+	// 验证码登录同时完成邮箱验证
+	var u user.CoreUser
+	require.NoError(t, env.db.First(&u, "id = ?", "u1").Error)
+	assert.NotNil(t, u.EmailVerifiedAt)
+
+	// 验证码只能使用一次
+	status, _ = env.verify(t, "alice@example.com", code)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestEmailCodeUnknownAddress(t *testing.T) {
+	env := newTestEnv(t, config.DefaultAuthConfig().EmailCode)
+
+	assert.Equal(t, http.StatusOK, env.send(t, "bob@example.com"))
+	assert.Empty(t, env.mailer.Sent())
+
+	assert.Equal(t, http.StatusBadRequest, env.send(t, "not-an-email"))
+}
+
+func TestEmailCodeRateLimit(t *testing.T) {
+	cfg := config.DefaultAuthConfig().EmailCode
+	env := newTestEnv(t, cfg)
+
+	// 受频率限制时不发送，但与未注册的邮箱一样返回成功，避免泄露账号是否存在
+	require.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	assert.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	assert.Len(t, env.mailer.Sent(), 1)
+
+	// 超过每小时发送次数
+	cfg.SendInterval = 0
+	cfg.HourlyLimit = 2
+	env.handler.codes = NewCodeManager(env.db, env.mailer, cfg)
+	require.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	assert.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	assert.Len(t, env.mailer.Sent(), 2)
+}
+
+func TestEmailCodeAttemptLimit(t *testing.T) {
+	cfg := config.DefaultAuthConfig().EmailCode
+	cfg.MaxAttempts = 3
+	env := newTestEnv(t, cfg)
+
+	require.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	code := env.mailer.LastCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		status, _ := env.verify(t, "alice@example.com", wrong)
+		require.Equal(t, http.StatusUnauthorized, status)
+	}
+	// 次数用尽后正确的验证码也不再有效
+	status, _ := env.verify(t, "alice@example.com", code)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestEmailCodeExpired(t *testing.T) {
+	env := newTestEnv(t, config.DefaultAuthConfig().EmailCode)
+
+	require.Equal(t, http.StatusOK, env.send(t, "alice@example.com"))
+	code := env.mailer.LastCode(t)
+	require.NoError(t, env.db.Model(&auth_code.CoreVerifyCode{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error)
+
+	status, _ := env.verify(t, "alice@example.com", code)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
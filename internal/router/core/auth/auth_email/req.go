@@ -0,0 +1,12 @@
+package auth_email
+
+// SendCodeReq 发送邮箱验证码请求参数
+type SendCodeReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// EmailAuthReq 邮箱认证请求参数
+type EmailAuthReq struct {
+	Email string `json:"email" validate:"required,email"`
+	Code  string `json:"code" validate:"required,len=6"`
+}
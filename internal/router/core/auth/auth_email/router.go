@@ -1,19 +1,27 @@
 package auth_email
 
 import (
+	"time"
+
 	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
 )
 
 // RegisterRoutes 注册邮箱认证路由
-func RegisterRoutes(app *app.App) {
-	handler := NewEmailHandler()
+func RegisterRoutes(app *app.App, secondFactor auth_password.SecondFactor) {
+	repo := NewRepository(app.Db.DB)
+	codes := NewCodeManager(app.Db.DB, app.Mailer, app.Config.Auth.EmailCode)
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(app.Db.DB), role.NewRoleRepo(app.Db.DB), app.Jwt, app.Config.Auth.RequireEmailVerified, secondFactor, time.Duration(app.Config.Auth.TwoFA.ChallengeTTL)*time.Second)
+	handler := NewEmailHandler(repo, codes, user.NewRepository(app.Db.DB), issuer)
 
 	e := app.Server.Engine()
 
 	// 邮箱认证路由组
 	authGroup := e.Group("/api/core/auth")
 	{
-		authGroup.POST("/email/send-code", handler.SendVerificationCode) // 发送验证码
-		authGroup.POST("/email/verify", handler.VerifyEmail)             // 验证邮箱
+		authGroup.POST("/email/send-code", handler.SendVerificationCode) // 发送登录验证码
+		authGroup.POST("/email/verify", handler.VerifyEmail)             // 验证码登录
 	}
 }
@@ -0,0 +1,6 @@
+package auth_email
+
+const (
+	LoginTypeSuccess string = "success" // 登录成功
+	LoginTypeFailed  string = "failed"  // 登录失败
+)
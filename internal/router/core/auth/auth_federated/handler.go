@@ -0,0 +1,487 @@
+package auth_federated
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"king-starter/config"
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/goutils/idutil"
+	"king-starter/pkg/logx"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	// errAccountNotLinked 第三方账号没有关联本地用户，且提供方未开启自动创建
+	errAccountNotLinked = errors.New("federated identity not linked")
+	// errEmailInUse 邮箱已被本地用户使用，但不满足自动关联的条件
+	errEmailInUse = errors.New("email already in use")
+	// errEmailRequired 自动创建用户需要提供方返回已验证的邮箱
+	errEmailRequired = errors.New("verified email required")
+	// errInvalidState state 不存在、已过期、已使用，或与回调接口不匹配
+	errInvalidState = errors.New("invalid federated state")
+	// errCodeRequired 回调缺少授权码
+	errCodeRequired = errors.New("authorization code required")
+	// errExchangeFailed 用授权码换取第三方身份失败
+	errExchangeFailed = errors.New("federated exchange failed")
+)
+
+// registeredProvider 已启用的身份提供方及其配置
+type registeredProvider struct {
+	Provider
+	cfg config.IdentityProviderConfig
+}
+
+// FederatedHandler 第三方身份提供方登录处理器
+type FederatedHandler struct {
+	repo      *Repository
+	userRepo  *user.Repository
+	issuer    *auth_password.TokenIssuer
+	providers map[string]*registeredProvider
+	names     []string // 按配置顺序展示
+	stateTTL  time.Duration
+}
+
+// NewFederatedHandler 创建第三方登录处理器实例，提供方通过 AddProvider 添加
+func NewFederatedHandler(repo *Repository, userRepo *user.Repository, issuer *auth_password.TokenIssuer, stateTTL time.Duration) *FederatedHandler {
+	return &FederatedHandler{
+		repo:      repo,
+		userRepo:  userRepo,
+		issuer:    issuer,
+		providers: make(map[string]*registeredProvider),
+		stateTTL:  stateTTL,
+	}
+}
+
+// AddProvider 启用身份提供方，同名提供方后添加的覆盖先添加的
+func (h *FederatedHandler) AddProvider(cfg config.IdentityProviderConfig, provider Provider) {
+	if _, ok := h.providers[cfg.Name]; !ok {
+		h.names = append(h.names, cfg.Name)
+	}
+	h.providers[cfg.Name] = &registeredProvider{Provider: provider, cfg: cfg}
+}
+
+// ListProviders 查询已启用的身份提供方，供登录页展示
+func (h *FederatedHandler) ListProviders(c echo.Context) error {
+	items := make([]map[string]interface{}, 0, len(h.names))
+	for _, name := range h.names {
+		cfg := h.providers[name].cfg
+		displayName := cfg.DisplayName
+		if displayName == "" {
+			displayName = cfg.Name
+		}
+		items = append(items, map[string]interface{}{
+			"name":         cfg.Name,
+			"type":         cfg.Type,
+			"display_name": displayName,
+		})
+	}
+	return response.Success[any](c, items)
+}
+
+// Authorize 浏览器访问后跳转到提供方授权页面
+func (h *FederatedHandler) Authorize(c echo.Context) error {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return response.Error(c, http.StatusNotFound, "身份提供方不存在")
+	}
+
+	authURL, err := h.begin(c, provider, "")
+	if err != nil {
+		return response.Error(c, http.StatusBadGateway, "无法连接身份提供方")
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// BeginLink 当前用户发起关联第三方账号，返回提供方授权地址，由前端跳转
+// 授权完成后提供方回调到前端回调页面，前端携带当前用户令牌调用 LinkCallback 完成关联
+func (h *FederatedHandler) BeginLink(c echo.Context) error {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return response.Error(c, http.StatusNotFound, "身份提供方不存在")
+	}
+
+	authURL, err := h.begin(c, provider, echoutil.GetUserID(c))
+	if err != nil {
+		return response.Error(c, http.StatusBadGateway, "无法连接身份提供方")
+	}
+	return response.Success[any](c, map[string]interface{}{
+		"authorize_url": authURL,
+	})
+}
+
+// Callback 提供方登录回调：校验 state，用授权码换取第三方身份并登录
+// 登录成功后签发与密码登录相同的令牌，启用了两步验证时返回登录挑战
+func (h *FederatedHandler) Callback(c echo.Context) error {
+	var req CallbackReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return response.Error(c, http.StatusNotFound, "身份提供方不存在")
+	}
+
+	state, identity, err := h.exchange(c.Request().Context(), provider, req)
+	if err != nil {
+		return callbackError(c, err)
+	}
+	// 关联请求只能由发起关联的用户通过 LinkCallback 完成
+	if state.UserID != "" {
+		return callbackError(c, errInvalidState)
+	}
+	return h.login(c, provider, identity)
+}
+
+// LinkCallback 提供方关联回调：将第三方身份关联到当前用户
+// state 必须由当前用户通过 BeginLink 发起，避免他人诱导当前用户授权后把第三方账号关联到自己名下
+func (h *FederatedHandler) LinkCallback(c echo.Context) error {
+	var req CallbackReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return response.Error(c, http.StatusNotFound, "身份提供方不存在")
+	}
+
+	userID := echoutil.GetUserID(c)
+	state, identity, err := h.exchange(c.Request().Context(), provider, req)
+	if err != nil {
+		return callbackError(c, err)
+	}
+	if state.UserID == "" || state.UserID != userID {
+		return callbackError(c, errInvalidState)
+	}
+	return h.link(c, provider, userID, identity)
+}
+
+// exchange 取出 state 并用授权码换取第三方身份
+func (h *FederatedHandler) exchange(ctx context.Context, provider *registeredProvider, req CallbackReq) (*CoreFederatedState, *Identity, error) {
+	if req.State == "" {
+		return nil, nil, errInvalidState
+	}
+
+	// state 只能使用一次，无论成功与否都先取出
+	state, err := h.repo.TakeState(ctx, cryptoutil.SHA256Hex(req.State), provider.cfg.Name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errInvalidState
+		}
+		return nil, nil, err
+	}
+	if req.Error != "" {
+		return nil, nil, &deniedError{code: req.Error}
+	}
+	if req.Code == "" {
+		return nil, nil, errCodeRequired
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		logx.Warn("federated login exchange failed", "provider", provider.cfg.Name, "error", err)
+		return nil, nil, errExchangeFailed
+	}
+	return state, identity, nil
+}
+
+// deniedError 用户拒绝授权等情况下提供方返回的错误
+type deniedError struct {
+	code string
+}
+
+func (e *deniedError) Error() string {
+	return "identity provider denied: " + e.code
+}
+
+// callbackError 将回调处理中的错误转换为响应
+func callbackError(c echo.Context, err error) error {
+	var denied *deniedError
+	switch {
+	case errors.Is(err, errInvalidState):
+		return response.Error(c, http.StatusBadRequest, "授权请求已失效，请重新发起")
+	case errors.Is(err, errCodeRequired):
+		return response.Error(c, http.StatusBadRequest, "授权码不能为空")
+	case errors.Is(err, errExchangeFailed):
+		return response.Error(c, http.StatusUnauthorized, "第三方身份验证失败")
+	case errors.As(err, &denied):
+		return response.Error(c, http.StatusUnauthorized, "身份提供方未授权: "+denied.code)
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询授权请求失败")
+}
+
+// ListIdentities 查询当前用户关联的第三方身份
+func (h *FederatedHandler) ListIdentities(c echo.Context) error {
+	identities, err := h.repo.ListIdentitiesByUserID(c.Request().Context(), echoutil.GetUserID(c))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询关联账号失败")
+	}
+	return response.Success[any](c, identities)
+}
+
+// DeleteIdentity 当前用户解除关联的第三方身份
+// 没有设置密码的用户不能解除唯一的第三方身份，否则将无法再登录
+func (h *FederatedHandler) DeleteIdentity(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+	id := c.Param("id")
+
+	identities, err := h.repo.ListIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询关联账号失败")
+	}
+	found := false
+	for _, identity := range identities {
+		if identity.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return response.Error(c, http.StatusNotFound, "关联账号不存在")
+	}
+
+	u, err := h.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u.Password == "" && len(identities) == 1 {
+		return response.Error(c, http.StatusBadRequest, "请先设置密码，再解除唯一的关联账号")
+	}
+
+	affected, err := h.repo.DeleteIdentity(ctx, userID, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "解除关联失败")
+	}
+	if affected == 0 {
+		return response.Error(c, http.StatusNotFound, "关联账号不存在")
+	}
+	return response.SuccessWithMsg[any](c, "解除关联成功", nil)
+}
+
+// begin 保存授权请求并返回提供方授权地址，userID 不为空时为关联账号请求
+func (h *FederatedHandler) begin(c echo.Context, provider *registeredProvider, userID string) (string, error) {
+	ctx := c.Request().Context()
+	stateToken := cryptoutil.RandomToken(32)
+	state := &CoreFederatedState{
+		ID:           uuid.New().String(),
+		StateHash:    cryptoutil.SHA256Hex(stateToken),
+		Provider:     provider.cfg.Name,
+		UserID:       userID,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        cryptoutil.RandomToken(16),
+		ExpiresAt:    time.Now().Add(h.stateTTL),
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, stateToken, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		logx.Error("federated provider unavailable", "provider", provider.cfg.Name, "error", err)
+		return "", err
+	}
+	if err := h.repo.CreateState(ctx, state); err != nil {
+		logx.Error("failed to save federated state", "provider", provider.cfg.Name, "error", err)
+		return "", err
+	}
+	return authURL, nil
+}
+
+// login 第三方身份登录
+func (h *FederatedHandler) login(c echo.Context, provider *registeredProvider, identity *Identity) error {
+	ctx := c.Request().Context()
+
+	u, err := h.resolveUser(ctx, provider, identity)
+	if err != nil {
+		h.createLoginLog(c, "", identity.Email, auth_password.LoginTypeFailed, provider.cfg.Name+": "+err.Error())
+		switch {
+		case errors.Is(err, errAccountNotLinked):
+			return response.Error(c, http.StatusForbidden, "该第三方账号未关联本系统用户")
+		case errors.Is(err, errEmailInUse):
+			return response.Error(c, http.StatusBadRequest, "邮箱已被其他账号使用，请登录该账号后在账号设置中关联")
+		case errors.Is(err, errEmailRequired):
+			return response.Error(c, http.StatusBadRequest, "第三方账号未提供已验证的邮箱，无法自动注册")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return response.Error(c, http.StatusUnauthorized, "用户不存在")
+		}
+		logx.Error("federated login failed", "provider", provider.cfg.Name, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "第三方登录失败")
+	}
+
+	return h.issuer.Complete(c, u, func(loginType, message string) {
+		h.createLoginLog(c, u.ID, u.Username, loginType, message+"，身份提供方: "+provider.cfg.Name)
+	})
+}
+
+// link 将第三方身份关联到当前用户
+func (h *FederatedHandler) link(c echo.Context, provider *registeredProvider, userID string, identity *Identity) error {
+	ctx := c.Request().Context()
+
+	existing, err := h.repo.GetIdentity(ctx, provider.cfg.Name, identity.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			return response.Error(c, http.StatusBadRequest, "该第三方账号已关联其他用户")
+		}
+		return response.SuccessWithMsg[any](c, "已关联该第三方账号", existing)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询关联账号失败")
+	}
+
+	linked := newIdentity(provider, identity, userID)
+	if err := h.repo.CreateIdentity(ctx, linked); err != nil {
+		logx.Error("failed to link federated identity", "provider", provider.cfg.Name, "user_id", userID, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "关联账号失败")
+	}
+	return response.SuccessWithMsg[any](c, "关联成功", linked)
+}
+
+// resolveUser 查找第三方身份对应的本地用户
+// 依次按已关联的身份、已验证的邮箱查找，都没有时按配置自动创建用户
+func (h *FederatedHandler) resolveUser(ctx context.Context, provider *registeredProvider, identity *Identity) (*user.CoreUser, error) {
+	linked, err := h.repo.GetIdentity(ctx, provider.cfg.Name, identity.Subject)
+	if err == nil {
+		u, err := h.userRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.repo.UpdateIdentityLogin(ctx, linked.ID, identity.Email, identity.Name); err != nil {
+			logx.Error("failed to update federated identity", "id", linked.ID, "error", err)
+		}
+		return u, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email != "" && identity.EmailVerified {
+		u, err := h.userRepo.GetByEmail(ctx, email)
+		if err == nil {
+			// 本地邮箱也必须已验证：否则他人可以预先用该邮箱注册账号，等邮箱所有者第三方登录后接管账号
+			if !provider.cfg.LinkByEmail || u.EmailVerifiedAt == nil {
+				return nil, errEmailInUse
+			}
+			if err := h.repo.CreateIdentity(ctx, newIdentity(provider, identity, u.ID)); err != nil {
+				return nil, err
+			}
+			return u, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if !provider.cfg.AutoProvision {
+		return nil, errAccountNotLinked
+	}
+	if email == "" || !identity.EmailVerified {
+		return nil, errEmailRequired
+	}
+
+	now := time.Now()
+	id := idutil.ShortUUIDv7()
+	username, err := h.uniqueUsername(ctx, provider, identity, email)
+	if err != nil {
+		return nil, err
+	}
+	u := &user.CoreUser{
+		ID:              id,
+		Username:        username,
+		Nickname:        truncate(identity.Name, 50),
+		Email:           email,
+		EmailVerifiedAt: &now,
+		Status:          1, // 默认启用
+		CreatedBy:       id,
+		UpdatedBy:       id,
+	}
+	if err := h.repo.CreateUserWithIdentity(ctx, u, newIdentity(provider, identity, u.ID)); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// uniqueUsername 为自动创建的用户生成未被占用的用户名，优先使用提供方的登录名，其次是邮箱前缀
+func (h *FederatedHandler) uniqueUsername(ctx context.Context, provider *registeredProvider, identity *Identity, email string) (string, error) {
+	base := sanitizeUsername(identity.Username)
+	if base == "" {
+		base = sanitizeUsername(email[:strings.Index(email+"@", "@")])
+	}
+	if base == "" {
+		base = sanitizeUsername(provider.cfg.Name)
+	}
+
+	candidate := base
+	for range 5 {
+		_, err := h.userRepo.GetByUsername(ctx, candidate)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + cryptoutil.RandomString("0123456789", 6)
+	}
+	return "", errors.New("no available username for " + base)
+}
+
+// sanitizeUsername 只保留字母、数字和 _ - .，长度不超过 40
+func sanitizeUsername(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.') {
+			return r
+		}
+		return -1
+	}, s)
+	return truncate(s, 40)
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// newIdentity 创建关联记录
+func newIdentity(provider *registeredProvider, identity *Identity, userID string) *CoreUserIdentity {
+	now := time.Now()
+	return &CoreUserIdentity{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Provider:    provider.cfg.Name,
+		Subject:     identity.Subject,
+		Email:       truncate(identity.Email, 100),
+		Name:        truncate(identity.Name, 100),
+		LastLoginAt: &now,
+	}
+}
+
+// createLoginLog 记录第三方登录日志
+func (h *FederatedHandler) createLoginLog(c echo.Context, userID, username, loginType, message string) {
+	log := &auth_password.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Username:  username,
+		AuthType:  auth_password.AuthTypeSSO,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
+}
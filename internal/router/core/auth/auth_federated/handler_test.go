@@ -0,0 +1,428 @@
+package auth_federated
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// fakeUser 模拟提供方上的用户
+type fakeUser struct {
+	sub      string
+	email    string
+	verified bool
+	name     string
+	login    string
+}
+
+// fakeGrant 模拟提供方签发的授权码
+type fakeGrant struct {
+	challenge string
+	nonce     string
+	user      fakeUser
+}
+
+// fakeProvider 本地模拟的身份提供方，同时提供 OpenID Connect 端点和 GitHub 风格的 REST API
+type fakeProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant // 授权码
+	tokens map[string]fakeUser  // 访问令牌
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &fakeProvider{key: key, grants: map[string]fakeGrant{}, tokens: map[string]fakeUser{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"userinfo_endpoint":                     p.server.URL + "/userinfo",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token(t))
+	mux.HandleFunc("/userinfo", p.withUser(func(w http.ResponseWriter, u fakeUser) {
+		writeJSON(w, map[string]interface{}{"sub": u.sub, "email": u.email, "email_verified": u.verified})
+	}))
+	mux.HandleFunc("/api/user", p.withUser(func(w http.ResponseWriter, u fakeUser) {
+		id, _ := new(big.Int).SetString(u.sub, 10)
+		writeJSON(w, map[string]interface{}{"id": id.Int64(), "login": u.login, "name": u.name})
+	}))
+	mux.HandleFunc("/api/user/emails", p.withUser(func(w http.ResponseWriter, u fakeUser) {
+		writeJSON(w, []map[string]interface{}{
+			{"email": "noreply@users.example.com", "primary": false, "verified": true},
+			{"email": u.email, "primary": true, "verified": u.verified},
+		})
+	}))
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// token 令牌端点：校验授权码和 PKCE，返回访问令牌和 id_token
+func (p *fakeProvider) token(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		p.mu.Lock()
+		grant, ok := p.grants[r.PostForm.Get("code")]
+		delete(p.grants, r.PostForm.Get("code"))
+		p.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		accessToken := "at-" + grant.user.sub + "-" + r.PostForm.Get("code")
+		p.mu.Lock()
+		p.tokens[accessToken] = grant.user
+		p.mu.Unlock()
+
+		idToken := jwtv5.NewWithClaims(jwtv5.SigningMethodRS256, jwtv5.MapClaims{
+			"iss":            p.server.URL,
+			"aud":            "test-client",
+			"sub":            grant.user.sub,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          grant.nonce,
+			"email":          grant.user.email,
+			"email_verified": grant.user.verified,
+			"name":           grant.user.name,
+		})
+		idToken.Header["kid"] = "k1"
+		signed, err := idToken.SignedString(p.key)
+		require.NoError(t, err)
+
+		writeJSON(w, map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	}
+}
+
+// withUser 校验 Bearer 访问令牌
+func (p *fakeProvider) withUser(next func(http.ResponseWriter, fakeUser)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		u, ok := p.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		p.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, u)
+	}
+}
+
+// approve 模拟用户在提供方同意授权，返回授权码
+func (p *fakeProvider) approve(t *testing.T, authURL string, u fakeUser) string {
+	loc, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := loc.Query()
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+	require.NotEmpty(t, q.Get("code_challenge"))
+
+	code := "code-" + u.sub + "-" + q.Get("state")[:8]
+	p.mu.Lock()
+	p.grants[code] = fakeGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), user: u}
+	p.mu.Unlock()
+	return code
+}
+
+type testEnv struct {
+	db       *gorm.DB
+	handler  *FederatedHandler
+	provider *fakeProvider
+	e        *echo.Echo
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&auth_password.CoreLoginLog{}, &auth_password.CoreRefreshToken{},
+		&CoreUserIdentity{}, &CoreFederatedState{},
+	)
+	verifiedAt := time.Now()
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Password: "hash", Email: "alice@example.com", Phone: "13800000001", EmailVerifiedAt: &verifiedAt, Status: 1}).Error)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u2", Username: "bob", Email: "bob@example.com", Phone: "13800000002", Status: 1}).Error)
+
+	fake := newFakeProvider(t)
+	j := testutil.NewJWT()
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(db), role.NewRoleRepo(db), j, false, nil, 5*time.Minute)
+	handler := NewFederatedHandler(NewRepository(db), user.NewRepository(db), issuer, 10*time.Minute)
+
+	for _, cfg := range []config.IdentityProviderConfig{
+		{Name: "corp", Type: ProviderTypeOIDC, Issuer: fake.server.URL, AutoProvision: true, LinkByEmail: true},
+		{Name: "gh", Type: ProviderTypeGitHub, AuthURL: fake.server.URL + "/authorize", TokenURL: fake.server.URL + "/token", APIURL: fake.server.URL + "/api"},
+	} {
+		cfg.ClientID = "test-client"
+		cfg.ClientSecret = "test-secret"
+		cfg.RedirectURL = "https://app.example.com/login/callback/" + cfg.Name
+		provider, err := NewProvider(cfg)
+		require.NoError(t, err)
+		handler.AddProvider(cfg, provider)
+	}
+
+	return &testEnv{db: db, handler: handler, provider: fake, e: echo.New()}
+}
+
+// call 调用处理器并返回响应中的 code 与 data
+func (env *testEnv) call(t *testing.T, h echo.HandlerFunc, userID string, body interface{}, params ...string) (int, interface{}) {
+	resp := testutil.Call(t, h, userID, body, params...)
+	return resp.Code, resp.Data
+}
+
+// authorize 访问登录地址，返回跳转的提供方授权地址
+func (env *testEnv) authorize(t *testing.T, provider string) string {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues(provider)
+	require.NoError(t, env.handler.Authorize(c))
+	require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+	return rec.Header().Get(echo.HeaderLocation)
+}
+
+// login 完成一次第三方登录，返回回调接口的响应
+func (env *testEnv) login(t *testing.T, provider string, u fakeUser) (int, interface{}) {
+	authURL := env.authorize(t, provider)
+	code := env.provider.approve(t, authURL, u)
+	return env.callback(t, provider, code, stateOf(t, authURL))
+}
+
+func (env *testEnv) callback(t *testing.T, provider, code, state string) (int, interface{}) {
+	return env.call(t, env.handler.Callback, "", map[string]string{"code": code, "state": state}, "provider", provider)
+}
+
+// linkCallback 以用户 userID 的身份完成关联回调
+func (env *testEnv) linkCallback(t *testing.T, userID, provider, code, state string) (int, interface{}) {
+	return env.call(t, env.handler.LinkCallback, userID, map[string]string{"code": code, "state": state}, "provider", provider)
+}
+
+func stateOf(t *testing.T, authURL string) string {
+	loc, err := url.Parse(authURL)
+	require.NoError(t, err)
+	return loc.Query().Get("state")
+}
+
+func (env *testEnv) countUsers(t *testing.T) int64 {
+	var count int64
+	require.NoError(t, env.db.Model(&user.CoreUser{}).Count(&count).Error)
+	return count
+}
+
+func TestListProviders(t *testing.T) {
+	env := newTestEnv(t)
+
+	code, data := env.call(t, env.handler.ListProviders, "", nil)
+	require.Equal(t, http.StatusOK, code)
+	items := data.([]interface{})
+	require.Len(t, items, 2)
+	assert.Equal(t, "corp", items[0].(map[string]interface{})["name"])
+	assert.Equal(t, "gh", items[1].(map[string]interface{})["name"])
+}
+
+func TestOIDCLoginAutoProvision(t *testing.T) {
+	env := newTestEnv(t)
+	carol := fakeUser{sub: "oidc-carol", email: "Carol@Example.com", verified: true, name: "Carol"}
+
+	authURL := env.authorize(t, "corp")
+	assert.True(t, strings.HasPrefix(authURL, env.provider.server.URL+"/authorize?"))
+	q, err := url.ParseQuery(authURL[strings.Index(authURL, "?")+1:])
+	require.NoError(t, err)
+	assert.Equal(t, "test-client", q.Get("client_id"))
+	assert.Equal(t, "https://app.example.com/login/callback/corp", q.Get("redirect_uri"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	assert.NotEmpty(t, q.Get("nonce"))
+
+	code, data := env.callback(t, "corp", env.provider.approve(t, authURL, carol), q.Get("state"))
+	require.Equal(t, http.StatusOK, code, data)
+	assert.NotEmpty(t, data.(map[string]interface{})["access_token"])
+	loggedIn := data.(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, "carol", loggedIn["username"])
+	assert.Equal(t, "Carol", loggedIn["name"])
+
+	var created user.CoreUser
+	require.NoError(t, env.db.Where("email = ?", "carol@example.com").First(&created).Error)
+	assert.NotNil(t, created.EmailVerifiedAt)
+	assert.Empty(t, created.Password)
+
+	// 再次登录使用已关联的身份，不会重复创建用户
+	code, data = env.login(t, "corp", carol)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, created.ID, data.(map[string]interface{})["user"].(map[string]interface{})["id"])
+	assert.Equal(t, int64(3), env.countUsers(t))
+
+	// state 只能使用一次
+	code, _ = env.callback(t, "corp", env.provider.approve(t, authURL, carol), q.Get("state"))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 双方邮箱都已验证时关联已有用户
+	code, data := env.login(t, "corp", fakeUser{sub: "oidc-alice", email: "alice@example.com", verified: true})
+	require.Equal(t, http.StatusOK, code, data)
+	assert.Equal(t, "u1", data.(map[string]interface{})["user"].(map[string]interface{})["id"])
+	var identity CoreUserIdentity
+	require.NoError(t, env.db.Where("provider = ? AND subject = ?", "corp", "oidc-alice").First(&identity).Error)
+	assert.Equal(t, "u1", identity.UserID)
+
+	// 本地邮箱未验证时不能自动关联，也不能用该邮箱创建新用户
+	code, _ = env.login(t, "corp", fakeUser{sub: "oidc-bob", email: "bob@example.com", verified: true})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// 提供方未验证邮箱时不能自动创建用户
+	code, _ = env.login(t, "corp", fakeUser{sub: "oidc-dave", email: "dave@example.com"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, int64(2), env.countUsers(t))
+}
+
+func TestCallbackRejectsInvalidExchange(t *testing.T) {
+	env := newTestEnv(t)
+	carol := fakeUser{sub: "oidc-carol", email: "carol@example.com", verified: true}
+
+	// 授权码与 PKCE code_challenge 不匹配
+	authURL := env.authorize(t, "corp")
+	other := env.authorize(t, "corp")
+	code, _ := env.callback(t, "corp", env.provider.approve(t, other, carol), stateOf(t, authURL))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// state 属于其他提供方
+	authURL = env.authorize(t, "gh")
+	code, _ = env.callback(t, "corp", env.provider.approve(t, authURL, carol), stateOf(t, authURL))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// 用户在提供方拒绝授权
+	authURL = env.authorize(t, "corp")
+	code, _ = env.call(t, env.handler.Callback, "", map[string]string{"error": "access_denied", "state": stateOf(t, authURL)}, "provider", "corp")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = env.callback(t, "unknown", "code", "state")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, int64(2), env.countUsers(t))
+}
+
+func TestGitHubLinkAndLogin(t *testing.T) {
+	env := newTestEnv(t)
+	octo := fakeUser{sub: "1001", email: "octo@example.com", verified: true, name: "Octo", login: "octocat"}
+
+	// 未开启自动创建和邮箱关联时，未关联的账号不能登录
+	code, _ := env.login(t, "gh", octo)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// 用户 alice 登录后关联 GitHub 账号
+	code, data := env.call(t, env.handler.BeginLink, "u1", nil, "provider", "gh")
+	require.Equal(t, http.StatusOK, code)
+	authURL := data.(map[string]interface{})["authorize_url"].(string)
+
+	// 关联请求不能通过登录回调或其他用户完成
+	code, _ = env.callback(t, "gh", env.provider.approve(t, authURL, octo), stateOf(t, authURL))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, data = env.call(t, env.handler.BeginLink, "u1", nil, "provider", "gh")
+	require.Equal(t, http.StatusOK, code)
+	authURL = data.(map[string]interface{})["authorize_url"].(string)
+	code, _ = env.linkCallback(t, "u2", "gh", env.provider.approve(t, authURL, octo), stateOf(t, authURL))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, data = env.call(t, env.handler.BeginLink, "u1", nil, "provider", "gh")
+	require.Equal(t, http.StatusOK, code)
+	authURL = data.(map[string]interface{})["authorize_url"].(string)
+	code, data = env.linkCallback(t, "u1", "gh", env.provider.approve(t, authURL, octo), stateOf(t, authURL))
+	require.Equal(t, http.StatusOK, code, data)
+	assert.Equal(t, "gh", data.(map[string]interface{})["provider"])
+	assert.Equal(t, "octo@example.com", data.(map[string]interface{})["email"])
+
+	// 同一 GitHub 账号不能再关联给其他用户
+	code, data = env.call(t, env.handler.BeginLink, "u2", nil, "provider", "gh")
+	require.Equal(t, http.StatusOK, code)
+	authURL = data.(map[string]interface{})["authorize_url"].(string)
+	code, _ = env.linkCallback(t, "u2", "gh", env.provider.approve(t, authURL, octo), stateOf(t, authURL))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, data = env.login(t, "gh", octo)
+	require.Equal(t, http.StatusOK, code, data)
+	assert.Equal(t, "u1", data.(map[string]interface{})["user"].(map[string]interface{})["id"])
+
+	code, data = env.call(t, env.handler.ListIdentities, "u1", nil)
+	require.Equal(t, http.StatusOK, code)
+	identities := data.([]interface{})
+	require.Len(t, identities, 1)
+	id := identities[0].(map[string]interface{})["id"].(string)
+	assert.NotContains(t, identities[0], "subject")
+
+	code, _ = env.call(t, env.handler.DeleteIdentity, "u2", nil, "id", id)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = env.call(t, env.handler.DeleteIdentity, "u1", nil, "id", id)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = env.login(t, "gh", octo)
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestDeleteLastIdentityRequiresPassword(t *testing.T) {
+	env := newTestEnv(t)
+
+	code, _ := env.login(t, "corp", fakeUser{sub: "oidc-carol", email: "carol@example.com", verified: true})
+	require.Equal(t, http.StatusOK, code)
+	var identity CoreUserIdentity
+	require.NoError(t, env.db.Where("subject = ?", "oidc-carol").First(&identity).Error)
+
+	code, _ = env.call(t, env.handler.DeleteIdentity, identity.UserID, nil, "id", identity.ID)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,40 @@
+package auth_federated
+
+import (
+	"time"
+)
+
+// CoreUserIdentity 用户关联的第三方身份，同一提供方的同一用户只能关联一个本地用户
+type CoreUserIdentity struct {
+	ID          string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID      string     `gorm:"type:varchar(36);index" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(50);uniqueIndex:idx_identity_provider_subject" json:"provider"` // 提供方标识
+	Subject     string     `gorm:"type:varchar(255);uniqueIndex:idx_identity_provider_subject" json:"-"`       // 提供方的用户唯一标识
+	Email       string     `gorm:"type:varchar(100)" json:"email"`                                             // 提供方返回的邮箱，仅供展示
+	Name        string     `gorm:"type:varchar(100)" json:"name"`                                              // 提供方返回的用户名称，仅供展示
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`                                                    // 最近一次通过该身份登录的时间
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CoreUserIdentity) TableName() string {
+	return "core_user_identities"
+}
+
+// CoreFederatedState 跳转到提供方前保存的授权请求，回调时取出并删除
+type CoreFederatedState struct {
+	ID           string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex" json:"-"` // state 参数的 SHA-256
+	Provider     string    `gorm:"type:varchar(50)" json:"provider"`
+	UserID       string    `gorm:"type:varchar(36)" json:"user_id"` // 关联账号时为当前用户，登录时为空
+	CodeVerifier string    `gorm:"type:varchar(128)" json:"-"`      // PKCE code_verifier
+	Nonce        string    `gorm:"type:varchar(64)" json:"-"`       // OIDC nonce，防止 id_token 重放
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CoreFederatedState) TableName() string {
+	return "core_federated_states"
+}
@@ -0,0 +1,297 @@
+package auth_federated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"king-starter/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Identity 提供方返回的用户身份
+type Identity struct {
+	Subject       string // 提供方的用户唯一标识
+	Email         string
+	EmailVerified bool   // 提供方是否确认用户拥有该邮箱
+	Name          string // 显示名称
+	Username      string // 提供方的登录名，自动创建用户时作为用户名的候选
+}
+
+// Provider 身份提供方
+type Provider interface {
+	// AuthCodeURL 返回提供方的授权地址，携带 state、PKCE code_challenge 和 nonce
+	AuthCodeURL(ctx context.Context, state, verifier, nonce string) (string, error)
+	// Exchange 使用授权码和 PKCE code_verifier 换取令牌并获取用户身份
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// providerTimeout 请求提供方的超时时间
+const providerTimeout = 10 * time.Second
+
+// NewProvider 按配置创建身份提供方
+func NewProvider(cfg config.IdentityProviderConfig) (Provider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("name, client_id and redirect_url are required")
+	}
+	client := &http.Client{Timeout: providerTimeout}
+
+	switch cfg.Type {
+	case ProviderTypeGitHub:
+		return newGitHubProvider(cfg, client), nil
+	case ProviderTypeGoogle:
+		if cfg.Issuer == "" {
+			cfg.Issuer = googleIssuer
+		}
+		return newOIDCProvider(cfg, client), nil
+	case ProviderTypeOIDC:
+		if cfg.Issuer == "" {
+			return nil, errors.New("issuer is required for oidc provider")
+		}
+		return newOIDCProvider(cfg, client), nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type %q", cfg.Type)
+	}
+}
+
+// oidcProvider 通过 OpenID Connect Discovery 发现端点并校验 id_token 的提供方
+type oidcProvider struct {
+	cfg    config.IdentityProviderConfig
+	client *http.Client
+
+	// 首次使用时再获取元数据，避免提供方暂时不可用导致服务无法启动
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func newOIDCProvider(cfg config.IdentityProviderConfig, client *http.Client) *oidcProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oidcProvider{cfg: cfg, client: client}
+}
+
+// discover 获取并缓存提供方元数据，失败时下次请求重试
+func (p *oidcProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.provider, nil
+	}
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, p.client), p.cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	p.provider = provider
+	return provider, nil
+}
+
+func (p *oidcProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(provider).AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = oidc.ClientContext(ctx, p.client)
+
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("id_token missing from token response")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// 部分提供方只在用户信息端点返回邮箱
+	if claims.Email == "" && provider.UserInfoEndpoint() != "" {
+		info, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, err
+		}
+		if info.Subject != idToken.Subject {
+			return nil, errors.New("userinfo subject mismatch")
+		}
+		if err := info.Claims(&claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+// oidcClaims id_token 和用户信息端点中使用的标准声明
+type oidcClaims struct {
+	Email             string     `json:"email"`
+	EmailVerified     stringBool `json:"email_verified"`
+	Name              string     `json:"name"`
+	PreferredUsername string     `json:"preferred_username"`
+}
+
+// stringBool 兼容部分提供方以字符串 "true" 返回的布尔声明
+type stringBool bool
+
+func (b *stringBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null", "":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	return nil
+}
+
+// GitHub 默认端点
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+// githubProvider GitHub 不支持 OpenID Connect，通过 REST API 获取用户和已验证的邮箱
+type githubProvider struct {
+	oauth2 *oauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+func newGitHubProvider(cfg config.IdentityProviderConfig, client *http.Client) *githubProvider {
+	endpoint := oauth2.Endpoint{AuthURL: githubAuthURL, TokenURL: githubTokenURL}
+	if cfg.AuthURL != "" {
+		endpoint.AuthURL = cfg.AuthURL
+	}
+	if cfg.TokenURL != "" {
+		endpoint.TokenURL = cfg.TokenURL
+	}
+	apiURL := githubAPIURL
+	if cfg.APIURL != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL, "/")
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+	return &githubProvider{
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoint,
+			Scopes:       scopes,
+		},
+		apiURL: apiURL,
+		client: client,
+	}
+}
+
+func (p *githubProvider) AuthCodeURL(_ context.Context, state, verifier, _ string) (string, error) {
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, verifier, _ string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	client := p.oauth2.Client(ctx, token)
+
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, client, "/user", &profile); err != nil {
+		return nil, err
+	}
+	if profile.ID == 0 {
+		return nil, errors.New("github user id missing")
+	}
+
+	// 个人资料中的公开邮箱不一定经过验证，以邮箱接口返回的已验证主邮箱为准
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:  strconv.FormatInt(profile.ID, 10),
+		Name:     profile.Name,
+		Username: profile.Login,
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+			break
+		}
+	}
+	return identity, nil
+}
+
+// get 调用 GitHub REST API 并解析响应
+func (p *githubProvider) get(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github api %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
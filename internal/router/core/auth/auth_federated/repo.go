@@ -0,0 +1,114 @@
+package auth_federated
+
+import (
+	"context"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+
+	"gorm.io/gorm"
+)
+
+// Repository 第三方登录仓库
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建第三方登录仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateState 保存授权请求，顺便清理已过期的请求
+func (r *Repository) CreateState(ctx context.Context, state *CoreFederatedState) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&CoreFederatedState{}).Error; err != nil {
+		return err
+	}
+	return db.Create(state).Error
+}
+
+// TakeState 取出并删除授权请求，state 只能使用一次
+// 请求不存在、已过期、提供方不匹配或已被并发请求取走时返回 gorm.ErrRecordNotFound
+func (r *Repository) TakeState(ctx context.Context, stateHash, provider string) (*CoreFederatedState, error) {
+	var state CoreFederatedState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ? AND provider = ? AND expires_at > ?", stateHash, provider, time.Now()).First(&state).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", state.ID).Delete(&CoreFederatedState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetIdentity 根据提供方和提供方用户标识查询关联的身份
+func (r *Repository) GetIdentity(ctx context.Context, provider, subject string) (*CoreUserIdentity, error) {
+	var identity CoreUserIdentity
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateIdentity 关联第三方身份
+func (r *Repository) CreateIdentity(ctx context.Context, identity *CoreUserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// CreateUserWithIdentity 自动创建用户并关联第三方身份
+func (r *Repository) CreateUserWithIdentity(ctx context.Context, u *user.CoreUser, identity *CoreUserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		return tx.Create(identity).Error
+	})
+}
+
+// UpdateIdentityLogin 登录成功后更新提供方返回的资料和最近登录时间
+func (r *Repository) UpdateIdentityLogin(ctx context.Context, id, email, name string) error {
+	return r.db.WithContext(ctx).Model(&CoreUserIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"name":          name,
+			"last_login_at": time.Now(),
+		}).Error
+}
+
+// ListIdentitiesByUserID 查询用户关联的第三方身份
+func (r *Repository) ListIdentitiesByUserID(ctx context.Context, userID string) ([]CoreUserIdentity, error) {
+	var identities []CoreUserIdentity
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&identities).Error
+	return identities, err
+}
+
+// DeleteIdentity 解除用户关联的第三方身份，返回删除的数量
+func (r *Repository) DeleteIdentity(ctx context.Context, userID, id string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&CoreUserIdentity{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateLoginLog 创建登录日志
+func (r *Repository) CreateLoginLog(ctx context.Context, log *auth_password.CoreLoginLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
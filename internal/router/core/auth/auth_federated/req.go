@@ -0,0 +1,9 @@
+package auth_federated
+
+// CallbackReq 提供方回调参数，由前端回调页面原样提交
+type CallbackReq struct {
+	Code             string `json:"code" query:"code"`
+	State            string `json:"state" query:"state" validate:"required"`
+	Error            string `json:"error,omitempty" query:"error"`                         // 用户拒绝授权等情况下提供方返回的错误码
+	ErrorDescription string `json:"error_description,omitempty" query:"error_description"` // 提供方返回的错误描述
+}
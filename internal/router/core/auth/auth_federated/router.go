@@ -0,0 +1,55 @@
+package auth_federated
+
+import (
+	"time"
+
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/logx"
+)
+
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&CoreUserIdentity{},
+		&CoreFederatedState{},
+	)
+}
+
+// RegisterRoutes 注册第三方身份提供方登录路由
+func RegisterRoutes(app *app.App, secondFactor auth_password.SecondFactor) {
+	cfg := app.Config.Auth.Federation
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(app.Db.DB), role.NewRoleRepo(app.Db.DB), app.Jwt, app.Config.Auth.RequireEmailVerified, secondFactor, time.Duration(app.Config.Auth.TwoFA.ChallengeTTL)*time.Second)
+	handler := NewFederatedHandler(NewRepository(app.Db.DB), user.NewRepository(app.Db.DB), issuer, time.Duration(cfg.StateTTL)*time.Second)
+
+	// 配置不完整的提供方只记录警告，不影响其他登录方式
+	for _, providerCfg := range cfg.Providers {
+		provider, err := NewProvider(providerCfg)
+		if err != nil {
+			logx.Warn("skip federated identity provider", "name", providerCfg.Name, "error", err)
+			continue
+		}
+		handler.AddProvider(providerCfg, provider)
+	}
+
+	e := app.Server.Engine()
+
+	// 第三方登录
+	authGroup := e.Group("/api/core/auth/login/federated")
+	{
+		authGroup.GET("", handler.ListProviders)
+		authGroup.GET("/:provider", handler.Authorize)
+		authGroup.POST("/:provider/callback", handler.Callback)
+	}
+
+	// 当前用户管理关联的第三方账号
+	identityGroup := e.Group("/api/core/auth/identities", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		identityGroup.GET("", handler.ListIdentities)
+		identityGroup.POST("/:provider", handler.BeginLink)
+		identityGroup.POST("/:provider/callback", handler.LinkCallback)
+		identityGroup.DELETE("/:id", handler.DeleteIdentity)
+	}
+}
@@ -0,0 +1,10 @@
+package auth_federated
+
+const (
+	ProviderTypeGitHub string = "github" // GitHub（OAuth2 + REST API）
+	ProviderTypeGoogle string = "google" // Google（OpenID Connect）
+	ProviderTypeOIDC   string = "oidc"   // 通用 OpenID Connect 提供方
+)
+
+// googleIssuer Google 的 OpenID Connect 签发者
+const googleIssuer = "https://accounts.google.com"
@@ -0,0 +1,218 @@
+package auth_oauth2
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"king-starter/internal/response"
+	"king-starter/pkg/logx"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CreateClientReq 创建客户端请求参数
+type CreateClientReq struct {
+	ClientMetadata
+	IsPublic bool `json:"is_public"` // 公开客户端没有密钥，创建后不能修改
+}
+
+// ClientResp 客户端信息，不包含密钥
+type ClientResp struct {
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"` // 实际允许的授权类型
+	Scopes       []string  `json:"scopes"`      // 实际允许申请的权限范围
+	IsPublic     bool      `json:"is_public"`
+	RequirePKCE  bool      `json:"require_pkce"`
+	Status       int       `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewClientResp 将客户端转换为响应
+func NewClientResp(client *OAuthClient) ClientResp {
+	return ClientResp{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIList(),
+		GrantTypes:   client.AllowedGrantTypes(),
+		Scopes:       client.AllowedScopes(),
+		IsPublic:     client.IsPublic,
+		RequirePKCE:  client.RequirePKCE,
+		Status:       client.Status,
+		CreatedAt:    client.CreatedAt,
+		UpdatedAt:    client.UpdatedAt,
+	}
+}
+
+// ClientSecretResp 创建客户端或轮换密钥的响应，密钥明文只返回这一次
+type ClientSecretResp struct {
+	ClientResp
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// CreateClient 管理员创建客户端，机密客户端同时生成密钥
+func (h *OAuthHandler) CreateClient(c echo.Context) error {
+	var req CreateClientReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	client := &OAuthClient{
+		ID:       uuid.New().String(),
+		ClientID: uuid.New().String(),
+		Status:   1,
+		IsPublic: req.IsPublic,
+	}
+	if err := req.apply(client, h.redirectPolicy); err != nil {
+		return response.Error(c, http.StatusBadRequest, err.Error())
+	}
+
+	var secret string
+	if !client.IsPublic {
+		secret, client.ClientSecretHash = newClientSecret()
+	}
+
+	if err := h.repo.CreateClient(c.Request().Context(), client); err != nil {
+		logx.Error("failed to create oauth client", "error", err)
+		return response.Error(c, http.StatusInternalServerError, "创建客户端失败")
+	}
+
+	return response.SuccessWithMsg[any](c, "创建成功，请妥善保存客户端密钥，密钥不会再次显示", ClientSecretResp{
+		ClientResp:   NewClientResp(client),
+		ClientSecret: secret,
+	})
+}
+
+// ListClients 管理员分页查询客户端，支持按名称和状态筛选
+func (h *OAuthHandler) ListClients(c echo.Context) error {
+	var pq response.PageQuery
+	if err := c.Bind(&pq); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	var status *int
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		s, err := strconv.Atoi(statusStr)
+		if err != nil {
+			return response.Error(c, http.StatusBadRequest, "请求参数错误")
+		}
+		status = &s
+	}
+
+	result, err := h.repo.PageClients(c.Request().Context(), &pq, c.QueryParam("name"), status)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询失败")
+	}
+
+	items := make([]ClientResp, 0, len(result.Items))
+	for i := range result.Items {
+		items = append(items, NewClientResp(&result.Items[i]))
+	}
+	return response.SuccessPage(c, response.NewPageResult(items, result.Page, result.Size, result.Total))
+}
+
+// GetClient 管理员查询客户端详情
+func (h *OAuthHandler) GetClient(c echo.Context) error {
+	client, err := h.repo.GetClientByClientID(c.Request().Context(), c.Param("client_id"))
+	if err != nil {
+		return clientNotFound(c, err)
+	}
+	return response.Success[any](c, NewClientResp(client))
+}
+
+// UpdateClient 管理员更新客户端元数据，不影响密钥、状态和客户端类型
+func (h *OAuthHandler) UpdateClient(c echo.Context) error {
+	var req ClientMetadata
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+
+	client, err := h.repo.GetClientByClientID(ctx, c.Param("client_id"))
+	if err != nil {
+		return clientNotFound(c, err)
+	}
+	if err := req.apply(client, h.redirectPolicy); err != nil {
+		return response.Error(c, http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.repo.UpdateClient(ctx, client); err != nil {
+		logx.Error("failed to update oauth client", "client_id", client.ClientID, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "更新失败")
+	}
+	return response.SuccessWithMsg[any](c, "更新成功", NewClientResp(client))
+}
+
+// DisableClient 管理员禁用客户端，同时吊销其签发的所有令牌和未使用的授权码
+func (h *OAuthHandler) DisableClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	client, err := h.repo.GetClientByClientID(ctx, c.Param("client_id"))
+	if err != nil {
+		return clientNotFound(c, err)
+	}
+
+	if err := h.repo.UpdateClientStatus(ctx, client.ClientID, 0); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "禁用客户端失败")
+	}
+	if err := h.repo.DeleteClientGrants(ctx, client.ClientID); err != nil {
+		// 客户端已禁用，令牌端点和内省端点都会拒绝其令牌，这里只记录错误
+		logx.Error("failed to delete oauth client grants", "client_id", client.ClientID, "error", err)
+	}
+	return response.SuccessWithMsg[any](c, "客户端已禁用", nil)
+}
+
+// EnableClient 管理员重新启用客户端
+func (h *OAuthHandler) EnableClient(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	client, err := h.repo.GetClientByClientID(ctx, c.Param("client_id"))
+	if err != nil {
+		return clientNotFound(c, err)
+	}
+
+	if err := h.repo.UpdateClientStatus(ctx, client.ClientID, 1); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "启用客户端失败")
+	}
+	return response.SuccessWithMsg[any](c, "客户端已启用", nil)
+}
+
+// RotateClientSecret 管理员轮换客户端密钥，旧密钥立即失效，已签发的令牌不受影响
+func (h *OAuthHandler) RotateClientSecret(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	client, err := h.repo.GetClientByClientID(ctx, c.Param("client_id"))
+	if err != nil {
+		return clientNotFound(c, err)
+	}
+	if client.IsPublic {
+		return response.Error(c, http.StatusBadRequest, "公开客户端没有密钥")
+	}
+
+	secret, hash := newClientSecret()
+	if err := h.repo.UpdateClientSecret(ctx, client.ClientID, hash); err != nil {
+		logx.Error("failed to rotate oauth client secret", "client_id", client.ClientID, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "轮换密钥失败")
+	}
+	client.ClientSecretHash = hash
+
+	return response.SuccessWithMsg[any](c, "密钥已轮换，请妥善保存新密钥，密钥不会再次显示", ClientSecretResp{
+		ClientResp:   NewClientResp(client),
+		ClientSecret: secret,
+	})
+}
+
+// clientNotFound 将查询客户端的错误转换为响应
+func clientNotFound(c echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusNotFound, "客户端不存在")
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询客户端失败")
+}
@@ -0,0 +1,211 @@
+package auth_oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"king-starter/internal/testutil"
+	"king-starter/pkg/goutils/cryptoutil"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// admin 调用客户端管理接口，返回原始响应
+func (env *testEnv) admin(t *testing.T, h echo.HandlerFunc, method, clientID string, body interface{}) *httptest.ResponseRecorder {
+	var reader *strings.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = strings.NewReader(string(raw))
+	} else {
+		reader = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, "/", reader)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	c.SetParamNames("client_id")
+	c.SetParamValues(clientID)
+	require.NoError(t, h(c))
+	return rec
+}
+
+func TestClientAdmin(t *testing.T) {
+	env := newTestEnv(t)
+
+	status, data := decode(t, env.admin(t, env.handler.CreateClient, http.MethodPost, "", map[string]interface{}{
+		"name":          "Reports",
+		"redirect_uris": []string{"https://reports.example.com/cb", "com.example.reports:/cb", "https://reports.example.com/cb"},
+		"grant_types":   []string{"authorization_code", "client_credentials"},
+		"scopes":        []string{"reports:read"},
+	}))
+	require.Equal(t, http.StatusOK, status)
+	clientID := data["client_id"].(string)
+	secret := data["client_secret"].(string)
+	require.NotEmpty(t, secret)
+	assert.Equal(t, []interface{}{"https://reports.example.com/cb", "com.example.reports:/cb"}, data["redirect_uris"])
+
+	// 只保存密钥摘要
+	var stored OAuthClient
+	require.NoError(t, env.db.Where("client_id = ?", clientID).First(&stored).Error)
+	assert.Equal(t, cryptoutil.SHA256Hex(secret), stored.ClientSecretHash)
+
+	// 详情和列表不返回密钥
+	rec := env.admin(t, env.handler.GetClient, http.MethodGet, clientID, nil)
+	assert.NotContains(t, rec.Body.String(), "client_secret")
+	assert.NotContains(t, rec.Body.String(), stored.ClientSecretHash)
+	rec = env.admin(t, env.handler.ListClients, http.MethodGet, "", nil)
+	var page struct {
+		Data struct {
+			Items []ClientResp `json:"items"`
+			Total int64        `json:"total"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.EqualValues(t, 6, page.Data.Total)
+	assert.NotContains(t, rec.Body.String(), "client_secret")
+
+	// 只能申请允许的权限范围
+	status, tokenData := env.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read"}}, clientID, secret)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = env.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read users:write"}}, clientID, secret)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 轮换密钥后旧密钥失效
+	status, data = decode(t, env.admin(t, env.handler.RotateClientSecret, http.MethodPost, clientID, nil))
+	require.Equal(t, http.StatusOK, status)
+	newSecret := data["client_secret"].(string)
+	assert.NotEqual(t, secret, newSecret)
+	status, data = env.token(t, url.Values{"grant_type": {"client_credentials"}}, clientID, secret)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", data["error"])
+	status, _ = env.token(t, url.Values{"grant_type": {"client_credentials"}}, clientID, newSecret)
+	assert.Equal(t, http.StatusOK, status)
+
+	// 更新元数据
+	status, data = decode(t, env.admin(t, env.handler.UpdateClient, http.MethodPut, clientID, map[string]interface{}{
+		"name":          "Reports v2",
+		"redirect_uris": []string{"https://reports.example.com/v2"},
+		"scopes":        []string{"reports:read", "reports:write"},
+	}))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Reports v2", data["name"])
+	assert.Equal(t, []interface{}{"authorization_code", "refresh_token"}, data["grant_types"])
+	status, _ = decode(t, env.admin(t, env.handler.UpdateClient, http.MethodPut, clientID, map[string]interface{}{
+		"name":          "Reports v2",
+		"redirect_uris": []string{"https://reports.example.com/cb#fragment"},
+	}))
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 禁用后令牌失效
+	status, _ = decode(t, env.admin(t, env.handler.DisableClient, http.MethodPost, clientID, nil))
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, env.introspect(t, tokenData["access_token"].(string)).Active)
+	status, _ = env.token(t, url.Values{"grant_type": {"client_credentials"}}, clientID, newSecret)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = decode(t, env.admin(t, env.handler.EnableClient, http.MethodPost, clientID, nil))
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = decode(t, env.admin(t, env.handler.GetClient, http.MethodGet, "unknown", nil))
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestCreateClientValidation(t *testing.T) {
+	env := newTestEnv(t)
+
+	cases := []map[string]interface{}{
+		{"redirect_uris": []string{"https://a.example.com/cb"}}, // 缺少名称
+		{"name": "A"}, // 授权码模式缺少回调地址
+		{"name": "A", "redirect_uris": []string{"/relative"}},           // 相对地址
+		{"name": "A", "redirect_uris": []string{"javascript:alert(1)"}}, // 脚本协议
+		{"name": "A", "redirect_uris": []string{"data:text/html,<script>alert(1)</script>"}},
+		{"name": "A", "redirect_uris": []string{"vbscript:msgbox(1)"}},
+		{"name": "A", "redirect_uris": []string{"http://a.example.com/cb"}},             // 非回环地址不能使用 http
+		{"name": "A", "redirect_uris": []string{"com.evil.app:/cb"}},                    // 未登记的自定义协议
+		{"name": "A", "grant_types": []string{"implicit"}},                              // 不支持的授权类型
+		{"name": "A", "is_public": true, "grant_types": []string{"client_credentials"}}, // 公开客户端不能使用客户端凭证
+	}
+	for _, body := range cases {
+		status, _ := decode(t, env.admin(t, env.handler.CreateClient, http.MethodPost, "", body))
+		assert.Equal(t, http.StatusBadRequest, status, body)
+	}
+
+	// http 只允许回环地址
+	status, data := decode(t, env.admin(t, env.handler.CreateClient, http.MethodPost, "", map[string]interface{}{
+		"name": "CLI", "is_public": true, "redirect_uris": []string{"http://127.0.0.1:8400/cb", "http://[::1]:8400/cb", "http://localhost/cb"},
+	}))
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, data["redirect_uris"], 3)
+
+	// 公开客户端没有密钥
+	status, data = decode(t, env.admin(t, env.handler.CreateClient, http.MethodPost, "", map[string]interface{}{
+		"name": "SPA", "is_public": true, "redirect_uris": []string{"https://spa.example.com/cb"},
+	}))
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, data, "client_secret")
+	status, _ = decode(t, env.admin(t, env.handler.RotateClientSecret, http.MethodPost, data["client_id"].(string), nil))
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAuthorizeRedirectURIs(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 回调地址与登记的任意一个完全一致即可
+	params := url.Values{"client_id": {"web"}, "response_type": {"code"}, "redirect_uri": {"https://app.example.com/alt"}}
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+env.bearer)
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.Authorize(env.e.NewContext(req, rec)))
+	status, data := decode(t, rec)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["consent_required"])
+
+	params.Set("redirect_uri", "https://app.example.com/alt/other")
+	req = httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+env.bearer)
+	rec = httptest.NewRecorder()
+	require.NoError(t, env.handler.Authorize(env.e.NewContext(req, rec)))
+	status, _ = decode(t, rec)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+// legacyOAuthClient 旧版客户端表结构
+type legacyOAuthClient struct {
+	ID           string `gorm:"primaryKey;type:varchar(36)"`
+	ClientID     string `gorm:"type:varchar(100);uniqueIndex"`
+	ClientSecret string `gorm:"type:varchar(255)"`
+	Name         string `gorm:"type:varchar(100)"`
+	RedirectURI  string `gorm:"type:varchar(255)"`
+	Status       int    `gorm:"type:tinyint;default:1"`
+}
+
+func (legacyOAuthClient) TableName() string {
+	return "core_user_oauth_clients"
+}
+
+func TestMigrateLegacyClients(t *testing.T) {
+	db := testutil.NewDB(t, &legacyOAuthClient{})
+	require.NoError(t, db.Create(&legacyOAuthClient{
+		ID: "c1", ClientID: "legacy", ClientSecret: "plain-secret", Name: "Legacy", RedirectURI: "https://legacy.example.com/cb", Status: 1,
+	}).Error)
+	require.NoError(t, db.AutoMigrate(&OAuthClient{}))
+
+	repo := NewRepository(db)
+	require.NoError(t, repo.MigrateLegacyClients(context.Background()))
+	// 重复执行不报错
+	require.NoError(t, repo.MigrateLegacyClients(context.Background()))
+
+	assert.False(t, db.Migrator().HasColumn(&OAuthClient{}, "client_secret"))
+	assert.False(t, db.Migrator().HasColumn(&OAuthClient{}, "redirect_uri"))
+	client, err := repo.GetClientByClientID(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.True(t, client.CheckSecret("plain-secret"))
+	assert.True(t, client.AllowsRedirectURI("https://legacy.example.com/cb"))
+}
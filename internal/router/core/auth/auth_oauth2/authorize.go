@@ -0,0 +1,309 @@
+package auth_oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"king-starter/internal/response"
+	auth_password2 "king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/jwt"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// OAuthAuthorizeReq OAuth 授权请求参数
+type OAuthAuthorizeReq struct {
+	ClientID     string `query:"client_id" json:"client_id" validate:"required"`
+	RedirectURI  string `query:"redirect_uri" json:"redirect_uri" validate:"required"`
+	ResponseType string `query:"response_type" json:"response_type" validate:"required,oneof=code"`
+	Scope        string `query:"scope" json:"scope"`
+	State        string `query:"state" json:"state"`
+	Prompt       string `query:"prompt" json:"prompt"` // 空格分隔，consent：即使已授权过也要求用户重新确认；login：要求用户重新登录
+	Nonce        string `query:"nonce" json:"nonce"`   // OpenID Connect 请求的随机值，原样写入 id_token
+	// PKCE（RFC 7636），公开客户端必须提供
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// OAuthConsentReq 用户确认授权请求参数，携带原始授权请求参数
+type OAuthConsentReq struct {
+	OAuthAuthorizeReq
+	Approve bool `json:"approve"` // 是否同意授权
+}
+
+// authorizeError 授权请求参数错误
+// 回调地址校验通过之前不能重定向到客户端，只能直接返回错误
+type authorizeError struct {
+	msg string
+}
+
+func (e *authorizeError) Error() string {
+	return e.msg
+}
+
+// Authorize 授权接口
+//
+// 浏览器直接访问时（未携带访问令牌）跳转到前端授权页，前端完成登录后携带访问令牌以相同参数再次请求：
+//   - 用户已授权过请求的全部权限范围时直接签发授权码，返回回调地址
+//   - 否则返回客户端和权限范围信息，由前端展示授权确认页，用户确认后调用 Consent
+func (h *OAuthHandler) Authorize(c echo.Context) error {
+	var req OAuthAuthorizeReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+
+	client, err := h.validateAuthorize(ctx, &req)
+	if err != nil {
+		return authorizeErrorResponse(c, err)
+	}
+
+	u, authTime, err := h.sessionUser(c)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u == nil {
+		if h.consentURL != "" {
+			return c.Redirect(http.StatusFound, withQuery(h.consentURL, c.QueryParams()))
+		}
+		return response.Error(c, http.StatusUnauthorized, "请先登录")
+	}
+
+	// prompt=login 要求用户重新登录，登录时间超过 loginMaxAge 时由前端重新登录后以相同参数再次请求
+	if hasPrompt(req.Prompt, PromptLogin) && time.Since(authTime) > h.loginMaxAge {
+		return response.Success[any](c, map[string]interface{}{
+			"login_required": true,
+		})
+	}
+
+	// 已授权过相同或更大的权限范围时跳过确认
+	if !hasPrompt(req.Prompt, PromptConsent) {
+		consent, err := h.repo.GetConsent(ctx, u.ID, client.ClientID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusInternalServerError, "查询授权记录失败")
+		}
+		if consent != nil && scopeCovers(consent.Scope, req.Scope) {
+			return h.issueCode(c, &req, client, u, authTime)
+		}
+	}
+
+	return response.Success[any](c, map[string]interface{}{
+		"consent_required": true,
+		"client": map[string]interface{}{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"scopes":        parseScope(req.Scope),
+		"scope_details": describeScopes(req.Scope),
+	})
+}
+
+// Consent 用户确认或拒绝授权，返回需要跳转的客户端回调地址
+func (h *OAuthHandler) Consent(c echo.Context) error {
+	var req OAuthConsentReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+
+	client, err := h.validateAuthorize(ctx, &req.OAuthAuthorizeReq)
+	if err != nil {
+		return authorizeErrorResponse(c, err)
+	}
+
+	u, err := h.userRepo.GetByID(ctx, echoutil.GetUserID(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	authTime := loginTime(middleware.GetClaims(c))
+
+	// 用户拒绝时按 RFC 6749 4.1.2.1 携带 access_denied 跳回客户端
+	if !req.Approve {
+		params := url.Values{"error": {"access_denied"}}
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		return response.Success[any](c, map[string]interface{}{
+			"redirect_to": withQuery(req.RedirectURI, params),
+		})
+	}
+
+	if err := h.repo.SaveConsent(ctx, u.ID, client.ClientID, req.Scope); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "保存授权记录失败")
+	}
+	return h.issueCode(c, &req.OAuthAuthorizeReq, client, u, authTime)
+}
+
+// validateAuthorize 校验授权请求参数，校验通过后 CodeChallengeMethod 被规范化
+func (h *OAuthHandler) validateAuthorize(ctx context.Context, req *OAuthAuthorizeReq) (*OAuthClient, error) {
+	if req.ClientID == "" || req.RedirectURI == "" {
+		return nil, &authorizeError{msg: "请求参数错误"}
+	}
+
+	// 验证客户端是否存在
+	client, err := h.repo.GetClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &authorizeError{msg: "无效的客户端ID"}
+		}
+		return nil, err
+	}
+
+	// 检查客户端状态
+	if client.Status != 1 {
+		return nil, &authorizeError{msg: "客户端已被禁用"}
+	}
+
+	// 检查客户端是否允许授权码模式
+	if !client.AllowsGrant(GrantTypeAuthorizationCode) {
+		return nil, &authorizeError{msg: "客户端不允许使用授权码模式"}
+	}
+
+	// 验证回调地址，必须与登记的某个回调地址完全一致
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, &authorizeError{msg: "回调地址不匹配"}
+	}
+
+	if req.ResponseType != "code" {
+		return nil, &authorizeError{msg: "不支持的 response_type"}
+	}
+
+	if !client.AllowsScope(req.Scope) {
+		return nil, &authorizeError{msg: "请求的权限范围超出客户端允许的范围"}
+	}
+
+	// 校验 PKCE 参数
+	challengeMethod, ok := normalizePKCEMethod(req.CodeChallengeMethod)
+	switch {
+	case req.CodeChallenge == "":
+		if pkceRequired(client) {
+			return nil, &authorizeError{msg: "该客户端必须使用 PKCE"}
+		}
+		challengeMethod = ""
+	case !ok:
+		return nil, &authorizeError{msg: "不支持的 code_challenge_method"}
+	case !pkcePattern.MatchString(req.CodeChallenge):
+		return nil, &authorizeError{msg: "code_challenge 格式错误"}
+	}
+	req.CodeChallengeMethod = challengeMethod
+
+	return client, nil
+}
+
+// authorizeErrorResponse 将 validateAuthorize 返回的错误转换为响应
+func authorizeErrorResponse(c echo.Context, err error) error {
+	var authErr *authorizeError
+	if errors.As(err, &authErr) {
+		return response.Error(c, http.StatusBadRequest, authErr.msg)
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询客户端失败")
+}
+
+// sessionUser 从 Authorization 头解析当前登录用户，同时返回登录时间（访问令牌的 auth_time）
+// 授权端点允许浏览器直接访问，未携带或携带无效的访问令牌、用户不存在或已禁用时均视为未登录，返回 nil
+func (h *OAuthHandler) sessionUser(c echo.Context) (*user.CoreUser, time.Time, error) {
+	tokenString, ok := middleware.BearerToken(c)
+	if !ok {
+		return nil, time.Time{}, nil
+	}
+	ctx := c.Request().Context()
+	claims, err := h.jwt.ParseTokenWithContext(ctx, tokenString)
+	if err != nil {
+		return nil, time.Time{}, nil
+	}
+	u, err := h.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, time.Time{}, nil
+		}
+		return nil, time.Time{}, err
+	}
+	if u.Status == 0 {
+		return nil, time.Time{}, nil
+	}
+	return u, loginTime(claims), nil
+}
+
+// loginTime 返回访问令牌记录的用户登录时间
+// 刷新令牌换取的访问令牌签发时间会更新，不能代表登录时间；没有 auth_time 的令牌视为登录时间未知
+func loginTime(claims *jwt.CustomClaims) time.Time {
+	if claims == nil || claims.AuthTime == nil {
+		return time.Time{}
+	}
+	return claims.AuthTime.Time
+}
+
+// issueCode 为用户签发授权码，返回携带授权码的客户端回调地址
+func (h *OAuthHandler) issueCode(c echo.Context, req *OAuthAuthorizeReq, client *OAuthClient, u *user.CoreUser, authTime time.Time) error {
+	code := uuid.New().String()
+
+	authCode := &OAuthCode{
+		ID:          uuid.New().String(),
+		ClientID:    client.ClientID,
+		UserID:      u.ID,
+		Code:        code,
+		RedirectURI: req.RedirectURI,
+		Scope:       joinScope(parseScope(req.Scope)),
+		ExpiresAt:   time.Now().Add(h.codeTTL),
+
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+
+		Nonce:    req.Nonce,
+		AuthTime: authTime,
+	}
+
+	if err := h.repo.CreateOAuthCode(c.Request().Context(), authCode); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "创建授权码失败")
+	}
+
+	// 记录 OAuth2 授权成功日志
+	log := &auth_password2.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Username:  u.Username,
+		AuthType:  auth_password2.AuthTypeOAuth2,
+		LoginType: auth_password2.LoginTypeSuccess,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   "OAuth2 授权成功，客户端: " + client.ClientID,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return response.Success[any](c, map[string]interface{}{
+		"redirect_to": withQuery(req.RedirectURI, params),
+	})
+}
+
+// withQuery 在地址上追加查询参数，保留地址中原有的参数
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for k, vs := range params {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
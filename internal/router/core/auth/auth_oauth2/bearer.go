@@ -0,0 +1,138 @@
+package auth_oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/http/middleware"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// TokenKey 上下文中保存已校验的 OAuth2 访问令牌的键
+const TokenKey = "oauth2_token"
+
+// errInvalidToken 访问令牌不存在、已过期，或其客户端、用户已被禁用
+var errInvalidToken = errors.New("invalid access token")
+
+// BearerAuthMiddleware 校验 OAuth2 访问令牌，供第三方应用调用的资源接口使用
+// 校验通过后将令牌保存到上下文，令牌关联用户时同时设置当前用户ID；scopes 为令牌必须包含的权限范围。
+// 同一路由组内所需权限范围不同时，在路由上使用 RequireScopes：
+//
+//	g := e.Group("/api/open", auth_oauth2.BearerAuthMiddleware(app.Db.DB))
+//	g.GET("/users", handler.ListUsers, auth_oauth2.RequireScopes("users:read"))
+func BearerAuthMiddleware(db *gorm.DB, scopes ...string) echo.MiddlewareFunc {
+	repo := NewRepository(db)
+	userRepo := user.NewRepository(db)
+	requireScopes := RequireScopes(scopes...)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		checked := requireScopes(next)
+		return func(c echo.Context) error {
+			accessToken, ok := middleware.BearerToken(c)
+			if !ok {
+				return bearerError(c, http.StatusUnauthorized, "", "")
+			}
+
+			token, err := activeAccessToken(c.Request().Context(), repo, userRepo, accessToken)
+			if err != nil {
+				if errors.Is(err, errInvalidToken) {
+					return bearerError(c, http.StatusUnauthorized, "invalid_token", "无效或已过期的访问令牌")
+				}
+				return oauthError(c, http.StatusInternalServerError, "server_error", "验证令牌失败")
+			}
+
+			c.Set(TokenKey, token)
+			if token.UserID != "" {
+				echoutil.SetUserID(c, token.UserID)
+			}
+			return checked(c)
+		}
+	}
+}
+
+// RequireScopes 要求 OAuth2 访问令牌包含全部指定的权限范围，需在 BearerAuthMiddleware 之后使用
+// 权限范围必须已登记，否则注册路由时 panic，避免拼写错误导致接口始终无法访问
+func RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	for _, scope := range scopes {
+		if !scopeRegistered(scope) {
+			panic("oauth2: unregistered scope " + scope)
+		}
+	}
+	required := joinScope(parseScope(joinScope(scopes)))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := GetAccessToken(c)
+			if token == nil {
+				return bearerError(c, http.StatusUnauthorized, "", "")
+			}
+			if !scopeCovers(token.Scope, required) {
+				return insufficientScope(c, required)
+			}
+			return next(c)
+		}
+	}
+}
+
+// GetAccessToken 获取 BearerAuthMiddleware 保存的访问令牌，未经过该中间件时返回 nil
+func GetAccessToken(c echo.Context) *OAuthToken {
+	if token, ok := c.Get(TokenKey).(*OAuthToken); ok {
+		return token
+	}
+	return nil
+}
+
+// activeAccessToken 查询访问令牌并确认其仍然有效
+func activeAccessToken(ctx context.Context, repo *Repository, userRepo *user.Repository, accessToken string) (*OAuthToken, error) {
+	token, err := repo.GetOAuthTokenByAccessToken(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidToken
+		}
+		return nil, err
+	}
+	if token.ExpiresAt.Before(time.Now()) {
+		return nil, errInvalidToken
+	}
+
+	// 禁用客户端时会删除其令牌，这里再次确认，避免删除失败时令牌仍可使用
+	client, err := repo.GetClientByClientID(ctx, token.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidToken
+		}
+		return nil, err
+	}
+	if client.Status != 1 {
+		return nil, errInvalidToken
+	}
+
+	if token.UserID != "" {
+		u, err := userRepo.GetByID(ctx, token.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errInvalidToken
+			}
+			return nil, err
+		}
+		if u.Status == 0 {
+			return nil, errInvalidToken
+		}
+	}
+	return token, nil
+}
+
+// insufficientScope 按 RFC 6750 3.1 返回权限范围不足，scope 为访问该资源需要的权限范围
+func insufficientScope(c echo.Context, scope string) error {
+	c.Response().Header().Set("WWW-Authenticate", `Bearer realm="oauth", error="insufficient_scope", scope="`+scope+`"`)
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"error":             "insufficient_scope",
+		"error_description": "访问令牌缺少权限范围: " + scope,
+	})
+}
@@ -0,0 +1,99 @@
+package auth_oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/pkg/goutils/echoutil"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resource 使用 BearerAuthMiddleware 保护的资源接口，返回响应和当前用户ID
+func (env *testEnv) resource(t *testing.T, accessToken string, scopes ...string) (*httptest.ResponseRecorder, string) {
+	var userID string
+	handler := BearerAuthMiddleware(env.db, scopes...)(func(c echo.Context) error {
+		userID = echoutil.GetUserID(c)
+		require.NotNil(t, GetAccessToken(c))
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/open/reports", nil)
+	if accessToken != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler(env.e.NewContext(req, rec)))
+	return rec, userID
+}
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	env := newTestEnv(t)
+
+	status, data := env.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read"}}, "service", "svc-secret")
+	require.Equal(t, http.StatusOK, status)
+	accessToken := data["access_token"].(string)
+
+	rec, _ := env.resource(t, accessToken, "reports:read")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// 令牌缺少所需权限范围
+	rec, _ = env.resource(t, accessToken, "reports:read", "reports:write")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `scope="reports:read reports:write"`)
+
+	// 未携带或携带无效令牌
+	rec, _ = env.resource(t, "", "reports:read")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec, _ = env.resource(t, "unknown", "reports:read")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	// 客户端被禁用后令牌失效
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "service").Update("status", 0).Error)
+	rec, _ = env.resource(t, accessToken)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestBearerAuthMiddlewareUser(t *testing.T) {
+	env := newTestEnv(t)
+
+	accessToken, _ := env.passwordTokens(t)
+
+	rec, userID := env.resource(t, accessToken, ScopeProfile)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "u1", userID)
+
+	// 过期令牌
+	require.NoError(t, env.db.Model(&OAuthToken{}).Where("access_token = ?", accessToken).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	rec, _ = env.resource(t, accessToken)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestScopeRegistry(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 路由要求未登记的权限范围时启动失败
+	assert.Panics(t, func() { RequireScopes("reports:delete") })
+	assert.Panics(t, func() { RegisterScope("bad scope", "") })
+
+	// 配置文件中的权限范围登记后即可用于路由
+	RegisterConfigScopes([]config.ScopeConfig{{Name: "reports:export", Description: "导出报表"}})
+	assert.NotPanics(t, func() { RequireScopes("reports:export") })
+
+	// 未配置权限范围的客户端只能申请 OIDC 标准权限范围
+	status, _ := env.token(t, url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"Passw0rd!"}, "scope": {"reports:read"}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.ListScopes(env.e.NewContext(httptest.NewRequest(http.MethodGet, "/api/core/auth/oauth/scopes", nil), rec)))
+	assert.Contains(t, rec.Body.String(), `"reports:read"`)
+	assert.Contains(t, rec.Body.String(), `"openid"`)
+}
@@ -0,0 +1,191 @@
+package auth_oauth2
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"king-starter/pkg/goutils/cryptoutil"
+)
+
+// supportedGrantTypes 授权服务支持的授权类型
+var supportedGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypePassword, GrantTypeClientCredentials, GrantTypeDeviceCode}
+
+// newClientSecret 生成客户端密钥，返回明文和摘要
+func newClientSecret() (string, string) {
+	secret := cryptoutil.RandomToken(32)
+	return secret, cryptoutil.SHA256Hex(secret)
+}
+
+// CheckSecret 校验客户端密钥，公开客户端没有密钥，始终返回 false
+func (c *OAuthClient) CheckSecret(secret string) bool {
+	if secret == "" || c.ClientSecretHash == "" {
+		return false
+	}
+	return cryptoutil.EqualHash(secret, c.ClientSecretHash)
+}
+
+// RedirectURIList 返回客户端允许的回调地址
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// AllowsRedirectURI 判断回调地址是否与客户端登记的某个回调地址完全一致
+func (c *OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	return redirectURI != "" && slices.Contains(c.RedirectURIList(), redirectURI)
+}
+
+// AllowedScopes 返回客户端允许申请的权限范围，未设置时为 OpenID Connect 标准权限范围
+func (c *OAuthClient) AllowedScopes() []string {
+	if c.Scopes == "" {
+		return slices.Clone(standardScopes)
+	}
+	return parseScope(c.Scopes)
+}
+
+// AllowsScope 判断请求的权限范围是否都已登记，且都在客户端允许的范围内
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	allowed := c.AllowedScopes()
+	for _, s := range parseScope(scope) {
+		if !scopeRegistered(s) || !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// RestrictScope 去掉客户端当前不再允许或已取消登记的权限范围
+// 客户端的权限范围被管理员收窄后，已签发的刷新令牌不能再换取被移除的权限范围
+func (c *OAuthClient) RestrictScope(scope string) string {
+	allowed := c.AllowedScopes()
+	var kept []string
+	for _, s := range parseScope(scope) {
+		if scopeRegistered(s) && slices.Contains(allowed, s) {
+			kept = append(kept, s)
+		}
+	}
+	return joinScope(kept)
+}
+
+// clientMetadataError 客户端元数据校验失败
+// code 为 RFC 7591 3.2.2 定义的错误码，供动态注册端点直接返回
+type clientMetadataError struct {
+	code string
+	msg  string
+}
+
+func (e *clientMetadataError) Error() string {
+	return e.msg
+}
+
+// ClientMetadata 客户端元数据，由管理接口和动态注册共用
+type ClientMetadata struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"` // 为空时只允许授权码和刷新令牌
+	Scopes       []string `json:"scopes"`      // 必须是已登记的权限范围，为空时只允许 OpenID Connect 标准权限范围
+	RequirePKCE  bool     `json:"require_pkce"`
+}
+
+// apply 校验元数据并写入客户端，客户端的 IsPublic 需事先设置
+func (m *ClientMetadata) apply(client *OAuthClient, policy redirectPolicy) error {
+	name := strings.TrimSpace(m.Name)
+	if name == "" {
+		return &clientMetadataError{code: "invalid_client_metadata", msg: "客户端名称不能为空"}
+	}
+
+	var redirectURIs []string
+	for _, raw := range m.RedirectURIs {
+		if !policy.allows(raw) {
+			return &clientMetadataError{code: "invalid_redirect_uri", msg: "无效的回调地址: " + raw}
+		}
+		if !slices.Contains(redirectURIs, raw) {
+			redirectURIs = append(redirectURIs, raw)
+		}
+	}
+
+	var grantTypes []string
+	for _, grantType := range m.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "不支持的授权类型: " + grantType}
+		}
+		if client.IsPublic && grantType == GrantTypeClientCredentials {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "公开客户端不能使用客户端凭证模式"}
+		}
+		if !slices.Contains(grantTypes, grantType) {
+			grantTypes = append(grantTypes, grantType)
+		}
+	}
+	effective := grantTypes
+	if len(effective) == 0 {
+		effective = defaultGrantTypes
+	}
+	if slices.Contains(effective, GrantTypeAuthorizationCode) && len(redirectURIs) == 0 {
+		return &clientMetadataError{code: "invalid_redirect_uri", msg: "授权码模式至少需要一个回调地址"}
+	}
+
+	for _, scope := range m.Scopes {
+		if !scopeRegistered(scope) {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "未登记的权限范围: " + scope}
+		}
+	}
+
+	client.Name = name
+	client.RedirectURIs = strings.Join(redirectURIs, " ")
+	client.GrantTypes = strings.Join(grantTypes, " ")
+	client.Scopes = joinScope(parseScope(strings.Join(m.Scopes, " ")))
+	client.RequirePKCE = m.RequirePKCE
+	return nil
+}
+
+// redirectPolicy 回调地址校验规则
+// 授权确认后前端会跳转到回调地址，因此只允许 https；http 只允许回环地址（RFC 8252 7.3），
+// 原生应用的自定义协议（RFC 8252 7.1）需要在配置中登记
+type redirectPolicy struct {
+	loopback      bool     // 是否允许回环地址使用 http
+	customSchemes []string // 允许的自定义协议
+}
+
+// newRedirectPolicy 管理员创建的客户端使用的回调地址校验规则
+func newRedirectPolicy(customSchemes []string) redirectPolicy {
+	policy := redirectPolicy{loopback: true}
+	for _, scheme := range customSchemes {
+		policy.customSchemes = append(policy.customSchemes, strings.ToLower(scheme))
+	}
+	return policy
+}
+
+// blockedRedirectSchemes 可在授权服务页面执行脚本或读取本地内容的协议，即使登记为自定义协议也不允许
+var blockedRedirectSchemes = []string{"javascript", "data", "vbscript", "file", "blob"}
+
+// allows 回调地址必须是不含片段的绝对地址（RFC 6749 3.1.2），且协议符合规则
+func (p redirectPolicy) allows(raw string) bool {
+	if strings.ContainsAny(raw, " \t\r\n#") {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch {
+	case slices.Contains(blockedRedirectSchemes, scheme):
+		return false
+	case scheme == "https":
+		return u.Host != ""
+	case scheme == "http":
+		return p.loopback && isLoopbackHost(u.Hostname())
+	default:
+		return slices.Contains(p.customSchemes, scheme)
+	}
+}
+
+// isLoopbackHost 判断是否为回环地址
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,79 @@
+package auth_oauth2
+
+import (
+	"net/http"
+	"time"
+
+	"king-starter/internal/response"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/logx"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuthorizedAppResp 用户已授权的第三方应用
+type AuthorizedAppResp struct {
+	ClientID     string    `json:"client_id"`     // 客户端ID
+	Name         string    `json:"name"`          // 应用名称
+	Scopes       []string  `json:"scopes"`        // 已授权的权限范围
+	AuthorizedAt time.Time `json:"authorized_at"` // 首次授权时间
+	UpdatedAt    time.Time `json:"updated_at"`    // 最近一次授权时间
+}
+
+// ListMyAuthorizations 获取当前用户已授权的第三方应用
+func (h *OAuthHandler) ListMyAuthorizations(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	consents, err := h.repo.ListConsentsByUserID(ctx, echoutil.GetUserID(c))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询授权记录失败")
+	}
+
+	clientIDs := make([]string, 0, len(consents))
+	for _, consent := range consents {
+		clientIDs = append(clientIDs, consent.ClientID)
+	}
+	names := make(map[string]string, len(consents))
+	if len(clientIDs) > 0 {
+		clients, err := h.repo.ListClientsByClientIDs(ctx, clientIDs)
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "查询客户端失败")
+		}
+		for _, client := range clients {
+			names[client.ClientID] = client.Name
+		}
+	}
+
+	list := make([]AuthorizedAppResp, 0, len(consents))
+	for _, consent := range consents {
+		list = append(list, AuthorizedAppResp{
+			ClientID:     consent.ClientID,
+			Name:         names[consent.ClientID],
+			Scopes:       parseScope(consent.Scope),
+			AuthorizedAt: consent.CreatedAt,
+			UpdatedAt:    consent.UpdatedAt,
+		})
+	}
+	return response.Success[any](c, list)
+}
+
+// RevokeMyAuthorization 取消对第三方应用的授权，同时删除该应用持有的令牌
+func (h *OAuthHandler) RevokeMyAuthorization(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+	clientID := c.Param("client_id")
+
+	deleted, err := h.repo.DeleteConsent(ctx, userID, clientID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "取消授权失败")
+	}
+	if !deleted {
+		return response.Error(c, http.StatusNotFound, "授权记录不存在")
+	}
+
+	if err := h.repo.DeleteUserClientGrants(ctx, userID, clientID); err != nil {
+		logx.Error("delete oauth grants failed", "user_id", userID, "client_id", clientID, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "删除令牌失败")
+	}
+	return response.SuccessWithMsg[any](c, "已取消授权", nil)
+}
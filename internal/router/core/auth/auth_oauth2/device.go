@@ -0,0 +1,365 @@
+package auth_oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"king-starter/internal/response"
+	auth_password2 "king-starter/internal/router/core/auth/auth_password"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/logx"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// userCodeAlphabet 用户码字符集，去掉元音和易混淆的字符，避免组成单词或输错（RFC 8628 6.1）
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// userCodeLength 用户码长度，展示时每 4 位以 - 分隔
+const userCodeLength = 8
+
+// slowDownStep 设备轮询过快时增加的轮询间隔（RFC 8628 3.5）
+const slowDownStep = 5
+
+// DeviceAuthorizationReq 设备授权请求参数（RFC 8628 3.1）
+type DeviceAuthorizationReq struct {
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty" form:"client_secret"`
+	Scope        string `json:"scope,omitempty" form:"scope"`
+}
+
+// DeviceAuthorizationResp 设备授权响应（RFC 8628 3.2）
+type DeviceAuthorizationResp struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceVerifyReq 用户确认设备授权请求参数
+type DeviceVerifyReq struct {
+	UserCode string `json:"user_code" query:"user_code"`
+	Approve  bool   `json:"approve"` // 是否同意授权
+}
+
+// DeviceAuthorization 设备授权端点，为设备签发设备码和用户码
+// 设备展示用户码和验证地址，用户在其他设备上登录后输入用户码完成授权，设备同时轮询令牌端点
+func (h *OAuthHandler) DeviceAuthorization(c echo.Context) error {
+	var req DeviceAuthorizationReq
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "请求参数错误")
+	}
+
+	client, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		if errors.Is(err, errInvalidClient) {
+			return oauthError(c, http.StatusUnauthorized, "invalid_client", "无效的客户端凭证")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证客户端失败")
+	}
+	if client.Status != 1 {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "客户端已被禁用")
+	}
+	if !client.AllowsGrant(GrantTypeDeviceCode) {
+		return oauthError(c, http.StatusBadRequest, "unauthorized_client", "客户端不允许使用设备授权模式")
+	}
+	if !client.AllowsScope(req.Scope) {
+		return oauthError(c, http.StatusBadRequest, "invalid_scope", "请求的权限范围超出客户端允许的范围")
+	}
+
+	deviceCode := cryptoutil.RandomToken(32)
+	userCode := cryptoutil.RandomString(userCodeAlphabet, userCodeLength)
+	now := time.Now()
+	record := &OAuthDeviceCode{
+		ID:             uuid.New().String(),
+		ClientID:       client.ClientID,
+		DeviceCodeHash: cryptoutil.SHA256Hex(deviceCode),
+		UserCode:       userCode,
+		Scope:          joinScope(parseScope(req.Scope)),
+		Status:         DeviceCodePending,
+		Interval:       h.deviceInterval,
+		ExpiresAt:      now.Add(h.deviceCodeTTL),
+	}
+	if err := h.repo.CreateDeviceCode(c.Request().Context(), record); err != nil {
+		logx.Error("failed to create oauth device code", "client_id", client.ClientID, "error", err)
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建设备授权请求失败")
+	}
+
+	display := formatUserCode(userCode)
+	resp := DeviceAuthorizationResp{
+		DeviceCode:      deviceCode,
+		UserCode:        display,
+		VerificationURI: h.deviceVerificationURL,
+		ExpiresIn:       int(h.deviceCodeTTL.Seconds()),
+		Interval:        h.deviceInterval,
+	}
+	if h.deviceVerificationURL != "" {
+		resp.VerificationURIComplete = withQuery(h.deviceVerificationURL, url.Values{"user_code": {display}})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// handleDeviceCode 处理设备授权模式（RFC 8628 3.4）
+// 设备通常使用标准 OAuth2 客户端库轮询，需要根据 authorization_pending、slow_down 等错误码决定下一步，
+// 因此成功和失败都按 RFC 6749 5.1/5.2 返回标准格式，不使用统一响应格式
+func (h *OAuthHandler) handleDeviceCode(c echo.Context, req OAuthTokenReq) error {
+	client, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		if errors.Is(err, errInvalidClient) {
+			return oauthError(c, http.StatusUnauthorized, "invalid_client", "无效的客户端凭证")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证客户端失败")
+	}
+	if client.Status != 1 {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "客户端已被禁用")
+	}
+	if !client.AllowsGrant(GrantTypeDeviceCode) {
+		return oauthError(c, http.StatusBadRequest, "unauthorized_client", "客户端不允许使用设备授权模式")
+	}
+	if req.DeviceCode == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "缺少 device_code")
+	}
+
+	ctx := c.Request().Context()
+
+	record, err := h.repo.GetDeviceCodeByHash(ctx, cryptoutil.SHA256Hex(req.DeviceCode))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的设备码")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询设备码失败")
+	}
+	if record.ClientID != client.ClientID {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的设备码")
+	}
+
+	now := time.Now()
+	if !record.ExpiresAt.After(now) {
+		return oauthError(c, http.StatusBadRequest, "expired_token", "设备码已过期")
+	}
+
+	// 轮询间隔小于要求时返回 slow_down，并将后续轮询间隔增加 5 秒
+	interval := record.Interval
+	slowDown := !record.LastPolledAt.IsZero() && now.Sub(record.LastPolledAt) < time.Duration(interval)*time.Second
+	if slowDown {
+		interval += slowDownStep
+	}
+	if err := h.repo.TouchDeviceCode(ctx, record.ID, now, interval); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "更新设备码失败")
+	}
+	if slowDown {
+		return oauthError(c, http.StatusBadRequest, "slow_down", "轮询过于频繁")
+	}
+
+	switch record.Status {
+	case DeviceCodePending:
+		return oauthError(c, http.StatusBadRequest, "authorization_pending", "等待用户授权")
+	case DeviceCodeDenied:
+		h.repo.DeleteDeviceCode(ctx, record.ID)
+		return oauthError(c, http.StatusBadRequest, "access_denied", "用户拒绝授权")
+	}
+
+	token := newOAuthToken(client.ClientID, record.UserID, record.Scope, true)
+	token.AuthTime = record.AuthTime
+
+	data, err := h.oidcTokenResponse(ctx, token, "")
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "签发 id_token 失败")
+	}
+
+	// 消费设备码并创建令牌，设备码已被使用时不签发令牌
+	redeemed, err := h.repo.RedeemDeviceCode(ctx, record.ID, token)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建令牌失败")
+	}
+	if !redeemed {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的设备码")
+	}
+	return tokenSuccess(c, data)
+}
+
+// GetDeviceVerification 查询用户码对应的设备授权请求，由前端设备验证页展示客户端和权限范围
+func (h *OAuthHandler) GetDeviceVerification(c echo.Context) error {
+	var req DeviceVerifyReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	record, client, err := h.pendingDevice(c, req.UserCode)
+	if err != nil {
+		return deviceVerifyError(c, err)
+	}
+
+	return response.Success[any](c, map[string]interface{}{
+		"user_code": formatUserCode(record.UserCode),
+		"client": map[string]interface{}{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"scopes":        parseScope(record.Scope),
+		"scope_details": describeScopes(record.Scope),
+	})
+}
+
+// VerifyDevice 当前用户同意或拒绝设备授权请求，同意后设备下一次轮询即可换取令牌
+func (h *OAuthHandler) VerifyDevice(c echo.Context) error {
+	var req DeviceVerifyReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+
+	record, client, err := h.pendingDevice(c, req.UserCode)
+	if err != nil {
+		return deviceVerifyError(c, err)
+	}
+
+	u, err := h.userRepo.GetByID(ctx, echoutil.GetUserID(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+
+	if !req.Approve {
+		if _, err := h.repo.DecideDeviceCode(ctx, record.ID, DeviceCodeDenied, u.ID, time.Time{}); err != nil {
+			return response.Error(c, http.StatusInternalServerError, "更新设备授权请求失败")
+		}
+		return response.SuccessWithMsg[any](c, "已拒绝授权", nil)
+	}
+
+	authTime := loginTime(middleware.GetClaims(c))
+	decided, err := h.repo.DecideDeviceCode(ctx, record.ID, DeviceCodeApproved, u.ID, authTime)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新设备授权请求失败")
+	}
+	if !decided {
+		return response.Error(c, http.StatusNotFound, "无效或已过期的用户码")
+	}
+	if err := h.repo.SaveConsent(ctx, u.ID, client.ClientID, record.Scope); err != nil {
+		logx.Error("failed to save oauth consent", "user_id", u.ID, "client_id", client.ClientID, "error", err)
+	}
+
+	// 记录 OAuth2 设备授权成功日志
+	log := &auth_password2.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Username:  u.Username,
+		AuthType:  auth_password2.AuthTypeOAuth2,
+		LoginType: auth_password2.LoginTypeSuccess,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   "OAuth2 设备授权成功，客户端: " + client.ClientID,
+	}
+	h.repo.CreateLoginLog(ctx, log)
+
+	return response.SuccessWithMsg[any](c, "授权成功，请返回设备继续操作", nil)
+}
+
+var (
+	errInvalidUserCode  = errors.New("invalid user code")           // 用户码不存在、已过期或已处理
+	errUserCodeAttempts = errors.New("too many user code attempts") // 用户码猜测次数已用完
+)
+
+// pendingDevice 根据用户输入的用户码查找待确认的设备授权请求及其客户端
+// 用户码熵较低，按 RFC 8628 5.1 限制猜测次数：每次查找失败记录一条失败日志，按当前用户和 IP 统计
+func (h *OAuthHandler) pendingDevice(c echo.Context, userCode string) (*OAuthDeviceCode, *OAuthClient, error) {
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	exceeded, err := h.lockout.FailuresExceeded(ctx, auth_password2.AuthTypeDevice, userID, c.RealIP())
+	if err != nil {
+		return nil, nil, err
+	}
+	if exceeded {
+		return nil, nil, errUserCodeAttempts
+	}
+
+	record, client, err := h.findPendingDevice(ctx, normalizeUserCode(userCode))
+	if errors.Is(err, errInvalidUserCode) {
+		h.repo.CreateLoginLog(ctx, &auth_password2.CoreLoginLog{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			AuthType:  auth_password2.AuthTypeDevice,
+			LoginType: auth_password2.LoginTypeFailed,
+			IP:        c.RealIP(),
+			UserAgent: c.Request().UserAgent(),
+			Message:   "无效或已过期的用户码",
+		})
+	}
+	return record, client, err
+}
+
+// findPendingDevice 查找用户码对应的待确认设备授权请求及其客户端
+func (h *OAuthHandler) findPendingDevice(ctx context.Context, userCode string) (*OAuthDeviceCode, *OAuthClient, error) {
+	if userCode == "" {
+		return nil, nil, errInvalidUserCode
+	}
+
+	record, err := h.repo.GetDeviceCodeByUserCode(ctx, userCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errInvalidUserCode
+		}
+		return nil, nil, err
+	}
+	if record.Status != DeviceCodePending || !record.ExpiresAt.After(time.Now()) {
+		return nil, nil, errInvalidUserCode
+	}
+
+	client, err := h.repo.GetClientByClientID(ctx, record.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errInvalidUserCode
+		}
+		return nil, nil, err
+	}
+	if client.Status != 1 {
+		return nil, nil, errInvalidUserCode
+	}
+	return record, client, nil
+}
+
+// deviceVerifyError 将 pendingDevice 返回的错误转换为响应
+func deviceVerifyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errInvalidUserCode):
+		return response.Error(c, http.StatusNotFound, "无效或已过期的用户码")
+	case errors.Is(err, errUserCodeAttempts):
+		return response.Error(c, http.StatusTooManyRequests, "用户码错误次数过多，请稍后再试")
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询设备授权请求失败")
+}
+
+// normalizeUserCode 规范化用户输入的用户码：忽略大小写、分隔符和空白
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, userCode)
+}
+
+// formatUserCode 将用户码每 4 位以 - 分隔，便于用户阅读和输入
+func formatUserCode(userCode string) string {
+	if len(userCode) <= 4 {
+		return userCode
+	}
+	return userCode[:4] + "-" + userCode[4:]
+}
@@ -0,0 +1,220 @@
+package auth_oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/http/middleware"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newDeviceEnv 创建测试环境，并允许公开客户端 spa 使用设备授权模式
+func newDeviceEnv(t *testing.T) *testEnv {
+	env := newTestEnv(t)
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "spa").Update("grant_types", GrantTypeDeviceCode+" refresh_token").Error)
+	return env
+}
+
+// deviceAuthorize 以客户端 spa 的身份请求设备授权端点
+func (env *testEnv) deviceAuthorize(t *testing.T, scope string) DeviceAuthorizationResp {
+	rec := env.tokenAction(t, env.handler.DeviceAuthorization, url.Values{"client_id": {"spa"}, "scope": {scope}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp DeviceAuthorizationResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+// pollDevice 以客户端 spa 的身份轮询令牌端点，返回 HTTP 状态码和响应内容
+func (env *testEnv) pollDevice(t *testing.T, deviceCode string) (int, map[string]interface{}) {
+	rec := env.tokenAction(t, env.handler.GetToken, url.Values{"grant_type": {GrantTypeDeviceCode}, "client_id": {"spa"}, "device_code": {deviceCode}})
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &data))
+	return rec.Code, data
+}
+
+// verifyDevice 以用户 u1 的身份同意或拒绝设备授权
+func (env *testEnv) verifyDevice(t *testing.T, userCode string, approve bool) int {
+	raw, err := json.Marshal(map[string]interface{}{"user_code": userCode, "approve": approve})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/oauth/device", strings.NewReader(string(raw)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	claims, err := env.jwt.ParseToken(env.bearer)
+	require.NoError(t, err)
+	echoutil.SetUserID(c, "u1")
+	c.Set(middleware.ClaimsKey, claims)
+	require.NoError(t, env.handler.VerifyDevice(c))
+	status, _ := decode(t, rec)
+	return status
+}
+
+// resetPolling 清除上次轮询时间，模拟设备按要求的间隔轮询
+func (env *testEnv) resetPolling(t *testing.T) {
+	require.NoError(t, env.db.Model(&OAuthDeviceCode{}).Where("1 = 1").Update("last_polled_at", time.Time{}).Error)
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	env := newDeviceEnv(t)
+	resp := env.deviceAuthorize(t, "openid profile")
+
+	assert.NotEmpty(t, resp.DeviceCode)
+	assert.Regexp(t, `^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`, resp.UserCode)
+	assert.Equal(t, "http://localhost:8080/oauth/device", resp.VerificationURI)
+	assert.Contains(t, resp.VerificationURIComplete, "user_code="+resp.UserCode)
+	assert.Equal(t, 600, resp.ExpiresIn)
+	assert.Equal(t, 5, resp.Interval)
+
+	// 用户查看待确认的请求，用户码忽略大小写和分隔符
+	req := httptest.NewRequest(http.MethodGet, "/oauth/device?user_code="+strings.ToLower(strings.ReplaceAll(resp.UserCode, "-", "")), nil)
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	echoutil.SetUserID(c, "u1")
+	require.NoError(t, env.handler.GetDeviceVerification(c))
+	status, data := decode(t, rec)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, resp.UserCode, data["user_code"])
+	assert.Equal(t, "spa", data["client"].(map[string]interface{})["client_id"])
+	assert.Equal(t, []interface{}{"openid", "profile"}, data["scopes"])
+
+	// 用户确认前返回 authorization_pending
+	code, body := env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	// 轮询过快返回 slow_down，并增加轮询间隔
+	code, body = env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "slow_down", body["error"])
+	var record OAuthDeviceCode
+	require.NoError(t, env.db.First(&record).Error)
+	assert.Equal(t, 10, record.Interval)
+
+	require.Equal(t, http.StatusOK, env.verifyDevice(t, resp.UserCode, true))
+	env.resetPolling(t)
+
+	code, body = env.pollDevice(t, resp.DeviceCode)
+	require.Equal(t, http.StatusOK, code, body)
+	assert.NotEmpty(t, body["access_token"])
+	assert.NotEmpty(t, body["refresh_token"])
+	assert.NotEmpty(t, body["id_token"])
+	assert.Equal(t, "openid profile", body["scope"])
+
+	// 同意授权后记住用户授权
+	consent, err := env.handler.repo.GetConsent(t.Context(), "u1", "spa")
+	require.NoError(t, err)
+	assert.Equal(t, "openid profile", consent.Scope)
+
+	// 设备码只能使用一次
+	code, body = env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	// 已处理的用户码不能再次确认
+	assert.Equal(t, http.StatusNotFound, env.verifyDevice(t, resp.UserCode, true))
+}
+
+// TestDeviceCodeKeptWhenTokenCreationFails 创建令牌失败时设备码保留，设备可以重新轮询
+func TestDeviceCodeKeptWhenTokenCreationFails(t *testing.T) {
+	env := newDeviceEnv(t)
+	resp := env.deviceAuthorize(t, "openid")
+	require.Equal(t, http.StatusOK, env.verifyDevice(t, resp.UserCode, true))
+
+	failed := false
+	require.NoError(t, env.db.Callback().Create().Before("gorm:create").Register("test:fail_token", func(tx *gorm.DB) {
+		if tx.Statement.Table == (&OAuthToken{}).TableName() && !failed {
+			failed = true
+			tx.AddError(errors.New("insert failed"))
+		}
+	}))
+
+	code, body := env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "server_error", body["error"])
+
+	env.resetPolling(t)
+	code, body = env.pollDevice(t, resp.DeviceCode)
+	require.Equal(t, http.StatusOK, code, body)
+	assert.NotEmpty(t, body["access_token"])
+}
+
+func TestDeviceAuthorizationDenied(t *testing.T) {
+	env := newDeviceEnv(t)
+	resp := env.deviceAuthorize(t, "profile")
+
+	require.Equal(t, http.StatusOK, env.verifyDevice(t, resp.UserCode, false))
+
+	code, body := env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "access_denied", body["error"])
+
+	env.resetPolling(t)
+	code, body = env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", body["error"])
+}
+
+func TestDeviceCodeExpired(t *testing.T) {
+	env := newDeviceEnv(t)
+	resp := env.deviceAuthorize(t, "")
+	require.NoError(t, env.db.Model(&OAuthDeviceCode{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	code, body := env.pollDevice(t, resp.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "expired_token", body["error"])
+
+	assert.Equal(t, http.StatusNotFound, env.verifyDevice(t, resp.UserCode, true))
+}
+
+// TestDeviceUserCodeAttempts 用户码查找失败计入失败次数，用完后即使输入正确的用户码也被拒绝
+func TestDeviceUserCodeAttempts(t *testing.T) {
+	env := newDeviceEnv(t)
+	resp := env.deviceAuthorize(t, "")
+
+	// 测试环境的账号维度失败上限为 3
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusNotFound, env.verifyDevice(t, "BCDF-GHJK", true))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, env.verifyDevice(t, resp.UserCode, true))
+
+	var failed int64
+	require.NoError(t, env.db.Model(&auth_password.CoreLoginLog{}).Where("user_id = ? AND auth_type = ? AND login_type = ?", "u1", auth_password.AuthTypeDevice, auth_password.LoginTypeFailed).Count(&failed).Error)
+	assert.EqualValues(t, 3, failed)
+
+	// 失败日志移出统计窗口后恢复
+	require.NoError(t, env.db.Model(&auth_password.CoreLoginLog{}).Where("1 = 1").Update("created_at", time.Now().Add(-time.Hour)).Error)
+	assert.Equal(t, http.StatusOK, env.verifyDevice(t, resp.UserCode, true))
+}
+
+func TestDeviceAuthorizationRejectsClient(t *testing.T) {
+	env := newDeviceEnv(t)
+
+	// 未登记设备授权模式的客户端
+	rec := env.tokenAction(t, env.handler.DeviceAuthorization, url.Values{}, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unauthorized_client")
+
+	// 客户端密钥错误
+	rec = env.tokenAction(t, env.handler.DeviceAuthorization, url.Values{}, "web", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid_client")
+
+	// 设备码只能由申请它的客户端使用
+	resp := env.deviceAuthorize(t, "")
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "web").Update("grant_types", GrantTypeDeviceCode).Error)
+	rec = env.tokenAction(t, env.handler.GetToken, url.Values{"grant_type": {GrantTypeDeviceCode}, "device_code": {resp.DeviceCode}}, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid_grant")
+}
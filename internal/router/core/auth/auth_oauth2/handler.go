@@ -1,153 +1,180 @@
 package auth_oauth2
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"time"
 
-	"king-starter/internal/response"
+	"king-starter/config"
 	auth_password2 "king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/resp"
+	"king-starter/pkg/jwt"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
-// OAuthAuthorizeReq OAuth 授权请求参数
-type OAuthAuthorizeReq struct {
-	ClientID     string `query:"client_id" validate:"required"`
-	RedirectURI  string `query:"redirect_uri" validate:"required"`
-	ResponseType string `query:"response_type" validate:"required,oneof=code token"`
-	Scope        string `query:"scope"`
-	State        string `query:"state"`
-}
-
 // OAuthTokenReq OAuth 获取令牌请求参数
+// 标准客户端以 application/x-www-form-urlencoded 提交，同时兼容 JSON
+// 客户端凭证也可以通过 HTTP Basic 认证传递，公开客户端不需要 client_secret
 type OAuthTokenReq struct {
-	ClientID     string `json:"client_id" validate:"required"`
-	ClientSecret string `json:"client_secret" validate:"required"`
-	GrantType    string `json:"grant_type" validate:"required,oneof=authorization_code refresh_token password client_credentials"`
-	Code         string `json:"code,omitempty"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	Username     string `json:"username,omitempty"`
-	Password     string `json:"password,omitempty"`
-	RedirectURI  string `json:"redirect_uri,omitempty"`
-	Scope        string `json:"scope,omitempty"`
+	ClientID     string `json:"client_id" form:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	GrantType    string `json:"grant_type" form:"grant_type" validate:"required,oneof=authorization_code refresh_token password client_credentials urn:ietf:params:oauth:grant-type:device_code"`
+	Code         string `json:"code,omitempty" form:"code"`
+	CodeVerifier string `json:"code_verifier,omitempty" form:"code_verifier"`
+	DeviceCode   string `json:"device_code,omitempty" form:"device_code"`
+	RefreshToken string `json:"refresh_token,omitempty" form:"refresh_token"`
+	Username     string `json:"username,omitempty" form:"username"`
+	Password     string `json:"password,omitempty" form:"password"`
+	RedirectURI  string `json:"redirect_uri,omitempty" form:"redirect_uri"`
+	Scope        string `json:"scope,omitempty" form:"scope"`
 }
 
-// OAuthUserInfoReq OAuth 获取用户信息请求参数
-type OAuthUserInfoReq struct {
-	AccessToken string `header:"Authorization" validate:"required"`
-}
+// errInvalidClient 客户端不存在或凭证错误
+var errInvalidClient = errors.New("invalid client credentials")
+
+const (
+	accessTokenTTL  = 2 * time.Hour       // 访问令牌有效期
+	refreshTokenTTL = 30 * 24 * time.Hour // 刷新令牌有效期
+)
 
 // OAuthHandler OAuth2 认证处理器
 type OAuthHandler struct {
-	repo *Repository
+	repo         *Repository
+	userRepo     *user.Repository
+	verifier     *auth_password2.PasswordVerifier
+	lockout      *auth_password2.Lockout     // 限制设备授权用户码的猜测次数
+	secondFactor auth_password2.SecondFactor // 为 nil 时不要求两步验证
+	jwt          *jwt.JWT
+	consentURL   string
+	codeTTL      time.Duration
+
+	// OpenID Connect
+	issuer      string
+	idTokenTTL  time.Duration
+	loginMaxAge time.Duration
+
+	// 设备授权（RFC 8628）
+	deviceVerificationURL string
+	deviceCodeTTL         time.Duration
+	deviceInterval        int
+
+	redirectPolicy redirectPolicy
+	registration   config.ClientRegistrationConfig
 }
 
 // NewOAuthHandler 创建 OAuth2 认证处理器实例
-func NewOAuthHandler(repo *Repository) *OAuthHandler {
+func NewOAuthHandler(repo *Repository, userRepo *user.Repository, verifier *auth_password2.PasswordVerifier, lockout *auth_password2.Lockout, secondFactor auth_password2.SecondFactor, jwt *jwt.JWT, cfg config.OAuth2Config) *OAuthHandler {
 	return &OAuthHandler{
-		repo: repo,
+		repo:         repo,
+		userRepo:     userRepo,
+		verifier:     verifier,
+		lockout:      lockout,
+		secondFactor: secondFactor,
+		jwt:          jwt,
+		consentURL:   cfg.ConsentURL,
+		codeTTL:      time.Duration(cfg.CodeTTL) * time.Second,
+
+		issuer:      cfg.Issuer,
+		idTokenTTL:  time.Duration(cfg.IDTokenTTL) * time.Second,
+		loginMaxAge: time.Duration(cfg.LoginMaxAge) * time.Second,
+
+		deviceVerificationURL: cfg.DeviceVerificationURL,
+		deviceCodeTTL:         time.Duration(cfg.DeviceCodeTTL) * time.Second,
+		deviceInterval:        cfg.DeviceInterval,
+
+		redirectPolicy: newRedirectPolicy(cfg.RedirectSchemes),
+		registration:   cfg.Registration,
 	}
 }
 
-// Authorize 授权接口
-func (h *OAuthHandler) Authorize(c echo.Context) error {
-	var req OAuthAuthorizeReq
+// GetToken 令牌端点（RFC 6749 3.2）
+// 标准 OAuth2/OIDC 客户端库直接解析令牌端点的响应，因此成功和失败都按 RFC 6749 5.1/5.2 返回标准格式，不使用统一响应格式
+func (h *OAuthHandler) GetToken(c echo.Context) error {
+	var req OAuthTokenReq
 	if err := c.Bind(&req); err != nil {
-		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "请求参数错误")
 	}
 
-	// 验证客户端是否存在
-	client, err := h.repo.GetClientByClientID(c.Request().Context(), req.ClientID)
+	// 设备授权模式按 RFC 8628 返回标准格式，供设备端的 OAuth2 客户端库识别轮询状态
+	if req.GrantType == GrantTypeDeviceCode {
+		return h.handleDeviceCode(c, req)
+	}
+
+	// 验证客户端
+	client, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusBadRequest, "无效的客户端ID")
+		if errors.Is(err, errInvalidClient) {
+			return oauthError(c, http.StatusUnauthorized, "invalid_client", "无效的客户端凭证")
 		}
-		return response.Error(c, http.StatusInternalServerError, "查询客户端失败")
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证客户端失败")
 	}
 
 	// 检查客户端状态
 	if client.Status != 1 {
-		return response.Error(c, http.StatusBadRequest, "客户端已被禁用")
-	}
-
-	// 验证回调地址
-	if client.RedirectURI != req.RedirectURI {
-		return response.Error(c, http.StatusBadRequest, "回调地址不匹配")
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "客户端已被禁用")
 	}
 
-	// 这里应该有用户授权页面逻辑
-	// 为了示例，我们直接返回授权码
-	code := uuid.New().String()
-
-	authCode := &OAuthCode{
-		ID:          uuid.New().String(),
-		ClientID:    client.ClientID,
-		UserID:      "1", // 示例用户ID
-		Code:        code,
-		RedirectURI: req.RedirectURI,
-		Scope:       req.Scope,
-		ExpiresAt:   time.Now().Add(10 * time.Minute), // 10分钟过期
-	}
-
-	if err := h.repo.CreateOAuthCode(c.Request().Context(), authCode); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "创建授权码失败")
-	}
-
-	// 记录 OAuth2 授权成功日志
-	log := &auth_password2.CoreLoginLog{
-		ID:        uuid.New().String(),
-		UserID:    "1",
-		Username:  "admin", // 示例用户名
-		AuthType:  auth_password2.AuthTypeOAuth2,
-		LoginType: auth_password2.LoginTypeSuccess,
-		IP:        c.RealIP(),
-		UserAgent: c.Request().UserAgent(),
-		Message:   "OAuth2 授权成功",
+	var handle func(echo.Context, OAuthTokenReq, *OAuthClient) error
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		// 使用授权码换取令牌
+		handle = h.handleAuthorizationCode
+	case GrantTypeRefreshToken:
+		// 使用刷新令牌
+		handle = h.handleRefreshToken
+	case GrantTypePassword:
+		// 使用用户账号密码
+		handle = h.handlePassword
+	case GrantTypeClientCredentials:
+		// 使用客户端自身凭证
+		handle = h.handleClientCredentials
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "不支持的授权类型")
 	}
-	h.repo.CreateLoginLog(c.Request().Context(), log)
 
-	// 重定向到回调地址
-	redirectURL := req.RedirectURI + "?code=" + code
-	if req.State != "" {
-		redirectURL += "&state=" + req.State
+	// 检查客户端是否允许该授权类型
+	if !client.AllowsGrant(req.GrantType) {
+		return oauthError(c, http.StatusBadRequest, "unauthorized_client", "客户端不允许使用该授权类型")
 	}
-
-	return c.Redirect(http.StatusFound, redirectURL)
+	return handle(c, req, client)
 }
 
-// GetToken 获取令牌接口
-func (h *OAuthHandler) GetToken(c echo.Context) error {
-	var req OAuthTokenReq
-	if err := c.Bind(&req); err != nil {
-		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+// authenticateClient 校验客户端凭证，优先使用 HTTP Basic 认证（RFC 6749 2.3.1）
+// 公开客户端只校验 client_id，其授权码由 PKCE 保护
+func (h *OAuthHandler) authenticateClient(c echo.Context, clientID, clientSecret string) (*OAuthClient, error) {
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		// Basic 认证中的凭证需先经过 application/x-www-form-urlencoded 编码
+		var err error
+		if clientID, err = url.QueryUnescape(id); err != nil {
+			return nil, errInvalidClient
+		}
+		if clientSecret, err = url.QueryUnescape(secret); err != nil {
+			return nil, errInvalidClient
+		}
+	}
+	if clientID == "" {
+		return nil, errInvalidClient
 	}
 
-	// 验证客户端
-	client, err := h.repo.GetClientByClientIDAndSecret(c.Request().Context(), req.ClientID, req.ClientSecret)
+	client, err := h.repo.GetClientByClientID(c.Request().Context(), clientID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusBadRequest, "无效的客户端凭证")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidClient
 		}
-		return response.Error(c, http.StatusInternalServerError, "验证客户端失败")
+		return nil, err
 	}
-
-	// 检查客户端状态
-	if client.Status != 1 {
-		return response.Error(c, http.StatusBadRequest, "客户端已被禁用")
+	if client.IsPublic {
+		return client, nil
 	}
-
-	switch req.GrantType {
-	case "authorization_code":
-		// 使用授权码换取令牌
-		return h.handleAuthorizationCode(c, req, client)
-	case "refresh_token":
-		// 使用刷新令牌
-		return h.handleRefreshToken(c, req, client)
-	default:
-		return response.Error(c, http.StatusBadRequest, "不支持的授权类型")
+	if !client.CheckSecret(clientSecret) {
+		return nil, errInvalidClient
 	}
+	return client, nil
 }
 
 // 处理授权码方式
@@ -156,146 +183,244 @@ func (h *OAuthHandler) handleAuthorizationCode(c echo.Context, req OAuthTokenReq
 	authCode, err := h.repo.GetOAuthCodeByCode(c.Request().Context(), req.Code)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusBadRequest, "无效的授权码")
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的授权码")
 		}
-		return response.Error(c, http.StatusInternalServerError, "查询授权码失败")
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询授权码失败")
 	}
 
 	// 检查授权码是否过期
 	if authCode.ExpiresAt.Before(time.Now()) {
-		return response.Error(c, http.StatusBadRequest, "授权码已过期")
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "授权码已过期")
 	}
 
 	// 验证客户端ID
-	if authCode.ClientID != req.ClientID {
-		return response.Error(c, http.StatusBadRequest, "客户端ID不匹配")
+	if authCode.ClientID != client.ClientID {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "客户端ID不匹配")
 	}
 
 	// 验证回调地址（如果提供）
 	if req.RedirectURI != "" && authCode.RedirectURI != req.RedirectURI {
-		return response.Error(c, http.StatusBadRequest, "回调地址不匹配")
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "回调地址不匹配")
+	}
+
+	// 校验 PKCE，授权请求带了 code_challenge 时必须提供匹配的 code_verifier
+	switch {
+	case authCode.CodeChallenge != "":
+		if req.CodeVerifier == "" {
+			return oauthError(c, http.StatusBadRequest, "invalid_request", "缺少 code_verifier")
+		}
+		if !verifyPKCE(authCode.CodeChallengeMethod, authCode.CodeChallenge, req.CodeVerifier) {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "code_verifier 校验失败")
+		}
+	case req.CodeVerifier != "":
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "授权请求未使用 PKCE")
+	case pkceRequired(client):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "该客户端必须使用 PKCE")
 	}
 
 	// 生成访问令牌和刷新令牌
-	accessToken := uuid.New().String()
-	refreshToken := uuid.New().String()
+	oauthToken := newOAuthToken(client.ClientID, authCode.UserID, authCode.Scope, true)
+	oauthToken.AuthTime = authCode.AuthTime
 
-	oauthToken := &OAuthToken{
-		ID:           uuid.New().String(),
-		ClientID:     client.ClientID,
-		UserID:       authCode.UserID,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		Scope:        authCode.Scope,
-		TokenType:    "Bearer",
-		ExpiresAt:    time.Now().Add(2 * time.Hour), // 2小时过期
+	data, err := h.oidcTokenResponse(c.Request().Context(), oauthToken, authCode.Nonce)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "签发 id_token 失败")
 	}
 
-	if err := h.repo.CreateOAuthToken(c.Request().Context(), oauthToken); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "创建令牌失败")
+	// 消费授权码并创建令牌，授权码已被使用时不签发令牌
+	redeemed, err := h.repo.RedeemOAuthCode(c.Request().Context(), authCode.ID, oauthToken)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建令牌失败")
 	}
-
-	// 删除已使用的授权码
-	if err := h.repo.DeleteOAuthCode(c.Request().Context(), req.Code); err != nil {
-		// 记录错误但不中断流程
+	if !redeemed {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的授权码")
 	}
 
-	return response.Success[any](c, map[string]interface{}{
-		"access_token":  oauthToken.AccessToken,
-		"refresh_token": oauthToken.RefreshToken,
-		"token_type":    oauthToken.TokenType,
-		"expires_in":    7200, // 2小时
-		"scope":         oauthToken.Scope,
-	})
+	return tokenSuccess(c, data)
 }
 
 // 处理刷新令牌方式
 func (h *OAuthHandler) handleRefreshToken(c echo.Context, req OAuthTokenReq, client *OAuthClient) error {
+	// 客户端凭证模式的令牌没有刷新令牌，空值不能参与查询
+	if req.RefreshToken == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的刷新令牌")
+	}
+
 	// 获取刷新令牌
 	token, err := h.repo.GetOAuthTokenByRefreshToken(c.Request().Context(), req.RefreshToken)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusBadRequest, "无效的刷新令牌")
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的刷新令牌")
 		}
-		return response.Error(c, http.StatusInternalServerError, "查询刷新令牌失败")
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询刷新令牌失败")
 	}
 
 	// 检查刷新令牌是否过期
-	if token.ExpiresAt.Before(time.Now()) {
-		return response.Error(c, http.StatusBadRequest, "刷新令牌已过期")
+	if token.RefreshExpiresAt.Before(time.Now()) {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "刷新令牌已过期")
 	}
 
 	// 检查客户端ID是否匹配
-	if token.ClientID != req.ClientID {
-		return response.Error(c, http.StatusBadRequest, "客户端ID不匹配")
+	if token.ClientID != client.ClientID {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "客户端ID不匹配")
 	}
 
-	// 生成新的访问令牌
-	newAccessToken := uuid.New().String()
-	newRefreshToken := uuid.New().String()
-
-	newToken := &OAuthToken{
-		ID:           uuid.New().String(),
-		ClientID:     token.ClientID,
-		UserID:       token.UserID,
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-		Scope:        token.Scope,
-		TokenType:    token.TokenType,
-		ExpiresAt:    time.Now().Add(2 * time.Hour),
+	// 权限范围不能超出原始授权，并去掉客户端当前不再允许的权限范围（RFC 6749 6）
+	granted := client.RestrictScope(token.GrantedScope())
+	scope := granted
+	if req.Scope != "" {
+		if !scopeCovers(granted, req.Scope) {
+			return oauthError(c, http.StatusBadRequest, "invalid_scope", "请求的权限范围超出原始授权范围")
+		}
+		scope = joinScope(parseScope(req.Scope))
 	}
 
-	if err := h.repo.CreateOAuthToken(c.Request().Context(), newToken); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "创建新令牌失败")
+	// 生成新的访问令牌，与旧令牌属于同一令牌族
+	// scope 参数只缩小本次访问令牌的权限范围，新的刷新令牌保持原始授权范围
+	newToken := newOAuthToken(token.ClientID, token.UserID, scope, true)
+	newToken.RefreshScope = granted
+	newToken.FamilyID = token.FamilyID
+	newToken.AuthTime = token.AuthTime
+
+	data, err := h.oidcTokenResponse(c.Request().Context(), newToken, "")
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "签发 id_token 失败")
 	}
 
-	// 删除旧的令牌
-	if err := h.repo.DeleteOAuthToken(c.Request().Context(), token.AccessToken); err != nil {
-		// 记录错误但不中断流程
+	// 删除旧令牌并创建新令牌，旧令牌已被使用时不签发令牌
+	rotated, err := h.repo.RotateOAuthToken(c.Request().Context(), token.ID, newToken)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建新令牌失败")
+	}
+	if !rotated {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "无效的刷新令牌")
 	}
 
-	return response.Success[any](c, map[string]interface{}{
-		"access_token":  newToken.AccessToken,
-		"refresh_token": newToken.RefreshToken,
-		"token_type":    newToken.TokenType,
-		"expires_in":    7200, // 2小时
-		"scope":         newToken.Scope,
-	})
+	return tokenSuccess(c, data)
 }
 
-// GetUserInfo 获取用户信息接口
-func (h *OAuthHandler) GetUserInfo(c echo.Context) error {
-	authHeader := c.Request().Header.Get("Authorization")
-	if authHeader == "" {
-		return response.Error(c, http.StatusUnauthorized, "缺少授权头")
+// 处理密码方式，仅限受信任的第一方客户端，账号校验与密码登录共用锁定策略
+func (h *OAuthHandler) handlePassword(c echo.Context, req OAuthTokenReq, client *OAuthClient) error {
+	if req.Username == "" || req.Password == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "用户名和密码不能为空")
 	}
 
-	// 解析 Bearer token
-	var accessToken string
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		accessToken = authHeader[7:]
-	} else {
-		return response.Error(c, http.StatusUnauthorized, "无效的授权头格式")
+	if !client.AllowsScope(req.Scope) {
+		return oauthError(c, http.StatusBadRequest, "invalid_scope", "请求的权限范围超出客户端允许的范围")
 	}
 
-	// 验证访问令牌
-	token, err := h.repo.GetOAuthTokenByAccessToken(c.Request().Context(), accessToken)
+	u, err := h.verifier.Verify(c, req.Username, req.Password)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusUnauthorized, "无效的访问令牌")
-		}
-		return response.Error(c, http.StatusInternalServerError, "验证令牌失败")
+		return passwordGrantError(c, err)
 	}
 
-	// 检查令牌是否过期
-	if token.ExpiresAt.Before(time.Now()) {
-		return response.Error(c, http.StatusUnauthorized, "访问令牌已过期")
+	// 密码模式无法完成两步验证，启用了两步验证的账号只能使用授权码模式
+	required, err := auth_password2.SecondFactorRequired(c.Request().Context(), h.secondFactor, u.ID)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询 2FA 配置失败")
+	}
+	if required {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "该账号已启用两步验证，请使用授权码模式登录")
+	}
+
+	token := newOAuthToken(client.ClientID, u.ID, req.Scope, true)
+	token.AuthTime = time.Now()
+
+	data, err := h.oidcTokenResponse(c.Request().Context(), token, "")
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "签发 id_token 失败")
 	}
 
-	// 返回用户信息
-	return response.Success[any](c, map[string]interface{}{
-		"user_id":   token.UserID,
-		"client_id": token.ClientID,
-		"scope":     token.Scope,
-	})
+	if err := h.repo.CreateOAuthToken(c.Request().Context(), token); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建令牌失败")
+	}
+
+	// 记录 OAuth2 密码模式登录日志
+	log := &auth_password2.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Username:  u.Username,
+		AuthType:  auth_password2.AuthTypeOAuth2,
+		LoginType: auth_password2.LoginTypeSuccess,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   "OAuth2 密码模式登录成功，客户端: " + client.ClientID,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
+
+	return tokenSuccess(c, data)
+}
+
+// 处理客户端凭证方式，令牌代表客户端自身，不关联用户，也不签发刷新令牌（RFC 6749 4.4.3）
+func (h *OAuthHandler) handleClientCredentials(c echo.Context, req OAuthTokenReq, client *OAuthClient) error {
+	if !client.AllowsScope(req.Scope) {
+		return oauthError(c, http.StatusBadRequest, "invalid_scope", "请求的权限范围超出客户端允许的范围")
+	}
+
+	token := newOAuthToken(client.ClientID, "", req.Scope, false)
+	if err := h.repo.CreateOAuthToken(c.Request().Context(), token); err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "创建令牌失败")
+	}
+	return tokenSuccess(c, tokenResponse(token))
+}
+
+// newOAuthToken 生成令牌记录并开启新的令牌族，withRefresh 为 false 时不签发刷新令牌
+func newOAuthToken(clientID, userID, scope string, withRefresh bool) *OAuthToken {
+	id := uuid.New().String()
+	now := time.Now()
+	token := &OAuthToken{
+		ID:          id,
+		ClientID:    clientID,
+		UserID:      userID,
+		AccessToken: uuid.New().String(),
+		Scope:       scope,
+		TokenType:   "Bearer",
+		ExpiresAt:   now.Add(accessTokenTTL),
+		FamilyID:    id,
+	}
+	if withRefresh {
+		token.RefreshToken = uuid.New().String()
+		token.RefreshExpiresAt = now.Add(refreshTokenTTL)
+	}
+	return token
+}
+
+// tokenResponse 令牌端点的响应内容
+func tokenResponse(token *OAuthToken) map[string]interface{} {
+	data := map[string]interface{}{
+		"access_token": token.AccessToken,
+		"token_type":   token.TokenType,
+		"expires_in":   int(accessTokenTTL.Seconds()),
+		"scope":        token.Scope,
+	}
+	if token.RefreshToken != "" {
+		data["refresh_token"] = token.RefreshToken
+	}
+	return data
+}
+
+// tokenSuccess 按 RFC 6749 5.1 返回令牌，响应不允许缓存
+func tokenSuccess(c echo.Context, data map[string]interface{}) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, data)
+}
+
+// passwordGrantError 将账号密码校验错误转换为 RFC 6749 5.2 错误
+func passwordGrantError(c echo.Context, err error) error {
+	var lockedErr *auth_password2.LockedError
+	switch {
+	case errors.As(err, &lockedErr):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", resp.ErrAccountLocked.Msg+"，解锁时间: "+lockedErr.Until.Format(time.DateTime))
+	case errors.Is(err, auth_password2.ErrIPBlocked):
+		return oauthError(c, http.StatusTooManyRequests, "invalid_request", resp.ErrTooManyRequests.Msg)
+	case errors.Is(err, auth_password2.ErrInvalidCredentials):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "用户名或密码错误")
+	case errors.Is(err, auth_password2.ErrUserDisabled):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "用户已被禁用")
+	case errors.Is(err, auth_password2.ErrEmailUnverified):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "邮箱未验证，请先完成邮箱验证")
+	default:
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询用户失败")
+	}
 }
@@ -0,0 +1,465 @@
+package auth_oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/jwt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.InitLogger()
+	RegisterScope("reports:read", "查看报表")
+	RegisterScope("reports:write", "编辑报表")
+	os.Exit(m.Run())
+}
+
+const testRedirectURI = "https://app.example.com/callback"
+
+type testEnv struct {
+	db      *gorm.DB
+	jwt     *jwt.JWT
+	handler *OAuthHandler
+	e       *echo.Echo
+	bearer  string // 用户 u1 的访问令牌
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db := testutil.NewDB(t, &OAuthClient{}, &OAuthCode{}, &OAuthToken{}, &OAuthConsent{}, &OAuthDeviceCode{}, &auth_password.CoreLoginLog{}, &user.CoreUser{})
+	require.NoError(t, db.Create(&[]OAuthClient{
+		{ID: "c1", ClientID: "spa", Name: "SPA", RedirectURIs: testRedirectURI, Status: 1, IsPublic: true},
+		{ID: "c2", ClientID: "web", ClientSecretHash: cryptoutil.SHA256Hex("web-secret"), Name: "Web", RedirectURIs: testRedirectURI + " https://app.example.com/alt", Status: 1},
+		{ID: "c3", ClientID: "strict", ClientSecretHash: cryptoutil.SHA256Hex("strict-secret"), Name: "Strict", RedirectURIs: testRedirectURI, Status: 1, RequirePKCE: true},
+		{ID: "c4", ClientID: "first-party", ClientSecretHash: cryptoutil.SHA256Hex("fp-secret"), Name: "First Party", Status: 1, GrantTypes: "password refresh_token"},
+		{ID: "c5", ClientID: "service", ClientSecretHash: cryptoutil.SHA256Hex("svc-secret"), Name: "Service", Status: 1, GrantTypes: "client_credentials", Scopes: "reports:read reports:write"},
+	}).Error)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Password: string(hash), Status: 1}).Error)
+
+	passwordRepo := auth_password.NewRepository(db)
+	lockoutCfg := config.DefaultAuthConfig().Lockout
+	lockoutCfg.MaxFailures = 3
+	lockout := auth_password.NewLockout(passwordRepo, lockoutCfg)
+	verifier := auth_password.NewPasswordVerifier(passwordRepo, user.NewRepository(db), lockout, false)
+	j := testutil.NewJWT()
+	bearer, err := j.GenerateToken("u1", "alice", "")
+	require.NoError(t, err)
+
+	cfg := config.DefaultAuthConfig().OAuth2
+	cfg.RedirectSchemes = []string{"com.example.reports", "com.example.tool"}
+	handler := NewOAuthHandler(NewRepository(db), user.NewRepository(db), verifier, lockout, nil, j, cfg)
+	return &testEnv{db: db, jwt: j, handler: handler, e: echo.New(), bearer: bearer}
+}
+
+// decode 解析统一响应格式
+func decode(t *testing.T, rec *httptest.ResponseRecorder) (int, map[string]interface{}) {
+	var resp struct {
+		Code int                    `json:"code"`
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Code, resp.Data
+}
+
+// authorizeRequest 请求授权端点，bearer 为空时模拟浏览器直接访问
+func (env *testEnv) authorizeRequest(t *testing.T, params url.Values, bearer string) *httptest.ResponseRecorder {
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", testRedirectURI)
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil)
+	if bearer != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.Authorize(env.e.NewContext(req, rec)))
+	return rec
+}
+
+// consent 以用户 u1 的身份确认或拒绝授权
+func (env *testEnv) consent(t *testing.T, params url.Values, approve bool) (int, map[string]interface{}) {
+	body := map[string]interface{}{"approve": approve}
+	for k := range params {
+		body[k] = params.Get(k)
+	}
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(string(raw)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	claims, err := env.jwt.ParseToken(env.bearer)
+	require.NoError(t, err)
+	echoutil.SetUserID(c, "u1")
+	c.Set(middleware.ClaimsKey, claims)
+	require.NoError(t, env.handler.Consent(c))
+	return decode(t, rec)
+}
+
+// redirectParams 解析返回的回调地址中的参数
+func redirectParams(t *testing.T, data map[string]interface{}) url.Values {
+	loc, err := url.Parse(data["redirect_to"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, testRedirectURI, loc.Scheme+"://"+loc.Host+loc.Path)
+	return loc.Query()
+}
+
+// authorize 以用户 u1 的身份发起授权请求，需要确认时同意授权
+// 成功时返回授权码和 http.StatusOK，失败时返回业务状态码
+func (env *testEnv) authorize(t *testing.T, params url.Values) (string, int) {
+	status, data := decode(t, env.authorizeRequest(t, params, env.bearer))
+	if status != http.StatusOK {
+		return "", status
+	}
+	if data["consent_required"] == true {
+		status, data = env.consent(t, params, true)
+		require.Equal(t, http.StatusOK, status)
+	}
+	return redirectParams(t, data).Get("code"), http.StatusOK
+}
+
+// token 以表单方式请求令牌端点，返回 HTTP 状态码和响应内容
+func (env *testEnv) token(t *testing.T, form url.Values, basicAuth ...string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if len(basicAuth) == 2 {
+		req.SetBasicAuth(basicAuth[0], basicAuth[1])
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.GetToken(env.e.NewContext(req, rec)))
+
+	// 令牌端点按 RFC 6749 5.1/5.2 返回，不使用统一响应格式
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &data))
+	return rec.Code, data
+}
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestPKCEPublicClient(t *testing.T) {
+	env := newTestEnv(t)
+	verifier := strings.Repeat("v", 43)
+
+	// 公开客户端必须使用 PKCE
+	_, status := env.authorize(t, url.Values{"client_id": {"spa"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	_, status = env.authorize(t, url.Values{"client_id": {"spa"}, "code_challenge": {s256(verifier)}, "code_challenge_method": {"S512"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	code, status := env.authorize(t, url.Values{"client_id": {"spa"}, "code_challenge": {s256(verifier)}, "code_challenge_method": {"S256"}})
+	require.Equal(t, http.StatusOK, status)
+
+	var stored OAuthCode
+	require.NoError(t, env.db.Where("code = ?", code).First(&stored).Error)
+	assert.Equal(t, PKCEMethodS256, stored.CodeChallengeMethod)
+
+	form := url.Values{"grant_type": {"authorization_code"}, "client_id": {"spa"}, "code": {code}, "redirect_uri": {testRedirectURI}}
+	status, _ = env.token(t, form)
+	assert.Equal(t, http.StatusBadRequest, status, "缺少 code_verifier")
+
+	form.Set("code_verifier", strings.Repeat("w", 43))
+	status, _ = env.token(t, form)
+	assert.Equal(t, http.StatusBadRequest, status, "code_verifier 不匹配")
+
+	// 公开客户端无需 client_secret
+	form.Set("code_verifier", verifier)
+	status, data := env.token(t, form)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data["access_token"])
+}
+
+func TestPKCEPlainMethod(t *testing.T) {
+	env := newTestEnv(t)
+	verifier := strings.Repeat("p", 50)
+
+	// 未指定 code_challenge_method 时默认为 plain
+	code, status := env.authorize(t, url.Values{"client_id": {"web"}, "code_challenge": {verifier}})
+	require.Equal(t, http.StatusOK, status)
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "code_verifier": {verifier}}
+	status, data := env.token(t, form, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data["access_token"])
+}
+
+func TestPKCEConfidentialClient(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 未要求 PKCE 的机密客户端仍可使用密钥换取令牌
+	code, status := env.authorize(t, url.Values{"client_id": {"web"}})
+	require.Equal(t, http.StatusOK, status)
+
+	form := url.Values{"grant_type": {"authorization_code"}, "client_id": {"web"}, "code": {code}}
+	status, _ = env.token(t, form)
+	assert.Equal(t, http.StatusUnauthorized, status, "机密客户端必须提供密钥")
+
+	form.Set("code_verifier", strings.Repeat("v", 43))
+	form.Set("client_secret", "web-secret")
+	status, _ = env.token(t, form)
+	assert.Equal(t, http.StatusBadRequest, status, "授权请求未使用 PKCE")
+
+	form.Del("code_verifier")
+	status, data := env.token(t, form)
+	require.Equal(t, http.StatusOK, status)
+
+	// 授权码只能使用一次
+	status, _ = env.token(t, form)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 刷新令牌只能使用一次
+	refresh := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {data["refresh_token"].(string)}}
+	status, _ = env.token(t, refresh, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+	status, data = env.token(t, refresh, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", data["error"])
+
+	// 要求 PKCE 的机密客户端
+	_, status = env.authorize(t, url.Values{"client_id": {"strict"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	env := newTestEnv(t)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read"}}
+	status, data := env.token(t, form, "service", "svc-secret")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data["access_token"])
+	assert.Equal(t, "reports:read", data["scope"])
+	assert.NotContains(t, data, "refresh_token")
+
+	var token OAuthToken
+	require.NoError(t, env.db.Where("access_token = ?", data["access_token"]).First(&token).Error)
+	assert.Empty(t, token.UserID)
+
+	// 空刷新令牌不能匹配到客户端凭证模式的令牌
+	status, _ = env.token(t, url.Values{"grant_type": {"refresh_token"}}, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 未授权客户端凭证模式的客户端和公开客户端不能使用
+	status, _ = env.token(t, form, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "spa").Update("grant_types", "client_credentials").Error)
+	status, _ = env.token(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"spa"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, data = env.token(t, form, "service", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", data["error"])
+}
+
+// TestRefreshTokenScope 刷新时可以缩小访问令牌的权限范围，且不能换取客户端已不再允许的权限范围
+func TestRefreshTokenScope(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "first-party").Update("scopes", "openid reports:read reports:write").Error)
+
+	status, data := env.token(t, url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"Passw0rd!"}, "scope": {"reports:read reports:write"}}, "first-party", "fp-secret")
+	require.Equal(t, http.StatusOK, status)
+	refresh := func(scope string) (int, map[string]interface{}) {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {data["refresh_token"].(string)}}
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		status, resp := env.token(t, form, "first-party", "fp-secret")
+		if status == http.StatusOK {
+			data = resp
+		}
+		return status, resp
+	}
+
+	// 超出原始授权的权限范围
+	status, resp := refresh("reports:read openid")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_scope", resp["error"])
+
+	// 缩小访问令牌的权限范围，刷新令牌保持原始授权范围
+	status, resp = refresh("reports:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "reports:read", resp["scope"])
+	status, resp = refresh("")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "reports:read reports:write", resp["scope"])
+
+	// 客户端的权限范围被收窄后，刷新得到的令牌随之收窄
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", "first-party").Update("scopes", "openid reports:read").Error)
+	status, resp = refresh("")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "reports:read", resp["scope"])
+	status, _ = refresh("reports:write")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestPasswordGrant(t *testing.T) {
+	env := newTestEnv(t)
+
+	form := url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"Passw0rd!"}}
+	status, data := env.token(t, form, "first-party", "fp-secret")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data["refresh_token"])
+
+	var token OAuthToken
+	require.NoError(t, env.db.Where("access_token = ?", data["access_token"]).First(&token).Error)
+	assert.Equal(t, "u1", token.UserID)
+
+	// 刷新令牌同样可用
+	status, _ = env.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {data["refresh_token"].(string)}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusOK, status)
+
+	// 未开启密码模式的客户端
+	status, data = env.token(t, form, "web", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unauthorized_client", data["error"])
+
+	// 密码错误计入登录锁定
+	form.Set("password", "wrong")
+	status, data = env.token(t, form, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", data["error"])
+	status, _ = env.token(t, form, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, data = env.token(t, form, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, data["error_description"], "解锁时间")
+
+	form.Set("password", "Passw0rd!")
+	status, data = env.token(t, form, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, data["error_description"], "解锁时间")
+}
+
+func TestAuthorizeRequiresLogin(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 未登录的浏览器跳转到前端授权页，并携带原始参数
+	rec := env.authorizeRequest(t, url.Values{"client_id": {"web"}, "state": {"xyz"}}, "")
+	require.Equal(t, http.StatusFound, rec.Code)
+	loc, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/consent", loc.Path)
+	assert.Equal(t, "web", loc.Query().Get("client_id"))
+	assert.Equal(t, "xyz", loc.Query().Get("state"))
+
+	// 无效的访问令牌同样视为未登录
+	rec = env.authorizeRequest(t, url.Values{"client_id": {"web"}}, "invalid")
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	// 未配置授权页时直接返回未登录
+	env.handler.consentURL = ""
+	status, _ := decode(t, env.authorizeRequest(t, url.Values{"client_id": {"web"}}, ""))
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// 参数错误不跳转
+	status, _ = decode(t, env.authorizeRequest(t, url.Values{"client_id": {"unknown"}}, ""))
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAuthorizeConsent(t *testing.T) {
+	env := newTestEnv(t)
+	params := url.Values{"client_id": {"web"}, "scope": {"profile email"}, "state": {"s1"}}
+
+	status, data := decode(t, env.authorizeRequest(t, params, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["consent_required"])
+	assert.Equal(t, []interface{}{"profile", "email"}, data["scopes"])
+	assert.Equal(t, "Web", data["client"].(map[string]interface{})["name"])
+
+	// 拒绝授权
+	status, data = env.consent(t, params, false)
+	require.Equal(t, http.StatusOK, status)
+	q := redirectParams(t, data)
+	assert.Equal(t, "access_denied", q.Get("error"))
+	assert.Equal(t, "s1", q.Get("state"))
+	assert.Empty(t, q.Get("code"))
+
+	// 同意授权
+	status, data = env.consent(t, params, true)
+	require.Equal(t, http.StatusOK, status)
+	q = redirectParams(t, data)
+	assert.NotEmpty(t, q.Get("code"))
+	assert.Equal(t, "s1", q.Get("state"))
+
+	var code OAuthCode
+	require.NoError(t, env.db.Where("code = ?", q.Get("code")).First(&code).Error)
+	assert.Equal(t, "u1", code.UserID)
+
+	// 已授权的权限范围不再需要确认
+	status, data = decode(t, env.authorizeRequest(t, url.Values{"client_id": {"web"}, "scope": {"email"}}, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, redirectParams(t, data).Get("code"))
+
+	// 请求新的权限范围或 prompt=consent 时重新确认
+	status, data = decode(t, env.authorizeRequest(t, url.Values{"client_id": {"web"}, "scope": {"email phone"}}, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["consent_required"])
+	status, data = decode(t, env.authorizeRequest(t, url.Values{"client_id": {"web"}, "scope": {"email"}, "prompt": {"consent"}}, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["consent_required"])
+}
+
+func TestMyAuthorizations(t *testing.T) {
+	env := newTestEnv(t)
+
+	code, status := env.authorize(t, url.Values{"client_id": {"web"}, "scope": {"profile"}})
+	require.Equal(t, http.StatusOK, status)
+	status, data := env.token(t, url.Values{"grant_type": {"authorization_code"}, "code": {code}}, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+	accessToken := data["access_token"]
+
+	call := func(h echo.HandlerFunc, method, clientID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rec := httptest.NewRecorder()
+		c := env.e.NewContext(req, rec)
+		echoutil.SetUserID(c, "u1")
+		c.SetParamNames("client_id")
+		c.SetParamValues(clientID)
+		require.NoError(t, h(c))
+		return rec
+	}
+
+	var list struct {
+		Data []AuthorizedAppResp `json:"data"`
+	}
+	rec := call(env.handler.ListMyAuthorizations, http.MethodGet, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "Web", list.Data[0].Name)
+	assert.Equal(t, []string{"profile"}, list.Data[0].Scopes)
+
+	// 取消授权后令牌失效，再次授权需要重新确认
+	status, _ = decode(t, call(env.handler.RevokeMyAuthorization, http.MethodDelete, "web"))
+	require.Equal(t, http.StatusOK, status)
+	var count int64
+	require.NoError(t, env.db.Model(&OAuthToken{}).Where("access_token = ?", accessToken).Count(&count).Error)
+	assert.Zero(t, count)
+
+	status, data = decode(t, env.authorizeRequest(t, url.Values{"client_id": {"web"}, "scope": {"profile"}}, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["consent_required"])
+
+	status, _ = decode(t, call(env.handler.RevokeMyAuthorization, http.MethodDelete, "web"))
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,191 @@
+package auth_oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// OAuthTokenActionReq 令牌内省和吊销请求参数
+type OAuthTokenActionReq struct {
+	Token         string `json:"token" form:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint,omitempty" form:"token_type_hint"` // access_token / refresh_token，仅用于优化查找顺序
+	ClientID      string `json:"client_id,omitempty" form:"client_id"`
+	ClientSecret  string `json:"client_secret,omitempty" form:"client_secret"`
+}
+
+// IntrospectResp 令牌内省响应（RFC 7662 2.2），令牌无效时只返回 active=false
+type IntrospectResp struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+// oauthError 按 RFC 6749 5.2 返回错误，内省和吊销端点供资源服务器和第三方客户端调用，不使用统一响应格式
+func oauthError(c echo.Context, status int, code, description string) error {
+	if code == "invalid_client" {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	return c.JSON(status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// Introspect 令牌内省（RFC 7662），供资源服务器校验令牌
+// 只允许机密客户端调用，可以查询签发给任意客户端的令牌
+func (h *OAuthHandler) Introspect(c echo.Context) error {
+	var req OAuthTokenActionReq
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "请求参数错误")
+	}
+
+	caller, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		if errors.Is(err, errInvalidClient) {
+			return oauthError(c, http.StatusUnauthorized, "invalid_client", "无效的客户端凭证")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证客户端失败")
+	}
+	if caller.IsPublic || caller.Status != 1 {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "客户端无权调用内省端点")
+	}
+	if req.Token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "缺少 token")
+	}
+
+	ctx := c.Request().Context()
+
+	token, isRefresh, err := h.findToken(ctx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询令牌失败")
+	}
+	if token == nil {
+		return c.JSON(http.StatusOK, IntrospectResp{Active: false})
+	}
+
+	expiresAt := token.ExpiresAt
+	if isRefresh {
+		expiresAt = token.RefreshExpiresAt
+	}
+	if !expiresAt.After(time.Now()) {
+		return c.JSON(http.StatusOK, IntrospectResp{Active: false})
+	}
+
+	// 客户端被禁用后其令牌不再有效
+	client, err := h.repo.GetClientByClientID(ctx, token.ClientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询客户端失败")
+	}
+	if client == nil || client.Status != 1 {
+		return c.JSON(http.StatusOK, IntrospectResp{Active: false})
+	}
+
+	resp := IntrospectResp{
+		Active:    true,
+		Scope:     token.Scope,
+		ClientID:  token.ClientID,
+		TokenType: token.TokenType,
+		Exp:       expiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+		Sub:       token.UserID,
+	}
+	if isRefresh {
+		resp.TokenType = TokenTypeHintRefreshToken
+		resp.Scope = token.GrantedScope()
+	}
+	if token.UserID != "" {
+		u, err := h.userRepo.GetByID(ctx, token.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.JSON(http.StatusOK, IntrospectResp{Active: false})
+			}
+			return oauthError(c, http.StatusInternalServerError, "server_error", "查询用户失败")
+		}
+		if u.Status == 0 {
+			return c.JSON(http.StatusOK, IntrospectResp{Active: false})
+		}
+		resp.Username = u.Username
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Revoke 令牌吊销（RFC 7009），客户端只能吊销签发给自己的令牌
+// 吊销刷新令牌时同一令牌族的所有令牌一并失效，吊销访问令牌只使该访问令牌失效
+// 令牌无效或不属于该客户端时同样返回 200，避免泄露令牌信息
+func (h *OAuthHandler) Revoke(c echo.Context) error {
+	var req OAuthTokenActionReq
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "请求参数错误")
+	}
+
+	client, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		if errors.Is(err, errInvalidClient) {
+			return oauthError(c, http.StatusUnauthorized, "invalid_client", "无效的客户端凭证")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证客户端失败")
+	}
+	if req.Token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "缺少 token")
+	}
+
+	ctx := c.Request().Context()
+
+	token, isRefresh, err := h.findToken(ctx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		return oauthError(c, http.StatusServiceUnavailable, "server_error", "查询令牌失败")
+	}
+	if token == nil || token.ClientID != client.ClientID {
+		return c.NoContent(http.StatusOK)
+	}
+
+	if isRefresh {
+		err = h.repo.DeleteTokenFamily(ctx, token.FamilyID)
+	} else {
+		err = h.repo.ExpireAccessToken(ctx, token.ID)
+	}
+	if err != nil {
+		// RFC 7009 2.2.1：暂时无法吊销时返回 503，客户端可以稍后重试
+		return oauthError(c, http.StatusServiceUnavailable, "server_error", "吊销令牌失败")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// findToken 按提示的类型优先查找令牌，找不到时再按另一种类型查找，返回令牌以及它是否为刷新令牌
+func (h *OAuthHandler) findToken(ctx context.Context, value, hint string) (*OAuthToken, bool, error) {
+	lookups := []bool{false, true}
+	if hint == TokenTypeHintRefreshToken {
+		lookups = []bool{true, false}
+	}
+	for _, isRefresh := range lookups {
+		var token *OAuthToken
+		var err error
+		if isRefresh {
+			token, err = h.repo.GetOAuthTokenByRefreshToken(ctx, value)
+		} else {
+			token, err = h.repo.GetOAuthTokenByAccessToken(ctx, value)
+		}
+		if err == nil {
+			return token, isRefresh, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
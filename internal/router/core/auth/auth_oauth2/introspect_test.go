@@ -0,0 +1,124 @@
+package auth_oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"king-starter/internal/router/core/user"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAction 以表单方式调用内省或吊销端点
+func (env *testEnv) tokenAction(t *testing.T, h echo.HandlerFunc, form url.Values, basicAuth ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if len(basicAuth) == 2 {
+		req.SetBasicAuth(basicAuth[0], basicAuth[1])
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, h(env.e.NewContext(req, rec)))
+	return rec
+}
+
+func (env *testEnv) introspect(t *testing.T, token string) IntrospectResp {
+	rec := env.tokenAction(t, env.handler.Introspect, url.Values{"token": {token}}, "service", "svc-secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp IntrospectResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+// passwordTokens 通过密码模式为用户 u1 获取令牌
+func (env *testEnv) passwordTokens(t *testing.T) (string, string) {
+	status, data := env.token(t, url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"Passw0rd!"}, "scope": {"profile"}}, "first-party", "fp-secret")
+	require.Equal(t, http.StatusOK, status)
+	return data["access_token"].(string), data["refresh_token"].(string)
+}
+
+func TestIntrospect(t *testing.T) {
+	env := newTestEnv(t)
+	access, refresh := env.passwordTokens(t)
+
+	resp := env.introspect(t, access)
+	assert.True(t, resp.Active)
+	assert.Equal(t, "first-party", resp.ClientID)
+	assert.Equal(t, "profile", resp.Scope)
+	assert.Equal(t, "alice", resp.Username)
+	assert.Equal(t, "u1", resp.Sub)
+	assert.Equal(t, "Bearer", resp.TokenType)
+	assert.NotZero(t, resp.Exp)
+
+	resp = env.introspect(t, refresh)
+	assert.True(t, resp.Active)
+	assert.Equal(t, TokenTypeHintRefreshToken, resp.TokenType)
+
+	// 未知令牌只返回 active=false
+	rec := env.tokenAction(t, env.handler.Introspect, url.Values{"token": {"unknown"}}, "service", "svc-secret")
+	assert.JSONEq(t, `{"active":false}`, rec.Body.String())
+
+	// 禁用用户后令牌失效
+	require.NoError(t, env.db.Model(&user.CoreUser{}).Where("id = ?", "u1").Update("status", 0).Error)
+	assert.False(t, env.introspect(t, access).Active)
+}
+
+func TestIntrospectRequiresConfidentialClient(t *testing.T) {
+	env := newTestEnv(t)
+	access, _ := env.passwordTokens(t)
+
+	rec := env.tokenAction(t, env.handler.Introspect, url.Values{"token": {access}}, "service", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	var errResp map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Equal(t, "invalid_client", errResp["error"])
+
+	rec = env.tokenAction(t, env.handler.Introspect, url.Values{"token": {access}, "client_id": {"spa"}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	access, refresh := env.passwordTokens(t)
+
+	// 其他客户端不能吊销
+	rec := env.tokenAction(t, env.handler.Revoke, url.Values{"token": {access}}, "web", "web-secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, env.introspect(t, access).Active)
+
+	rec = env.tokenAction(t, env.handler.Revoke, url.Values{"token": {access}, "token_type_hint": {"access_token"}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, env.introspect(t, access).Active)
+
+	// 吊销访问令牌不影响刷新令牌
+	assert.True(t, env.introspect(t, refresh).Active)
+
+	// 无效令牌同样返回 200
+	rec = env.tokenAction(t, env.handler.Revoke, url.Values{"token": {"unknown"}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRevokeRefreshTokenCascades(t *testing.T) {
+	env := newTestEnv(t)
+	_, refresh := env.passwordTokens(t)
+
+	// 刷新后得到同一令牌族的新令牌
+	status, data := env.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}}, "first-party", "fp-secret")
+	require.Equal(t, http.StatusOK, status)
+	newAccess, newRefresh := data["access_token"].(string), data["refresh_token"].(string)
+	require.True(t, env.introspect(t, newAccess).Active)
+
+	rec := env.tokenAction(t, env.handler.Revoke, url.Values{"token": {newRefresh}, "token_type_hint": {"refresh_token"}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.False(t, env.introspect(t, newAccess).Active)
+	assert.False(t, env.introspect(t, newRefresh).Active)
+	status, _ = env.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {newRefresh}}, "first-party", "fp-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
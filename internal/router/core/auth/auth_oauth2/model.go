@@ -6,14 +6,19 @@ import (
 
 // OAuthClient OAuth 客户端模型
 type OAuthClient struct {
-	ID           string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	ClientID     string    `gorm:"type:varchar(100);uniqueIndex" json:"client_id"`
-	ClientSecret string    `gorm:"type:varchar(255)" json:"client_secret"`
-	Name         string    `gorm:"type:varchar(100)" json:"name"`
-	RedirectURI  string    `gorm:"type:varchar(255)" json:"redirect_uri"`
-	Status       int       `gorm:"type:tinyint;default:1" json:"status"` // 1: 启用, 0: 禁用
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID               string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	ClientID         string    `gorm:"type:varchar(100);uniqueIndex" json:"client_id"`
+	ClientSecretHash string    `gorm:"type:varchar(64)" json:"-"` // 密钥的 SHA-256 摘要，明文只在创建和轮换时返回一次
+	Name             string    `gorm:"type:varchar(100)" json:"name"`
+	RedirectURIs     string    `gorm:"type:text" json:"redirect_uris"`       // 允许的回调地址，空格分隔，授权请求必须与其中之一完全一致
+	Status           int       `gorm:"type:tinyint;default:1" json:"status"` // 1: 启用, 0: 禁用
+	IsPublic         bool      `gorm:"default:false" json:"is_public"`       // 公开客户端（SPA、移动端等无法保存密钥），不校验密钥且必须使用 PKCE
+	RequirePKCE      bool      `gorm:"default:false" json:"require_pkce"`    // 机密客户端是否也必须使用 PKCE
+	GrantTypes       string    `gorm:"type:varchar(255)" json:"grant_types"` // 允许的授权类型，空格分隔，为空时只允许授权码和刷新令牌
+	Scopes           string    `gorm:"type:text" json:"scopes"`              // 允许申请的权限范围，空格分隔，为空时只允许 OpenID Connect 标准权限范围
+	RegistrationHash string    `gorm:"type:varchar(64)" json:"-"`            // 注册访问令牌的 SHA-256 摘要，只有动态注册的客户端才有
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -23,14 +28,18 @@ func (OAuthClient) TableName() string {
 
 // OAuthCode OAuth 授权码模型
 type OAuthCode struct {
-	ID          string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	ClientID    string    `gorm:"type:varchar(100);index" json:"client_id"`
-	UserID      string    `gorm:"type:varchar(36);index" json:"user_id"`
-	Code        string    `gorm:"type:varchar(255);uniqueIndex" json:"code"`
-	RedirectURI string    `gorm:"type:varchar(255)" json:"redirect_uri"`
-	Scope       string    `gorm:"type:varchar(255)" json:"scope"`
-	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID                  string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	ClientID            string    `gorm:"type:varchar(100);index" json:"client_id"`
+	UserID              string    `gorm:"type:varchar(36);index" json:"user_id"`
+	Code                string    `gorm:"type:varchar(255);uniqueIndex" json:"code"`
+	RedirectURI         string    `gorm:"type:varchar(255)" json:"redirect_uri"`
+	Scope               string    `gorm:"type:varchar(255)" json:"scope"`
+	CodeChallenge       string    `gorm:"type:varchar(128)" json:"-"` // PKCE code_challenge（RFC 7636），为空表示未使用 PKCE
+	CodeChallengeMethod string    `gorm:"type:varchar(10)" json:"-"`  // PKCE 摘要方式：S256 / plain
+	Nonce               string    `gorm:"type:varchar(255)" json:"-"` // OpenID Connect nonce，写入 id_token
+	AuthTime            time.Time `json:"-"`                          // 用户登录时间，写入 id_token 的 auth_time
+	ExpiresAt           time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt           time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // TableName 指定表名
@@ -40,19 +49,74 @@ func (OAuthCode) TableName() string {
 
 // OAuthToken OAuth 令牌模型
 type OAuthToken struct {
-	ID           string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	ClientID     string    `gorm:"type:varchar(100);index" json:"client_id"`
-	UserID       string    `gorm:"type:varchar(36);index" json:"user_id"`
-	AccessToken  string    `gorm:"type:varchar(255);uniqueIndex" json:"access_token"`
-	RefreshToken string    `gorm:"type:varchar(255);uniqueIndex" json:"refresh_token"`
-	Scope        string    `gorm:"type:varchar(255)" json:"scope"`
-	TokenType    string    `gorm:"type:varchar(50);default:'Bearer'" json:"token_type"`
-	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID               string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	ClientID         string    `gorm:"type:varchar(100);index" json:"client_id"`
+	UserID           string    `gorm:"type:varchar(36);index" json:"user_id"` // 客户端凭证模式签发的令牌为空
+	AccessToken      string    `gorm:"type:varchar(255);uniqueIndex" json:"access_token"`
+	RefreshToken     string    `gorm:"type:varchar(255);index" json:"refresh_token"` // 客户端凭证模式不签发刷新令牌，为空
+	Scope            string    `gorm:"type:varchar(255)" json:"scope"`               // 访问令牌的权限范围
+	RefreshScope     string    `gorm:"type:varchar(255)" json:"refresh_scope"`       // 刷新令牌的权限范围，刷新时 scope 参数只缩小访问令牌的权限范围，为空时与 Scope 相同
+	TokenType        string    `gorm:"type:varchar(50);default:'Bearer'" json:"token_type"`
+	ExpiresAt        time.Time `gorm:"index" json:"expires_at"`                 // 访问令牌过期时间
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`                      // 刷新令牌过期时间
+	FamilyID         string    `gorm:"type:varchar(36);index" json:"family_id"` // 令牌族ID，同一次授权及其刷新产生的令牌相同
+	AuthTime         time.Time `json:"auth_time"`                               // 用户登录时间，刷新令牌时沿用，客户端凭证模式为零值
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
 func (OAuthToken) TableName() string {
 	return "core_user_oauth_tokens"
 }
+
+// GrantedScope 返回刷新令牌的权限范围，即换取新令牌时可以申请的最大范围
+func (t *OAuthToken) GrantedScope() string {
+	if t.RefreshScope != "" {
+		return t.RefreshScope
+	}
+	return t.Scope
+}
+
+// OAuthConsent 用户对客户端的授权记录，再次请求已授权过的权限范围时跳过确认
+type OAuthConsent struct {
+	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string    `gorm:"type:varchar(36);uniqueIndex:idx_oauth_consent_user_client" json:"user_id"`
+	ClientID  string    `gorm:"type:varchar(100);uniqueIndex:idx_oauth_consent_user_client" json:"client_id"`
+	Scope     string    `gorm:"type:varchar(1024)" json:"scope"` // 已授权的权限范围，空格分隔
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OAuthConsent) TableName() string {
+	return "core_user_oauth_consents"
+}
+
+// 设备授权请求状态
+const (
+	DeviceCodePending  = 0 // 等待用户确认
+	DeviceCodeApproved = 1 // 用户已同意，设备可以换取令牌
+	DeviceCodeDenied   = 2 // 用户已拒绝
+)
+
+// OAuthDeviceCode 设备授权请求（RFC 8628）
+type OAuthDeviceCode struct {
+	ID             string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	ClientID       string    `gorm:"type:varchar(100);index" json:"client_id"`
+	DeviceCodeHash string    `gorm:"type:varchar(64);uniqueIndex" json:"-"`         // 设备码的 SHA-256 摘要
+	UserCode       string    `gorm:"type:varchar(16);uniqueIndex" json:"user_code"` // 用户码，规范化为不含分隔符的大写字母
+	Scope          string    `gorm:"type:varchar(1024)" json:"scope"`
+	Status         int       `gorm:"type:tinyint;default:0" json:"status"` // 0: 待确认, 1: 已同意, 2: 已拒绝
+	UserID         string    `gorm:"type:varchar(36)" json:"user_id"`      // 确认授权的用户
+	AuthTime       time.Time `json:"-"`                                    // 确认授权的用户的登录时间
+	Interval       int       `json:"interval"`                             // 轮询间隔（秒），轮询过快时增加
+	LastPolledAt   time.Time `json:"last_polled_at"`
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (OAuthDeviceCode) TableName() string {
+	return "core_user_oauth_device_codes"
+}
@@ -0,0 +1,224 @@
+package auth_oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/jwt"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// OpenID Connect 标准权限范围，决定 id_token 和用户信息端点返回的声明
+const (
+	ScopeOpenID  = "openid"  // 请求 id_token
+	ScopeProfile = "profile" // name、nickname、preferred_username、updated_at
+	ScopeEmail   = "email"   // email、email_verified
+	ScopePhone   = "phone"   // phone_number、phone_number_verified
+)
+
+const (
+	PromptLogin   = "login"   // 要求用户重新登录
+	PromptConsent = "consent" // 要求用户重新确认授权
+)
+
+// hasPrompt 判断空格分隔的 prompt 参数是否包含指定值
+func hasPrompt(prompt, value string) bool {
+	return slices.Contains(strings.Fields(prompt), value)
+}
+
+// DiscoveryResp OpenID Connect 提供方元数据（OpenID Connect Discovery 1.0 第 3 节）
+type DiscoveryResp struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`   // RFC 8628 4
+	RegistrationEndpoint              string   `json:"registration_endpoint,omitempty"` // 开启动态注册时返回
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	PromptValuesSupported             []string `json:"prompt_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// Discovery 公开 OpenID Connect 提供方元数据，客户端据此自动发现各端点地址
+// 返回标准格式的 JSON，不使用统一响应包装
+func (h *OAuthHandler) Discovery(c echo.Context) error {
+	alg, err := h.jwt.SigningAlg()
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "获取签名算法失败")
+	}
+
+	var scopes []string
+	for _, info := range RegisteredScopes() {
+		scopes = append(scopes, info.Name)
+	}
+
+	doc := DiscoveryResp{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             h.endpoint("/api/core/auth/oauth/authorize"),
+		TokenEndpoint:                     h.endpoint("/api/core/auth/oauth/token"),
+		UserinfoEndpoint:                  h.endpoint("/api/core/auth/oauth/userinfo"),
+		JwksURI:                           h.endpoint("/.well-known/jwks.json"),
+		IntrospectionEndpoint:             h.endpoint("/api/core/auth/oauth/introspect"),
+		RevocationEndpoint:                h.endpoint("/api/core/auth/oauth/revoke"),
+		DeviceAuthorizationEndpoint:       h.endpoint("/api/core/auth/oauth/device_authorization"),
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               supportedGrantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{alg},
+		TokenEndpointAuthMethodsSupported: []string{AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodNone},
+		CodeChallengeMethodsSupported:     []string{PKCEMethodS256, PKCEMethodPlain},
+		PromptValuesSupported:             []string{PromptLogin, PromptConsent},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "nickname", "preferred_username", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+	if h.registration.Enabled {
+		doc.RegistrationEndpoint = h.endpoint("/api/core/auth/oauth/register")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, doc)
+}
+
+// endpoint 返回以签发者为前缀的端点地址
+func (h *OAuthHandler) endpoint(path string) string {
+	return strings.TrimSuffix(h.issuer, "/") + path
+}
+
+// userClaims 按权限范围返回用户的标准声明，sub 始终返回
+func userClaims(u *user.CoreUser, scope string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": u.ID}
+	scopes := parseScope(scope)
+	if slices.Contains(scopes, ScopeProfile) {
+		name := u.Nickname
+		if name == "" {
+			name = u.Username
+		}
+		claims["name"] = name
+		claims["nickname"] = u.Nickname
+		claims["preferred_username"] = u.Username
+		claims["updated_at"] = u.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, ScopeEmail) && u.Email != "" {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerifiedAt != nil
+	}
+	if slices.Contains(scopes, ScopePhone) && u.Phone != "" {
+		claims["phone_number"] = u.Phone
+		claims["phone_number_verified"] = u.PhoneVerifiedAt != nil
+	}
+	return claims
+}
+
+// oidcTokenResponse 令牌端点的响应内容，令牌关联用户且包含 openid 权限范围时附带 id_token
+func (h *OAuthHandler) oidcTokenResponse(ctx context.Context, token *OAuthToken, nonce string) (map[string]interface{}, error) {
+	data := tokenResponse(token)
+	if token.UserID == "" || !slices.Contains(parseScope(token.Scope), ScopeOpenID) {
+		return data, nil
+	}
+
+	u, err := h.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := jwtv5.MapClaims(userClaims(u, token.Scope))
+	claims["iss"] = h.issuer
+	claims["aud"] = token.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(h.idTokenTTL).Unix()
+	if !token.AuthTime.IsZero() {
+		claims["auth_time"] = token.AuthTime.Unix()
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	idToken, err := h.jwt.Sign(jwt.TokenUseID, claims)
+	if err != nil {
+		return nil, err
+	}
+	data["id_token"] = idToken
+	return data, nil
+}
+
+// bearerError 按 RFC 6750 3.1 返回受保护资源的错误，未携带令牌时 code 为空
+func bearerError(c echo.Context, status int, code, description string) error {
+	challenge := `Bearer realm="oauth"`
+	if code != "" {
+		challenge += `, error="` + code + `"`
+	}
+	c.Response().Header().Set("WWW-Authenticate", challenge)
+	if code == "" {
+		return c.NoContent(status)
+	}
+	return c.JSON(status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// GetUserInfo 用户信息端点（OpenID Connect Core 5.3），按访问令牌的权限范围返回用户的标准声明
+// 未包含 openid 的普通 OAuth2 访问令牌同样可以调用，至少返回 sub
+func (h *OAuthHandler) GetUserInfo(c echo.Context) error {
+	accessToken, ok := middleware.BearerToken(c)
+	if !ok {
+		return bearerError(c, http.StatusUnauthorized, "", "")
+	}
+
+	ctx := c.Request().Context()
+
+	// 验证访问令牌
+	token, err := h.repo.GetOAuthTokenByAccessToken(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return bearerError(c, http.StatusUnauthorized, "invalid_token", "无效的访问令牌")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "验证令牌失败")
+	}
+
+	// 检查令牌是否过期
+	if token.ExpiresAt.Before(time.Now()) {
+		return bearerError(c, http.StatusUnauthorized, "invalid_token", "访问令牌已过期")
+	}
+
+	// 客户端凭证模式的令牌不代表任何用户
+	if token.UserID == "" {
+		return bearerError(c, http.StatusUnauthorized, "invalid_token", "访问令牌未关联用户")
+	}
+
+	u, err := h.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return bearerError(c, http.StatusUnauthorized, "invalid_token", "用户不存在")
+		}
+		return oauthError(c, http.StatusInternalServerError, "server_error", "查询用户失败")
+	}
+	if u.Status == 0 {
+		return bearerError(c, http.StatusUnauthorized, "invalid_token", "用户已被禁用")
+	}
+
+	return c.JSON(http.StatusOK, userClaims(u, token.Scope))
+}
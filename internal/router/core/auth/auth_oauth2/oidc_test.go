@@ -0,0 +1,163 @@
+package auth_oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/jwt"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseIDToken 使用测试密钥验证并解析 id_token
+func parseIDToken(t *testing.T, raw interface{}) jwtv5.MapClaims {
+	require.IsType(t, "", raw)
+	claims := jwtv5.MapClaims{}
+	_, err := jwtv5.ParseWithClaims(raw.(string), claims, func(*jwtv5.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	return claims
+}
+
+func (env *testEnv) userInfo(t *testing.T, accessToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	if accessToken != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.GetUserInfo(env.e.NewContext(req, rec)))
+	return rec
+}
+
+func TestOIDCAuthorizationCode(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.db.Model(&user.CoreUser{}).Where("id = ?", "u1").
+		Updates(map[string]interface{}{"nickname": "Alice", "email": "alice@example.com", "email_verified_at": time.Now()}).Error)
+
+	code, status := env.authorize(t, url.Values{"client_id": {"web"}, "scope": {"openid profile email"}, "nonce": {"n-123"}})
+	require.Equal(t, http.StatusOK, status)
+	status, data := env.token(t, url.Values{"grant_type": {"authorization_code"}, "code": {code}}, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+
+	claims := parseIDToken(t, data["id_token"])
+	assert.Equal(t, config.DefaultAuthConfig().OAuth2.Issuer, claims["iss"])
+	assert.Equal(t, "web", claims["aud"])
+	assert.Equal(t, "u1", claims["sub"])
+	assert.Equal(t, "n-123", claims["nonce"])
+	assert.Equal(t, "Alice", claims["name"])
+	assert.Equal(t, "alice@example.com", claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	assert.NotContains(t, claims, "phone_number")
+	assert.NotZero(t, claims["auth_time"])
+	assert.Equal(t, jwt.TokenUseID, claims["token_use"])
+
+	// id_token 不能当作访问令牌调用接口
+	_, err := env.jwt.ParseToken(data["id_token"].(string))
+	assert.ErrorIs(t, err, jwt.ErrTokenUse)
+
+	// 刷新时签发新的 id_token，不再携带 nonce
+	status, data = env.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {data["refresh_token"].(string)}}, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+	claims = parseIDToken(t, data["id_token"])
+	assert.Equal(t, "u1", claims["sub"])
+	assert.NotContains(t, claims, "nonce")
+	assert.NotZero(t, claims["auth_time"])
+
+	// 未请求 openid 时不签发 id_token
+	code, status = env.authorize(t, url.Values{"client_id": {"web"}, "scope": {"profile"}})
+	require.Equal(t, http.StatusOK, status)
+	status, data = env.token(t, url.Values{"grant_type": {"authorization_code"}, "code": {code}}, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, data, "id_token")
+}
+
+func TestUserInfo(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.db.Model(&user.CoreUser{}).Where("id = ?", "u1").
+		Updates(map[string]interface{}{"email": "alice@example.com", "phone": "13800000000"}).Error)
+
+	code, status := env.authorize(t, url.Values{"client_id": {"web"}, "scope": {"openid email"}})
+	require.Equal(t, http.StatusOK, status)
+	status, data := env.token(t, url.Values{"grant_type": {"authorization_code"}, "code": {code}}, "web", "web-secret")
+	require.Equal(t, http.StatusOK, status)
+
+	// 只返回权限范围内的声明
+	rec := env.userInfo(t, data["access_token"].(string))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &claims))
+	assert.Equal(t, map[string]interface{}{"sub": "u1", "email": "alice@example.com", "email_verified": false}, claims)
+
+	// 缺少令牌、无效令牌
+	rec = env.userInfo(t, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="oauth"`, rec.Header().Get("WWW-Authenticate"))
+	rec = env.userInfo(t, "unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	// 客户端凭证模式的令牌不关联用户
+	status, data = env.token(t, url.Values{"grant_type": {"client_credentials"}}, "service", "svc-secret")
+	require.Equal(t, http.StatusOK, status)
+	rec = env.userInfo(t, data["access_token"].(string))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthorizePromptLogin(t *testing.T) {
+	env := newTestEnv(t)
+	params := url.Values{"client_id": {"web"}, "scope": {"openid"}, "prompt": {"login"}}
+
+	// 登录时间过早，要求重新登录；刷新得到的访问令牌签发时间较新，但登录时间不变
+	issuedAt := time.Now().Add(-10 * time.Minute)
+	stale, err := env.jwt.GenerateTokenWithAuthTime("u1", "alice", "", issuedAt)
+	require.NoError(t, err)
+	status, data := decode(t, env.authorizeRequest(t, params, stale))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, data["login_required"])
+
+	// 刚刚登录过的会话继续授权流程
+	status, data = decode(t, env.authorizeRequest(t, params, env.bearer))
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, data, "login_required")
+	assert.Equal(t, true, data["consent_required"])
+
+	// 未要求重新登录时旧会话同样可用，auth_time 为会话的登录时间
+	params.Del("prompt")
+	params.Set("nonce", "n1")
+	status, data = decode(t, env.authorizeRequest(t, params, stale))
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, true, data["consent_required"])
+	code, status := env.authorize(t, params)
+	require.Equal(t, http.StatusOK, status)
+	var stored OAuthCode
+	require.NoError(t, env.db.Where("code = ?", code).First(&stored).Error)
+	assert.Equal(t, "n1", stored.Nonce)
+	assert.False(t, stored.AuthTime.IsZero())
+}
+
+func TestDiscovery(t *testing.T) {
+	env := newTestEnv(t)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, env.handler.Discovery(env.e.NewContext(httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil), rec)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc DiscoveryResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "http://localhost:8080", doc.Issuer)
+	assert.Equal(t, "http://localhost:8080/api/core/auth/oauth/token", doc.TokenEndpoint)
+	assert.Equal(t, "http://localhost:8080/.well-known/jwks.json", doc.JwksURI)
+	assert.Equal(t, []string{"HS256"}, doc.IDTokenSigningAlgValuesSupported)
+	assert.Contains(t, doc.ScopesSupported, ScopeOpenID)
+	assert.Empty(t, doc.RegistrationEndpoint, "未开启动态注册")
+}
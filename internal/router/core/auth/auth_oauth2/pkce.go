@@ -0,0 +1,46 @@
+package auth_oauth2
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+const (
+	PKCEMethodS256  = "S256"  // code_challenge = BASE64URL(SHA256(code_verifier))
+	PKCEMethodPlain = "plain" // code_challenge = code_verifier，仅用于无法计算 SHA256 的客户端
+)
+
+// pkcePattern code_challenge 和 code_verifier 的格式：43~128 位 unreserved 字符（RFC 7636 4.1）
+var pkcePattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// pkceRequired 客户端是否必须使用 PKCE，公开客户端没有密钥，只能依靠 PKCE 防止授权码被截获后使用
+func pkceRequired(client *OAuthClient) bool {
+	return client.IsPublic || client.RequirePKCE
+}
+
+// normalizePKCEMethod 校验摘要方式，未指定时按 RFC 7636 默认为 plain，不支持的方式返回 false
+func normalizePKCEMethod(method string) (string, bool) {
+	switch method {
+	case "":
+		return PKCEMethodPlain, true
+	case PKCEMethodS256, PKCEMethodPlain:
+		return method, true
+	default:
+		return "", false
+	}
+}
+
+// verifyPKCE 使用 code_verifier 校验授权请求中的 code_challenge
+func verifyPKCE(method, challenge, verifier string) bool {
+	if !pkcePattern.MatchString(verifier) {
+		return false
+	}
+	expected := verifier
+	if method == PKCEMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,281 @@
+package auth_oauth2
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"king-starter/config"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/logx"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 令牌端点的客户端认证方式（RFC 7591 2）
+const (
+	AuthMethodNone              = "none"                // 公开客户端
+	AuthMethodClientSecretBasic = "client_secret_basic" // HTTP Basic 认证
+	AuthMethodClientSecretPost  = "client_secret_post"  // 表单参数
+)
+
+// ClientRegistrationReq 动态注册请求参数（RFC 7591 2），更新注册信息（RFC 7592 2.2）时同样使用
+type ClientRegistrationReq struct {
+	ClientID                string   `json:"client_id,omitempty"` // 仅更新时携带，必须与地址中的一致
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	Scope                   string   `json:"scope"`                      // 空格分隔
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"` // 默认 client_secret_basic，none 表示公开客户端
+}
+
+// ClientRegistrationResp 客户端信息响应（RFC 7591 3.2.1、RFC 7592 3）
+type ClientRegistrationResp struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"` // 只在注册时返回
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at"` // 0 表示永不过期
+	RegistrationAccessToken string   `json:"registration_access_token"`
+	RegistrationClientURI   string   `json:"registration_client_uri"`
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	Scope                   string   `json:"scope,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+}
+
+// apply 校验注册元数据并写入客户端
+// 动态注册的客户端不受信任，不允许使用密码模式，授权类型和权限范围只能在配置允许的范围内选择，回调地址只能使用 https
+func (req *ClientRegistrationReq) apply(client *OAuthClient, cfg config.ClientRegistrationConfig) error {
+	switch req.TokenEndpointAuthMethod {
+	case "", AuthMethodClientSecretBasic, AuthMethodClientSecretPost:
+		client.IsPublic = false
+	case AuthMethodNone:
+		client.IsPublic = true
+	default:
+		return &clientMetadataError{code: "invalid_client_metadata", msg: "不支持的 token_endpoint_auth_method"}
+	}
+	for _, responseType := range req.ResponseTypes {
+		if responseType != "code" {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "不支持的 response_type: " + responseType}
+		}
+	}
+	if slices.Contains(req.GrantTypes, GrantTypePassword) {
+		return &clientMetadataError{code: "invalid_client_metadata", msg: "动态注册的客户端不能使用密码模式"}
+	}
+	allowedGrants := cfg.AllowedGrantTypes
+	if len(allowedGrants) == 0 {
+		allowedGrants = defaultGrantTypes
+	}
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = defaultGrantTypes
+	}
+	for _, grantType := range grantTypes {
+		if !slices.Contains(allowedGrants, grantType) {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "动态注册的客户端不能使用该授权类型: " + grantType}
+		}
+	}
+	scopes := parseScope(req.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(standardScopes, scope) && !slices.Contains(cfg.AllowedScopes, scope) {
+			return &clientMetadataError{code: "invalid_client_metadata", msg: "动态注册的客户端不能申请该权限范围: " + scope}
+		}
+	}
+
+	name := req.ClientName
+	if name == "" {
+		name = client.ClientID
+	}
+	metadata := ClientMetadata{
+		Name:         name,
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       scopes,
+	}
+	return metadata.apply(client, redirectPolicy{})
+}
+
+// Register 动态注册客户端（RFC 7591），需携带配置的初始访问令牌
+func (h *OAuthHandler) Register(c echo.Context) error {
+	token, ok := middleware.BearerToken(c)
+	if !ok {
+		return bearerError(c, http.StatusUnauthorized, "", "")
+	}
+	if !h.validInitialAccessToken(token) {
+		return bearerError(c, http.StatusUnauthorized, "invalid_token", "无效的初始访问令牌")
+	}
+
+	var req ClientRegistrationReq
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", "请求参数错误")
+	}
+
+	client := &OAuthClient{
+		ID:       uuid.New().String(),
+		ClientID: uuid.New().String(),
+		Status:   1,
+	}
+	if err := req.apply(client, h.registration); err != nil {
+		return clientMetadataErrorResponse(c, err)
+	}
+
+	var secret string
+	if !client.IsPublic {
+		secret, client.ClientSecretHash = newClientSecret()
+	}
+	registrationToken := cryptoutil.RandomToken(32)
+	client.RegistrationHash = cryptoutil.SHA256Hex(registrationToken)
+
+	if err := h.repo.CreateClient(c.Request().Context(), client); err != nil {
+		logx.Error("failed to register oauth client", "error", err)
+		return oauthError(c, http.StatusInternalServerError, "server_error", "注册客户端失败")
+	}
+
+	resp := h.registrationResp(client, req.TokenEndpointAuthMethod, registrationToken)
+	resp.ClientSecret = secret
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// GetRegistration 读取客户端注册信息（RFC 7592 2.1）
+// 每次读取和更新都会签发新的注册访问令牌，旧令牌立即失效
+func (h *OAuthHandler) GetRegistration(c echo.Context) error {
+	client, err := h.registeredClient(c)
+	if err != nil {
+		return registrationAuthError(c, err)
+	}
+	return h.rotateRegistration(c, client, "")
+}
+
+// UpdateRegistration 更新客户端注册信息（RFC 7592 2.2），请求内容整体替换原有元数据
+// 客户端类型在注册后不能修改，token_endpoint_auth_method 必须与注册时一致
+func (h *OAuthHandler) UpdateRegistration(c echo.Context) error {
+	client, err := h.registeredClient(c)
+	if err != nil {
+		return registrationAuthError(c, err)
+	}
+
+	var req ClientRegistrationReq
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", "请求参数错误")
+	}
+	if req.ClientID != client.ClientID {
+		return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", "client_id 不匹配")
+	}
+
+	isPublic := client.IsPublic
+	if err := req.apply(client, h.registration); err != nil {
+		return clientMetadataErrorResponse(c, err)
+	}
+	if client.IsPublic != isPublic {
+		return oauthError(c, http.StatusBadRequest, "invalid_client_metadata", "不能修改 token_endpoint_auth_method")
+	}
+	return h.rotateRegistration(c, client, req.TokenEndpointAuthMethod)
+}
+
+// DeleteRegistration 删除客户端注册信息（RFC 7592 2.3），同时删除其令牌、授权码和用户授权记录
+func (h *OAuthHandler) DeleteRegistration(c echo.Context) error {
+	client, err := h.registeredClient(c)
+	if err != nil {
+		return registrationAuthError(c, err)
+	}
+	if err := h.repo.DeleteClient(c.Request().Context(), client.ClientID); err != nil {
+		logx.Error("failed to delete oauth client registration", "client_id", client.ClientID, "error", err)
+		return oauthError(c, http.StatusInternalServerError, "server_error", "删除客户端失败")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// errInvalidRegistrationToken 注册访问令牌缺失、无效，或客户端不存在
+var errInvalidRegistrationToken = errors.New("invalid registration access token")
+
+// registeredClient 根据地址中的 client_id 和注册访问令牌查找客户端
+// 客户端不存在和令牌错误返回相同的错误，避免泄露客户端是否存在（RFC 7592 2）
+func (h *OAuthHandler) registeredClient(c echo.Context) (*OAuthClient, error) {
+	token, ok := middleware.BearerToken(c)
+	if !ok {
+		return nil, errInvalidRegistrationToken
+	}
+	client, err := h.repo.GetClientByClientID(c.Request().Context(), c.Param("client_id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRegistrationToken
+		}
+		return nil, err
+	}
+	if client.RegistrationHash == "" || !cryptoutil.EqualHash(token, client.RegistrationHash) {
+		return nil, errInvalidRegistrationToken
+	}
+	return client, nil
+}
+
+// rotateRegistration 签发新的注册访问令牌，保存客户端并返回客户端信息
+func (h *OAuthHandler) rotateRegistration(c echo.Context, client *OAuthClient, authMethod string) error {
+	registrationToken := cryptoutil.RandomToken(32)
+	client.RegistrationHash = cryptoutil.SHA256Hex(registrationToken)
+	if err := h.repo.UpdateClient(c.Request().Context(), client); err != nil {
+		logx.Error("failed to update oauth client registration", "client_id", client.ClientID, "error", err)
+		return oauthError(c, http.StatusInternalServerError, "server_error", "更新客户端失败")
+	}
+	return c.JSON(http.StatusOK, h.registrationResp(client, authMethod, registrationToken))
+}
+
+// registrationResp 构造客户端信息响应，authMethod 为空时按客户端类型取默认值
+func (h *OAuthHandler) registrationResp(client *OAuthClient, authMethod, registrationToken string) ClientRegistrationResp {
+	if client.IsPublic {
+		authMethod = AuthMethodNone
+	} else if authMethod == "" {
+		authMethod = AuthMethodClientSecretBasic
+	}
+	issuedAt := client.CreatedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	return ClientRegistrationResp{
+		ClientID:                client.ClientID,
+		ClientIDIssuedAt:        issuedAt.Unix(),
+		RegistrationAccessToken: registrationToken,
+		RegistrationClientURI:   h.endpoint("/api/core/auth/oauth/register/" + client.ClientID),
+		ClientName:              client.Name,
+		RedirectURIs:            client.RedirectURIList(),
+		GrantTypes:              client.AllowedGrantTypes(),
+		ResponseTypes:           []string{"code"},
+		Scope:                   client.Scopes,
+		TokenEndpointAuthMethod: authMethod,
+	}
+}
+
+// validInitialAccessToken 常量时间比较初始访问令牌
+func (h *OAuthHandler) validInitialAccessToken(token string) bool {
+	valid := false
+	for _, expected := range h.registration.InitialAccessTokens {
+		if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// clientMetadataErrorResponse 将元数据校验错误转换为 RFC 7591 3.2.2 错误响应
+func clientMetadataErrorResponse(c echo.Context, err error) error {
+	var metaErr *clientMetadataError
+	if errors.As(err, &metaErr) {
+		return oauthError(c, http.StatusBadRequest, metaErr.code, metaErr.msg)
+	}
+	return oauthError(c, http.StatusInternalServerError, "server_error", "注册客户端失败")
+}
+
+// registrationAuthError 将 registeredClient 返回的错误转换为响应
+func registrationAuthError(c echo.Context, err error) error {
+	if errors.Is(err, errInvalidRegistrationToken) {
+		return bearerError(c, http.StatusUnauthorized, "invalid_token", "无效的注册访问令牌")
+	}
+	return oauthError(c, http.StatusInternalServerError, "server_error", "查询客户端失败")
+}
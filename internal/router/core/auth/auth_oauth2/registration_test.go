@@ -0,0 +1,183 @@
+package auth_oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registration 调用动态注册端点，bearer 为初始访问令牌或注册访问令牌
+func (env *testEnv) registration(t *testing.T, h echo.HandlerFunc, method, clientID, bearer string, body interface{}) *httptest.ResponseRecorder {
+	raw := ""
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		raw = string(b)
+	}
+	req := httptest.NewRequest(method, "/oauth/register", strings.NewReader(raw))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if bearer != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	c := env.e.NewContext(req, rec)
+	c.SetParamNames("client_id")
+	c.SetParamValues(clientID)
+	require.NoError(t, h(c))
+	return rec
+}
+
+func newRegistrationEnv(t *testing.T) *testEnv {
+	env := newTestEnv(t)
+	env.handler.registration.Enabled = true
+	env.handler.registration.InitialAccessTokens = []string{"initial-token"}
+	env.handler.registration.AllowedScopes = []string{"reports:read"}
+	env.handler.registration.AllowedGrantTypes = []string{"authorization_code", "refresh_token", "client_credentials"}
+	return env
+}
+
+func TestRegisterClient(t *testing.T) {
+	env := newRegistrationEnv(t)
+	metadata := map[string]interface{}{
+		"client_name":   "Build Bot",
+		"redirect_uris": []string{"https://bot.example.com/cb"},
+		"grant_types":   []string{"authorization_code", "refresh_token", "client_credentials"},
+		"scope":         "reports:read",
+	}
+
+	// 缺少或错误的初始访问令牌
+	rec := env.registration(t, env.handler.Register, http.MethodPost, "", "", metadata)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "wrong", metadata)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", metadata)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var resp ClientRegistrationResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.ClientSecret)
+	assert.NotEmpty(t, resp.RegistrationAccessToken)
+	assert.Equal(t, "http://localhost:8080/api/core/auth/oauth/register/"+resp.ClientID, resp.RegistrationClientURI)
+	assert.Equal(t, AuthMethodClientSecretBasic, resp.TokenEndpointAuthMethod)
+	assert.Equal(t, "Build Bot", resp.ClientName)
+
+	// 注册的客户端可以直接使用
+	status, _ := env.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"reports:read"}}, resp.ClientID, resp.ClientSecret)
+	assert.Equal(t, http.StatusOK, status)
+
+	// 读取时签发新的注册访问令牌，旧令牌失效
+	rec = env.registration(t, env.handler.GetRegistration, http.MethodGet, resp.ClientID, resp.RegistrationAccessToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var read ClientRegistrationResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &read))
+	assert.Empty(t, read.ClientSecret)
+	assert.NotEqual(t, resp.RegistrationAccessToken, read.RegistrationAccessToken)
+	assert.Equal(t, []string{"https://bot.example.com/cb"}, read.RedirectURIs)
+	rec = env.registration(t, env.handler.GetRegistration, http.MethodGet, resp.ClientID, resp.RegistrationAccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// 更新注册信息
+	update := map[string]interface{}{
+		"client_id":     resp.ClientID,
+		"client_name":   "Build Bot v2",
+		"redirect_uris": []string{"https://bot.example.com/v2"},
+	}
+	rec = env.registration(t, env.handler.UpdateRegistration, http.MethodPut, resp.ClientID, read.RegistrationAccessToken, update)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var updated ClientRegistrationResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, "Build Bot v2", updated.ClientName)
+	assert.Equal(t, []string{"authorization_code", "refresh_token"}, updated.GrantTypes)
+
+	// 不能修改客户端类型
+	update["token_endpoint_auth_method"] = AuthMethodNone
+	rec = env.registration(t, env.handler.UpdateRegistration, http.MethodPut, resp.ClientID, updated.RegistrationAccessToken, update)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// 其他客户端的注册访问令牌无效
+	rec = env.registration(t, env.handler.GetRegistration, http.MethodGet, "web", updated.RegistrationAccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// 删除注册后客户端不可用
+	rec = env.registration(t, env.handler.DeleteRegistration, http.MethodDelete, resp.ClientID, updated.RegistrationAccessToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	var count int64
+	require.NoError(t, env.db.Model(&OAuthClient{}).Where("client_id = ?", resp.ClientID).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, env.db.Model(&OAuthToken{}).Where("client_id = ?", resp.ClientID).Count(&count).Error)
+	assert.Zero(t, count)
+	rec = env.registration(t, env.handler.GetRegistration, http.MethodGet, resp.ClientID, updated.RegistrationAccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRegisterClientMetadataErrors(t *testing.T) {
+	env := newRegistrationEnv(t)
+
+	cases := []struct {
+		body map[string]interface{}
+		code string
+	}{
+		{map[string]interface{}{"client_name": "A"}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb#x"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"javascript:alert(1)"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"data:text/html,<script>alert(1)</script>"}}, "invalid_redirect_uri"},
+		// 动态注册的客户端只能使用 https，即使是回环地址或管理员可用的自定义协议
+		{map[string]interface{}{"redirect_uris": []string{"http://127.0.0.1:8400/cb"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"com.example.tool:/cb"}}, "invalid_redirect_uri"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}, "grant_types": []string{"password"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}, "grant_types": []string{"urn:ietf:params:oauth:grant-type:device_code"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}, "scope": "openid reports:write"}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}, "response_types": []string{"token"}}, "invalid_client_metadata"},
+		{map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}, "token_endpoint_auth_method": "private_key_jwt"}, "invalid_client_metadata"},
+	}
+	for _, tc := range cases {
+		rec := env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", tc.body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, tc.body)
+		var errResp map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+		assert.Equal(t, tc.code, errResp["error"], tc.body)
+	}
+
+	// 公开客户端没有密钥，名称默认为 client_id
+	rec := env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", map[string]interface{}{
+		"redirect_uris":              []string{"https://tool.example.com/cb"},
+		"token_endpoint_auth_method": AuthMethodNone,
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var resp ClientRegistrationResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Empty(t, resp.ClientSecret)
+	assert.Equal(t, resp.ClientID, resp.ClientName)
+	assert.Equal(t, AuthMethodNone, resp.TokenEndpointAuthMethod)
+
+	// 未配置允许的授权类型和权限范围时，只能使用授权码模式和 OIDC 标准权限范围
+	env.handler.registration.AllowedScopes = nil
+	env.handler.registration.AllowedGrantTypes = nil
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", map[string]interface{}{
+		"redirect_uris": []string{"https://a.example.com/cb"},
+		"grant_types":   []string{"client_credentials"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", map[string]interface{}{
+		"redirect_uris": []string{"https://a.example.com/cb"},
+		"scope":         "reports:read",
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", map[string]interface{}{
+		"redirect_uris": []string{"https://a.example.com/cb"},
+		"scope":         "openid profile",
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// 未配置初始访问令牌时拒绝注册
+	env.handler.registration.InitialAccessTokens = nil
+	rec = env.registration(t, env.handler.Register, http.MethodPost, "", "initial-token", map[string]interface{}{"redirect_uris": []string{"https://a.example.com/cb"}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -2,9 +2,15 @@ package auth_oauth2
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"king-starter/internal/response"
 	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/gormutil"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -28,14 +34,104 @@ func (r *Repository) GetClientByClientID(ctx context.Context, clientID string) (
 	return &client, nil
 }
 
-// GetClientByClientIDAndSecret 根据客户端ID和密钥获取客户端
-func (r *Repository) GetClientByClientIDAndSecret(ctx context.Context, clientID, clientSecret string) (*OAuthClient, error) {
-	var client OAuthClient
-	err := r.db.WithContext(ctx).Where("client_id = ? AND client_secret = ?", clientID, clientSecret).First(&client).Error
-	if err != nil {
-		return nil, err
+// CreateClient 创建客户端
+func (r *Repository) CreateClient(ctx context.Context, client *OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+// UpdateClient 更新客户端的全部字段
+func (r *Repository) UpdateClient(ctx context.Context, client *OAuthClient) error {
+	return r.db.WithContext(ctx).Save(client).Error
+}
+
+// UpdateClientSecret 更新客户端密钥摘要
+func (r *Repository) UpdateClientSecret(ctx context.Context, clientID, secretHash string) error {
+	return r.db.WithContext(ctx).Model(&OAuthClient{}).Where("client_id = ?", clientID).Update("client_secret_hash", secretHash).Error
+}
+
+// UpdateClientStatus 更新客户端状态
+func (r *Repository) UpdateClientStatus(ctx context.Context, clientID string, status int) error {
+	return r.db.WithContext(ctx).Model(&OAuthClient{}).Where("client_id = ?", clientID).Update("status", status).Error
+}
+
+// PageClients 分页查询客户端，name 模糊匹配，status 为 nil 时不过滤
+func (r *Repository) PageClients(ctx context.Context, pq *response.PageQuery, name string, status *int) (*response.PageResult[OAuthClient], error) {
+	scopes := make([]func(*gorm.DB) *gorm.DB, 0, 2)
+	if name != "" {
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name LIKE ?", "%"+name+"%")
+		})
 	}
-	return &client, nil
+	if status != nil {
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", *status)
+		})
+	}
+	return gormutil.NewBaseRepo[OAuthClient](r.db).PaginationWithScopes(ctx, pq, scopes...)
+}
+
+// DeleteClient 删除客户端及其令牌、授权码和用户授权记录
+func (r *Repository) DeleteClient(ctx context.Context, clientID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&OAuthToken{}, &OAuthCode{}, &OAuthConsent{}, &OAuthClient{}} {
+			if err := tx.Where("client_id = ?", clientID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteClientGrants 删除客户端的所有令牌和未使用的授权码
+func (r *Repository) DeleteClientGrants(ctx context.Context, clientID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", clientID).Delete(&OAuthToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("client_id = ?", clientID).Delete(&OAuthCode{}).Error
+	})
+}
+
+// MigrateLegacyClients 迁移旧版客户端表：
+// 单个 redirect_uri 迁移到 redirect_uris，明文 client_secret 替换为摘要，迁移完成后删除旧列
+func (r *Repository) MigrateLegacyClients(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	migrator := db.Migrator()
+
+	if migrator.HasColumn(&OAuthClient{}, "redirect_uri") {
+		err := db.Model(&OAuthClient{}).
+			Where("(redirect_uris IS NULL OR redirect_uris = '') AND redirect_uri IS NOT NULL").
+			Update("redirect_uris", gorm.Expr("redirect_uri")).Error
+		if err != nil {
+			return err
+		}
+		if err := migrator.DropColumn(&OAuthClient{}, "redirect_uri"); err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasColumn(&OAuthClient{}, "client_secret") {
+		var legacy []struct {
+			ID           string
+			ClientSecret string
+		}
+		err := db.Model(&OAuthClient{}).Select("id", "client_secret").
+			Where("client_secret IS NOT NULL AND client_secret <> ''").Scan(&legacy).Error
+		if err != nil {
+			return err
+		}
+		for _, l := range legacy {
+			err := db.Model(&OAuthClient{}).Where("id = ?", l.ID).
+				Update("client_secret_hash", cryptoutil.SHA256Hex(l.ClientSecret)).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := migrator.DropColumn(&OAuthClient{}, "client_secret"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // CreateOAuthCode 创建 OAuth 授权码
@@ -53,9 +149,22 @@ func (r *Repository) GetOAuthCodeByCode(ctx context.Context, code string) (*OAut
 	return &authCode, nil
 }
 
-// DeleteOAuthCode 删除 OAuth 授权码
-func (r *Repository) DeleteOAuthCode(ctx context.Context, code string) error {
-	return r.db.WithContext(ctx).Where("code = ?", code).Delete(&OAuthCode{}).Error
+// RedeemOAuthCode 在同一事务中删除授权码并创建令牌
+// 授权码只能使用一次，并发请求中只有删除成功的一方创建令牌，返回 false 表示授权码已被使用
+func (r *Repository) RedeemOAuthCode(ctx context.Context, codeID string, token *OAuthToken) (bool, error) {
+	redeemed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", codeID).Delete(&OAuthCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		redeemed = true
+		return tx.Create(token).Error
+	})
+	return redeemed, err
 }
 
 // CreateOAuthToken 创建 OAuth 令牌
@@ -83,9 +192,162 @@ func (r *Repository) GetOAuthTokenByAccessToken(ctx context.Context, accessToken
 	return &token, nil
 }
 
-// DeleteOAuthToken 删除 OAuth 令牌
-func (r *Repository) DeleteOAuthToken(ctx context.Context, accessToken string) error {
-	return r.db.WithContext(ctx).Where("access_token = ?", accessToken).Delete(&OAuthToken{}).Error
+// RotateOAuthToken 在同一事务中删除旧令牌并创建新令牌
+// 刷新令牌只能使用一次，并发请求中只有删除成功的一方创建新令牌，返回 false 表示旧令牌已被使用
+func (r *Repository) RotateOAuthToken(ctx context.Context, oldID string, newToken *OAuthToken) (bool, error) {
+	rotated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", oldID).Delete(&OAuthToken{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		rotated = true
+		return tx.Create(newToken).Error
+	})
+	return rotated, err
+}
+
+// ExpireAccessToken 使访问令牌立即过期，刷新令牌不受影响
+func (r *Repository) ExpireAccessToken(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&OAuthToken{}).Where("id = ?", id).Update("expires_at", time.Now()).Error
+}
+
+// DeleteTokenFamily 删除令牌族中的所有令牌
+func (r *Repository) DeleteTokenFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Where("family_id = ?", familyID).Delete(&OAuthToken{}).Error
+}
+
+// DeleteUserTokens 删除用户的所有令牌
+func (r *Repository) DeleteUserTokens(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&OAuthToken{}).Error
+}
+
+// DeleteUserClientGrants 删除用户在指定客户端上的所有令牌和未使用的授权码
+func (r *Repository) DeleteUserClientGrants(ctx context.Context, userID, clientID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&OAuthToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&OAuthCode{}).Error
+	})
+}
+
+// GetConsent 获取用户对客户端的授权记录
+func (r *Repository) GetConsent(ctx context.Context, userID, clientID string) (*OAuthConsent, error) {
+	var consent OAuthConsent
+	err := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent 保存授权记录，已存在时合并权限范围
+func (r *Repository) SaveConsent(ctx context.Context, userID, clientID, scope string) error {
+	consent, err := r.GetConsent(ctx, userID, clientID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return r.db.WithContext(ctx).Create(&OAuthConsent{
+			ID:       uuid.New().String(),
+			UserID:   userID,
+			ClientID: clientID,
+			Scope:    joinScope(parseScope(scope)),
+		}).Error
+	}
+	return r.db.WithContext(ctx).Model(consent).Update("scope", mergeScope(consent.Scope, scope)).Error
+}
+
+// ListConsentsByUserID 获取用户的所有授权记录，最近授权的在前
+func (r *Repository) ListConsentsByUserID(ctx context.Context, userID string) ([]OAuthConsent, error) {
+	var consents []OAuthConsent
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error
+	return consents, err
+}
+
+// DeleteConsent 删除授权记录，返回是否删除成功
+func (r *Repository) DeleteConsent(ctx context.Context, userID, clientID string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&OAuthConsent{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListClientsByClientIDs 根据客户端ID批量获取客户端
+func (r *Repository) ListClientsByClientIDs(ctx context.Context, clientIDs []string) ([]OAuthClient, error) {
+	var clients []OAuthClient
+	err := r.db.WithContext(ctx).Where("client_id IN ?", clientIDs).Find(&clients).Error
+	return clients, err
+}
+
+// CreateDeviceCode 创建设备授权请求，同时清理已过期的请求以释放用户码
+func (r *Repository) CreateDeviceCode(ctx context.Context, deviceCode *OAuthDeviceCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&OAuthDeviceCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(deviceCode).Error
+	})
+}
+
+// GetDeviceCodeByHash 根据设备码摘要获取设备授权请求
+func (r *Repository) GetDeviceCodeByHash(ctx context.Context, deviceCodeHash string) (*OAuthDeviceCode, error) {
+	var deviceCode OAuthDeviceCode
+	err := r.db.WithContext(ctx).Where("device_code_hash = ?", deviceCodeHash).First(&deviceCode).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deviceCode, nil
+}
+
+// GetDeviceCodeByUserCode 根据用户码获取设备授权请求
+func (r *Repository) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*OAuthDeviceCode, error) {
+	var deviceCode OAuthDeviceCode
+	err := r.db.WithContext(ctx).Where("user_code = ?", userCode).First(&deviceCode).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deviceCode, nil
+}
+
+// TouchDeviceCode 记录设备的轮询时间和新的轮询间隔
+func (r *Repository) TouchDeviceCode(ctx context.Context, id string, polledAt time.Time, interval int) error {
+	return r.db.WithContext(ctx).Model(&OAuthDeviceCode{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"last_polled_at": polledAt, "interval": interval}).Error
+}
+
+// DecideDeviceCode 用户同意或拒绝待确认的设备授权请求，返回是否更新成功
+func (r *Repository) DecideDeviceCode(ctx context.Context, id string, status int, userID string, authTime time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&OAuthDeviceCode{}).
+		Where("id = ? AND status = ?", id, DeviceCodePending).
+		Updates(map[string]interface{}{"status": status, "user_id": userID, "auth_time": authTime})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteDeviceCode 删除设备授权请求，返回是否删除成功
+func (r *Repository) DeleteDeviceCode(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&OAuthDeviceCode{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RedeemDeviceCode 在同一事务中删除已同意的设备授权请求并创建令牌
+// 设备码只能换取一次令牌，并发请求中只有删除成功的一方创建令牌，返回 false 表示设备码已被使用
+func (r *Repository) RedeemDeviceCode(ctx context.Context, id string, token *OAuthToken) (bool, error) {
+	redeemed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND status = ?", id, DeviceCodeApproved).Delete(&OAuthDeviceCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		redeemed = true
+		return tx.Create(token).Error
+	})
+	return redeemed, err
 }
 
 // CreateLoginLog 创建登录日志
@@ -1,13 +1,46 @@
 package auth_oauth2
 
 import (
+	"context"
+
 	"king-starter/internal/app"
+	"king-starter/internal/common"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/logx"
 )
 
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&OAuthClient{},
+		&OAuthCode{},
+		&OAuthToken{},
+		&OAuthConsent{},
+		&OAuthDeviceCode{},
+	)
+
+	if err := NewRepository(app.Db.DB).MigrateLegacyClients(context.Background()); err != nil {
+		logx.Error("failed to migrate legacy oauth clients", "error", err)
+	}
+}
+
 // RegisterRoutes 注册 OAuth2 认证路由
-func RegisterRoutes(app *app.App) {
+func RegisterRoutes(app *app.App, users *user.Repository, secondFactor auth_password.SecondFactor) {
 	repo := NewRepository(app.Db.DB)
-	handler := NewOAuthHandler(repo)
+	userRepo := user.NewRepository(app.Db.DB)
+	passwordRepo := auth_password.NewRepository(app.Db.DB)
+	lockout := auth_password.NewLockout(passwordRepo, app.Config.Auth.Lockout)
+	verifier := auth_password.NewPasswordVerifier(passwordRepo, userRepo, lockout, app.Config.Auth.RequireEmailVerified)
+	handler := NewOAuthHandler(repo, userRepo, verifier, lockout, secondFactor, app.Jwt, app.Config.Auth.OAuth2)
+
+	// 禁用用户时删除其 OAuth2 令牌
+	users.OnStatusChange(func(ctx context.Context, userID string, status int) error {
+		if status != 0 {
+			return nil
+		}
+		return repo.DeleteUserTokens(ctx, userID)
+	})
 
 	e := app.Server.Engine()
 
@@ -15,7 +48,53 @@ func RegisterRoutes(app *app.App) {
 	authGroup := e.Group("/api/core/auth")
 	{
 		authGroup.GET("/oauth/authorize", handler.Authorize)
+		authGroup.POST("/oauth/authorize", handler.Consent, middleware.JWTAuthMiddleware(app.Jwt)) // 用户确认授权
 		authGroup.POST("/oauth/token", handler.GetToken)
-		authGroup.GET("/oauth/userinfo", handler.GetUserInfo)
+		authGroup.GET("/oauth/userinfo", handler.GetUserInfo)   // OpenID Connect 用户信息
+		authGroup.POST("/oauth/introspect", handler.Introspect) // 令牌内省（RFC 7662）
+		authGroup.POST("/oauth/revoke", handler.Revoke)         // 令牌吊销（RFC 7009）
+		authGroup.GET("/oauth/scopes", handler.ListScopes)      // 已登记的权限范围
+
+		authGroup.POST("/oauth/device_authorization", handler.DeviceAuthorization) // 设备授权（RFC 8628）
+	}
+
+	// 动态客户端注册（RFC 7591/7592），未开启时不注册路由
+	if app.Config.Auth.OAuth2.Registration.Enabled {
+		registerGroup := e.Group("/api/core/auth/oauth/register")
+		{
+			registerGroup.POST("", handler.Register)
+			registerGroup.GET("/:client_id", handler.GetRegistration)
+			registerGroup.PUT("/:client_id", handler.UpdateRegistration)
+			registerGroup.DELETE("/:client_id", handler.DeleteRegistration)
+		}
+	}
+
+	// 用户在设备验证页输入用户码并确认授权
+	deviceGroup := e.Group("/api/core/auth/oauth/device", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		deviceGroup.GET("", handler.GetDeviceVerification)
+		deviceGroup.POST("", handler.VerifyDevice)
+	}
+
+	// OpenID Connect 提供方元数据
+	e.GET("/.well-known/openid-configuration", handler.Discovery)
+
+	// 当前用户已授权的第三方应用
+	appGroup := e.Group("/api/core/auth/oauth/authorizations", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		appGroup.GET("", handler.ListMyAuthorizations)
+		appGroup.DELETE("/:client_id", handler.RevokeMyAuthorization)
+	}
+
+	// 管理员管理 OAuth2 客户端
+	adminGroup := e.Group("/api/core/auth/admin/oauth/clients", middleware.JWTAuthMiddleware(app.Jwt), middleware.RequireRoles(common.AdminRoleCode))
+	{
+		adminGroup.POST("", handler.CreateClient)
+		adminGroup.GET("", handler.ListClients)
+		adminGroup.GET("/:client_id", handler.GetClient)
+		adminGroup.PUT("/:client_id", handler.UpdateClient)
+		adminGroup.POST("/:client_id/disable", handler.DisableClient)
+		adminGroup.POST("/:client_id/enable", handler.EnableClient)
+		adminGroup.POST("/:client_id/secret", handler.RotateClientSecret) // 轮换密钥
 	}
 }
@@ -0,0 +1,140 @@
+package auth_oauth2
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"king-starter/config"
+	"king-starter/internal/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ScopeInfo 已登记的权限范围
+type ScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"` // 展示在授权确认页，说明授权后应用可以做什么
+}
+
+// standardScopes OpenID Connect 标准权限范围，未设置权限范围的客户端也可以申请
+var standardScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone}
+
+// scopeRegistry 已登记的权限范围，按登记顺序保存
+var scopeRegistry = struct {
+	sync.RWMutex
+	scopes []ScopeInfo
+}{
+	scopes: []ScopeInfo{
+		{Name: ScopeOpenID, Description: "使用你的账号登录"},
+		{Name: ScopeProfile, Description: "读取你的用户名和昵称"},
+		{Name: ScopeEmail, Description: "读取你的邮箱地址"},
+		{Name: ScopePhone, Description: "读取你的手机号"},
+	},
+}
+
+// RegisterScope 登记权限范围，同名的权限范围只更新描述
+// 业务模块在注册路由前登记自己的权限范围，也可以在配置文件 auth.oauth2.scopes 中登记（见 RegisterConfigScopes）
+func RegisterScope(name, description string) {
+	if !validScopeName(name) {
+		panic("oauth2: invalid scope name " + name)
+	}
+	scopeRegistry.Lock()
+	defer scopeRegistry.Unlock()
+	for i := range scopeRegistry.scopes {
+		if scopeRegistry.scopes[i].Name == name {
+			scopeRegistry.scopes[i].Description = description
+			return
+		}
+	}
+	scopeRegistry.scopes = append(scopeRegistry.scopes, ScopeInfo{Name: name, Description: description})
+}
+
+// RegisterConfigScopes 登记配置文件 auth.oauth2.scopes 中的业务权限范围
+// 在注册任何路由之前调用，业务模块的 RequireScopes 才能使用配置中的权限范围
+func RegisterConfigScopes(scopes []config.ScopeConfig) {
+	for _, scope := range scopes {
+		RegisterScope(scope.Name, scope.Description)
+	}
+}
+
+// RegisteredScopes 返回已登记的全部权限范围
+func RegisteredScopes() []ScopeInfo {
+	scopeRegistry.RLock()
+	defer scopeRegistry.RUnlock()
+	return slices.Clone(scopeRegistry.scopes)
+}
+
+// lookupScope 查询已登记的权限范围
+func lookupScope(name string) (ScopeInfo, bool) {
+	scopeRegistry.RLock()
+	defer scopeRegistry.RUnlock()
+	for _, info := range scopeRegistry.scopes {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return ScopeInfo{}, false
+}
+
+// scopeRegistered 判断权限范围是否已登记
+func scopeRegistered(name string) bool {
+	_, ok := lookupScope(name)
+	return ok
+}
+
+// describeScopes 返回请求的权限范围及其描述，供授权确认页展示
+func describeScopes(scope string) []ScopeInfo {
+	scopes := parseScope(scope)
+	infos := make([]ScopeInfo, 0, len(scopes))
+	for _, name := range scopes {
+		info, ok := lookupScope(name)
+		if !ok {
+			info = ScopeInfo{Name: name}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// validScopeName 权限范围不能为空，也不能包含空白、双引号和反斜杠（RFC 6749 3.3）
+func validScopeName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n\"\\")
+}
+
+// ListScopes 查询已登记的权限范围，供客户端管理和授权确认页使用
+func (h *OAuthHandler) ListScopes(c echo.Context) error {
+	return response.Success[any](c, RegisteredScopes())
+}
+
+// parseScope 将空格分隔的权限范围拆分为列表，去除重复项并保持原有顺序
+func parseScope(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// joinScope 将权限范围列表合并为空格分隔的字符串
+func joinScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// scopeCovers 判断已授予的权限范围是否包含请求的全部权限范围
+func scopeCovers(granted, requested string) bool {
+	grantedScopes := parseScope(granted)
+	for _, s := range parseScope(requested) {
+		if !slices.Contains(grantedScopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeScope 合并两组权限范围
+func mergeScope(a, b string) string {
+	return joinScope(parseScope(a + " " + b))
+}
@@ -0,0 +1,34 @@
+package auth_oauth2
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"                           // 授权码模式
+	GrantTypeRefreshToken      = "refresh_token"                                // 刷新令牌
+	GrantTypePassword          = "password"                                     // 密码模式，仅限受信任的第一方客户端
+	GrantTypeClientCredentials = "client_credentials"                           // 客户端凭证模式，服务间调用，令牌不关联用户
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code" // 设备授权模式（RFC 8628），命令行工具等无法跳转浏览器的设备
+)
+
+// defaultGrantTypes 未配置授权类型的客户端默认允许的授权类型
+var defaultGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+// AllowedGrantTypes 返回客户端允许的授权类型
+func (c *OAuthClient) AllowedGrantTypes() []string {
+	if grantTypes := strings.Fields(c.GrantTypes); len(grantTypes) > 0 {
+		return grantTypes
+	}
+	return defaultGrantTypes
+}
+
+// AllowsGrant 判断客户端是否允许使用指定的授权类型
+// 公开客户端无法保管密钥，不能使用客户端凭证模式
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	if c.IsPublic && grantType == GrantTypeClientCredentials {
+		return false
+	}
+	return slices.Contains(c.AllowedGrantTypes(), grantType)
+}
@@ -0,0 +1,299 @@
+package auth_passkey
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/logx"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// PasskeyHandler 通行密钥处理器
+type PasskeyHandler struct {
+	repo     *Repository
+	userRepo *user.Repository
+	issuer   *auth_password.TokenIssuer
+	webauthn *webauthn.WebAuthn
+	timeout  time.Duration
+}
+
+// NewPasskeyHandler 创建通行密钥处理器实例
+func NewPasskeyHandler(repo *Repository, userRepo *user.Repository, issuer *auth_password.TokenIssuer, wa *webauthn.WebAuthn, timeout time.Duration) *PasskeyHandler {
+	return &PasskeyHandler{
+		repo:     repo,
+		userRepo: userRepo,
+		issuer:   issuer,
+		webauthn: wa,
+		timeout:  timeout,
+	}
+}
+
+// BeginRegistration 开始注册通行密钥，返回 navigator.credentials.create() 的参数
+func (h *PasskeyHandler) BeginRegistration(c echo.Context) error {
+	waUser, err := h.loadUser(c.Request().Context(), echoutil.GetUserID(c))
+	if err != nil {
+		return loadUserError(c, err)
+	}
+
+	// 要求可发现凭证，登录时无需输入用户名；排除已注册的凭证避免同一认证器重复注册
+	creation, sessionData, err := h.webauthn.BeginRegistration(waUser,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(waUser.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成注册参数失败")
+	}
+
+	sessionID, err := h.saveSession(c, waUser.user.ID, CeremonyRegister, sessionData)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "保存注册会话失败")
+	}
+
+	return response.Success[any](c, map[string]interface{}{
+		"session_id": sessionID,
+		"options":    creation,
+	})
+}
+
+// FinishRegistration 完成注册通行密钥
+func (h *PasskeyHandler) FinishRegistration(c echo.Context) error {
+	var req FinishRegistrationReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	sessionData, err := h.takeSession(ctx, req.SessionID, CeremonyRegister)
+	if err != nil {
+		return takeSessionError(c, err)
+	}
+	if string(sessionData.UserID) != userID {
+		return response.Error(c, http.StatusBadRequest, "会话已失效，请重新发起")
+	}
+
+	waUser, err := h.loadUser(ctx, userID)
+	if err != nil {
+		return loadUserError(c, err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "凭证格式错误")
+	}
+	credential, err := h.webauthn.CreateCredential(waUser, *sessionData, parsed)
+	if err != nil {
+		logx.Warn("passkey registration failed", "user_id", userID, "error", err)
+		return response.Error(c, http.StatusBadRequest, "通行密钥校验失败")
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "保存通行密钥失败")
+	}
+	name := req.Name
+	if name == "" {
+		name = "通行密钥 " + time.Now().Format(time.DateOnly)
+	}
+	passkey := &CorePasskey{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Name:         name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(data),
+		SignCount:    credential.Authenticator.SignCount,
+	}
+	if err := h.repo.CreatePasskey(ctx, passkey); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "保存通行密钥失败")
+	}
+
+	return response.SuccessWithMsg[any](c, "通行密钥注册成功", passkey)
+}
+
+// ListPasskeys 查询当前用户的通行密钥
+func (h *PasskeyHandler) ListPasskeys(c echo.Context) error {
+	passkeys, err := h.repo.ListPasskeysByUserID(c.Request().Context(), echoutil.GetUserID(c))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询通行密钥失败")
+	}
+	return response.Success[any](c, passkeys)
+}
+
+// DeletePasskey 删除当前用户的通行密钥
+func (h *PasskeyHandler) DeletePasskey(c echo.Context) error {
+	affected, err := h.repo.DeletePasskey(c.Request().Context(), echoutil.GetUserID(c), c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "删除通行密钥失败")
+	}
+	if affected == 0 {
+		return response.Error(c, http.StatusNotFound, "通行密钥不存在")
+	}
+	return response.SuccessWithMsg[any](c, "删除成功", nil)
+}
+
+// BeginLogin 开始通行密钥登录，返回 navigator.credentials.get() 的参数
+// 使用可发现凭证，客户端无需提供用户名
+func (h *PasskeyHandler) BeginLogin(c echo.Context) error {
+	assertion, sessionData, err := h.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成登录参数失败")
+	}
+
+	sessionID, err := h.saveSession(c, "", CeremonyLogin, sessionData)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "保存登录会话失败")
+	}
+
+	return response.Success[any](c, map[string]interface{}{
+		"session_id": sessionID,
+		"options":    assertion,
+	})
+}
+
+// FinishLogin 完成通行密钥登录，成功后签发与密码登录相同的令牌
+func (h *PasskeyHandler) FinishLogin(c echo.Context) error {
+	var req FinishLoginReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+
+	sessionData, err := h.takeSession(ctx, req.SessionID, CeremonyLogin)
+	if err != nil {
+		return takeSessionError(c, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "凭证格式错误")
+	}
+
+	// 根据用户句柄找回用户及其凭证
+	var waUser *webauthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		waUser, err = h.loadUser(ctx, string(userHandle))
+		return waUser, err
+	}
+	_, credential, err := h.webauthn.ValidatePasskeyLogin(handler, *sessionData, parsed)
+	if err != nil {
+		logx.Warn("passkey login failed", "error", err)
+		if waUser != nil {
+			h.createLoginLog(c, waUser.user, LoginTypeFailed, "通行密钥校验失败")
+		}
+		return response.Error(c, http.StatusUnauthorized, "通行密钥校验失败")
+	}
+
+	u := waUser.user
+	// 签名计数器没有递增，认证器可能被克隆
+	if credential.Authenticator.CloneWarning {
+		h.createLoginLog(c, u, LoginTypeFailed, "签名计数器异常，认证器可能被克隆")
+		return response.Error(c, http.StatusUnauthorized, "通行密钥校验失败")
+	}
+
+	// 更新签名计数器等凭证数据
+	if passkey := waUser.passkeyByCredentialID(base64.RawURLEncoding.EncodeToString(credential.ID)); passkey != nil {
+		if data, err := json.Marshal(credential); err == nil {
+			if err := h.repo.UpdatePasskeyUsage(ctx, passkey.ID, string(data), credential.Authenticator.SignCount); err != nil {
+				logx.Error("update passkey usage failed", "passkey_id", passkey.ID, "error", err)
+			}
+		}
+	}
+
+	// 登录时不强制用户验证（PIN、生物识别），通行密钥只能证明持有认证器，
+	// 启用了两步验证时同样需要完成两步验证
+	return h.issuer.Complete(c, u, func(loginType, message string) {
+		h.createLoginLog(c, u, loginType, message)
+	})
+}
+
+// loadUser 加载用户及其已注册的通行密钥
+func (h *PasskeyHandler) loadUser(ctx context.Context, userID string) (*webauthnUser, error) {
+	u, err := h.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := h.repo.ListPasskeysByUserID(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newWebauthnUser(u, passkeys)
+}
+
+// loadUserError loadUser 失败时的响应
+func loadUserError(c echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusNotFound, "用户不存在")
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+}
+
+// saveSession 保存 WebAuthn 会话数据，返回会话ID
+func (h *PasskeyHandler) saveSession(c echo.Context, userID, ceremony string, sessionData *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(sessionData)
+	if err != nil {
+		return "", err
+	}
+	session := &CorePasskeySession{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(h.timeout),
+	}
+	if err := h.repo.CreateSession(c.Request().Context(), session); err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+// takeSession 取出 WebAuthn 会话数据，会话只能使用一次
+func (h *PasskeyHandler) takeSession(ctx context.Context, sessionID, ceremony string) (*webauthn.SessionData, error) {
+	session, err := h.repo.TakeSession(ctx, sessionID, ceremony)
+	if err != nil {
+		return nil, err
+	}
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal([]byte(session.Data), &sessionData); err != nil {
+		return nil, err
+	}
+	return &sessionData, nil
+}
+
+// takeSessionError takeSession 失败时的响应
+func takeSessionError(c echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusBadRequest, "会话已失效，请重新发起")
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询会话失败")
+}
+
+// createLoginLog 记录通行密钥登录日志
+func (h *PasskeyHandler) createLoginLog(c echo.Context, u *user.CoreUser, loginType, message string) {
+	log := &auth_password.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Username:  u.Username,
+		AuthType:  AuthTypePasskey,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
+}
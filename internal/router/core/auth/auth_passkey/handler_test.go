@@ -0,0 +1,328 @@
+package auth_passkey
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/jwt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:8080"
+)
+
+var b64 = base64.RawURLEncoding
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// softAuthenticator 软件认证器，模拟浏览器与认证器完成 WebAuthn 仪式
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authData 构造认证器数据：rpIdHash | flags | signCount [| attestedCredentialData]
+func (a *softAuthenticator) authData(t *testing.T, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(0x01 | 0x04) // UP | UV
+	if attested {
+		flags |= 0x40 // AT
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+	return append(data, coseKey...)
+}
+
+func clientData(t *testing.T, typ, challenge string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+// create 响应 navigator.credentials.create()
+func (a *softAuthenticator) create(t *testing.T, options map[string]interface{}) json.RawMessage {
+	publicKey := options["publicKey"].(map[string]interface{})
+	userID, err := b64.DecodeString(publicKey["user"].(map[string]interface{})["id"].(string))
+	require.NoError(t, err)
+	a.userHandle = userID
+
+	attestation, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(t, true),
+	})
+	require.NoError(t, err)
+
+	credential, err := json.Marshal(map[string]interface{}{
+		"id":    b64.EncodeToString(a.credentialID),
+		"rawId": b64.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(clientData(t, "webauthn.create", publicKey["challenge"].(string))),
+			"attestationObject": b64.EncodeToString(attestation),
+		},
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+// get 响应 navigator.credentials.get()
+func (a *softAuthenticator) get(t *testing.T, options map[string]interface{}) json.RawMessage {
+	publicKey := options["publicKey"].(map[string]interface{})
+	authData := a.authData(t, false)
+	clientDataJSON := clientData(t, "webauthn.get", publicKey["challenge"].(string))
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	credential, err := json.Marshal(map[string]interface{}{
+		"id":    b64.EncodeToString(a.credentialID),
+		"rawId": b64.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(clientDataJSON),
+			"authenticatorData": b64.EncodeToString(authData),
+			"signature":         b64.EncodeToString(signature),
+			"userHandle":        b64.EncodeToString(a.userHandle),
+		},
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	jwt     *jwt.JWT
+	handler *PasskeyHandler
+	e       *echo.Echo
+}
+
+// newTestEnv 创建测试环境，secondFactor 为 nil 时不要求两步验证
+func newTestEnv(t *testing.T, secondFactor auth_password.SecondFactor) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&auth_password.CoreLoginLog{}, &auth_password.CoreRefreshToken{}, &auth_password.CoreLoginChallenge{},
+		&CorePasskey{}, &CorePasskeySession{},
+	)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Email: "alice@example.com", Status: 1}).Error)
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "King Starter",
+		RPOrigins:     []string{testOrigin},
+	})
+	require.NoError(t, err)
+
+	j := testutil.NewJWT()
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(db), role.NewRoleRepo(db), j, false, secondFactor, 5*time.Minute)
+	return &testEnv{
+		db:      db,
+		jwt:     j,
+		handler: NewPasskeyHandler(NewRepository(db), user.NewRepository(db), issuer, wa, 5*time.Minute),
+		e:       echo.New(),
+	}
+}
+
+// call 调用处理器并返回响应中的 code 与 data
+func (env *testEnv) call(t *testing.T, h echo.HandlerFunc, userID string, body interface{}, params ...string) (int, interface{}) {
+	resp := testutil.Call(t, h, userID, body, params...)
+	return resp.Code, resp.Data
+}
+
+func (env *testEnv) register(t *testing.T, auth *softAuthenticator) string {
+	code, data := env.call(t, env.handler.BeginRegistration, "u1", nil)
+	require.Equal(t, http.StatusOK, code)
+	begin := data.(map[string]interface{})
+
+	code, data = env.call(t, env.handler.FinishRegistration, "u1", map[string]interface{}{
+		"session_id": begin["session_id"],
+		"name":       "MacBook",
+		"credential": auth.create(t, begin["options"].(map[string]interface{})),
+	})
+	require.Equal(t, http.StatusOK, code)
+	return data.(map[string]interface{})["id"].(string)
+}
+
+func (env *testEnv) login(t *testing.T, auth *softAuthenticator) (int, interface{}) {
+	code, data := env.call(t, env.handler.BeginLogin, "", nil)
+	require.Equal(t, http.StatusOK, code)
+	begin := data.(map[string]interface{})
+
+	return env.call(t, env.handler.FinishLogin, "", map[string]interface{}{
+		"session_id": begin["session_id"],
+		"credential": auth.get(t, begin["options"].(map[string]interface{})),
+	})
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	env := newTestEnv(t, nil)
+	auth := newSoftAuthenticator(t)
+
+	passkeyID := env.register(t, auth)
+
+	code, data := env.call(t, env.handler.ListPasskeys, "u1", nil)
+	require.Equal(t, http.StatusOK, code)
+	passkeys := data.([]interface{})
+	require.Len(t, passkeys, 1)
+	assert.Equal(t, "MacBook", passkeys[0].(map[string]interface{})["name"])
+	assert.NotContains(t, passkeys[0], "credential")
+
+	auth.signCount = 1
+	code, data = env.login(t, auth)
+	require.Equal(t, http.StatusOK, code)
+	accessToken := data.(map[string]interface{})["access_token"].(string)
+	claims, err := env.jwt.ParseToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+	assert.NotEmpty(t, data.(map[string]interface{})["refresh_token"])
+
+	var passkey CorePasskey
+	require.NoError(t, env.db.First(&passkey, "id = ?", passkeyID).Error)
+	assert.EqualValues(t, 1, passkey.SignCount)
+	assert.NotNil(t, passkey.LastUsedAt)
+
+	var log auth_password.CoreLoginLog
+	require.NoError(t, env.db.Where("auth_type = ?", AuthTypePasskey).First(&log).Error)
+	assert.Equal(t, LoginTypeSuccess, log.LoginType)
+}
+
+func TestPasskeyLoginRejectsReplayedSession(t *testing.T) {
+	env := newTestEnv(t, nil)
+	auth := newSoftAuthenticator(t)
+	env.register(t, auth)
+
+	code, data := env.call(t, env.handler.BeginLogin, "", nil)
+	require.Equal(t, http.StatusOK, code)
+	begin := data.(map[string]interface{})
+	auth.signCount = 1
+	req := map[string]interface{}{
+		"session_id": begin["session_id"],
+		"credential": auth.get(t, begin["options"].(map[string]interface{})),
+	}
+
+	code, _ = env.call(t, env.handler.FinishLogin, "", req)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = env.call(t, env.handler.FinishLogin, "", req)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestPasskeyLoginDetectsClonedAuthenticator(t *testing.T) {
+	env := newTestEnv(t, nil)
+	auth := newSoftAuthenticator(t)
+	env.register(t, auth)
+
+	auth.signCount = 5
+	code, _ := env.login(t, auth)
+	require.Equal(t, http.StatusOK, code)
+
+	// 签名计数器回退
+	auth.signCount = 3
+	code, _ = env.login(t, auth)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestPasskeyLoginDisabledUser(t *testing.T) {
+	env := newTestEnv(t, nil)
+	auth := newSoftAuthenticator(t)
+	env.register(t, auth)
+	require.NoError(t, env.db.Model(&user.CoreUser{}).Where("id = ?", "u1").Update("status", 0).Error)
+
+	auth.signCount = 1
+	code, _ := env.login(t, auth)
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+// enabledSecondFactor 所有用户都启用了两步验证
+type enabledSecondFactor struct{}
+
+func (enabledSecondFactor) Enabled(context.Context, string) (bool, error) { return true, nil }
+
+func (enabledSecondFactor) Verify(context.Context, string, string, string) (string, bool, error) {
+	return auth_password.AuthType2FA, false, nil
+}
+
+func TestPasskeyLoginRequiresSecondFactor(t *testing.T) {
+	env := newTestEnv(t, enabledSecondFactor{})
+	auth := newSoftAuthenticator(t)
+	env.register(t, auth)
+
+	// 启用了两步验证时只返回挑战令牌
+	auth.signCount = 1
+	code, data := env.login(t, auth)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, data.(map[string]interface{})["two_factor_required"])
+	assert.NotContains(t, data, "access_token")
+}
+
+func TestPasskeyDelete(t *testing.T) {
+	env := newTestEnv(t, nil)
+	auth := newSoftAuthenticator(t)
+	passkeyID := env.register(t, auth)
+
+	// 不能删除其他用户的通行密钥
+	code, _ := env.call(t, env.handler.DeletePasskey, "u2", nil, "id", passkeyID)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = env.call(t, env.handler.DeletePasskey, "u1", nil, "id", passkeyID)
+	require.Equal(t, http.StatusOK, code)
+
+	// 删除后无法再用该凭证登录
+	auth.signCount = 1
+	code, _ = env.login(t, auth)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,38 @@
+package auth_passkey
+
+import (
+	"time"
+)
+
+// CorePasskey 用户的通行密钥（WebAuthn 凭证）
+type CorePasskey struct {
+	ID           string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID       string     `gorm:"type:varchar(36);index" json:"user_id"`
+	Name         string     `gorm:"type:varchar(100)" json:"name"`                      // 用户自定义名称
+	CredentialID string     `gorm:"type:varchar(255);uniqueIndex" json:"credential_id"` // 凭证ID（base64url）
+	Credential   string     `gorm:"type:text" json:"-"`                                 // webauthn.Credential 的 JSON
+	SignCount    uint32     `gorm:"default:0" json:"sign_count"`                        // 签名计数器，用于检测克隆的认证器
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`                             // 最近一次登录时间
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CorePasskey) TableName() string {
+	return "core_user_passkeys"
+}
+
+// CorePasskeySession WebAuthn 注册/登录流程的会话数据，begin 时写入，finish 时取出并删除
+type CorePasskeySession struct {
+	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string    `gorm:"type:varchar(36)" json:"user_id"`  // 注册时为当前用户，登录时为空
+	Ceremony  string    `gorm:"type:varchar(20)" json:"ceremony"` // register / login
+	Data      string    `gorm:"type:text" json:"-"`               // webauthn.SessionData 的 JSON
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CorePasskeySession) TableName() string {
+	return "core_passkey_sessions"
+}
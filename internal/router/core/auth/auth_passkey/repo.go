@@ -0,0 +1,91 @@
+package auth_passkey
+
+import (
+	"context"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+
+	"gorm.io/gorm"
+)
+
+// Repository 通行密钥仓库
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建通行密钥仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreatePasskey 保存通行密钥
+func (r *Repository) CreatePasskey(ctx context.Context, passkey *CorePasskey) error {
+	return r.db.WithContext(ctx).Create(passkey).Error
+}
+
+// ListPasskeysByUserID 查询用户的通行密钥
+func (r *Repository) ListPasskeysByUserID(ctx context.Context, userID string) ([]CorePasskey, error) {
+	var passkeys []CorePasskey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&passkeys).Error
+	return passkeys, err
+}
+
+// UpdatePasskeyUsage 登录成功后更新凭证数据（签名计数器、标志位）和最近使用时间
+func (r *Repository) UpdatePasskeyUsage(ctx context.Context, id, credential string, signCount uint32) error {
+	return r.db.WithContext(ctx).Model(&CorePasskey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"credential":   credential,
+			"sign_count":   signCount,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+// DeletePasskey 删除用户的通行密钥，返回删除的数量
+func (r *Repository) DeletePasskey(ctx context.Context, userID, id string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&CorePasskey{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateSession 保存 WebAuthn 会话，顺便清理已过期的会话
+func (r *Repository) CreateSession(ctx context.Context, session *CorePasskeySession) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&CorePasskeySession{}).Error; err != nil {
+		return err
+	}
+	return db.Create(session).Error
+}
+
+// TakeSession 取出并删除 WebAuthn 会话，会话只能使用一次
+// 会话不存在、已过期、流程不匹配或已被并发请求取走时返回 gorm.ErrRecordNotFound
+func (r *Repository) TakeSession(ctx context.Context, id, ceremony string) (*CorePasskeySession, error) {
+	var session CorePasskeySession
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND ceremony = ? AND expires_at > ?", id, ceremony, time.Now()).First(&session).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&CorePasskeySession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreateLoginLog 创建登录日志
+func (r *Repository) CreateLoginLog(ctx context.Context, log *auth_password.CoreLoginLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
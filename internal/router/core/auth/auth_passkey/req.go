@@ -0,0 +1,18 @@
+package auth_passkey
+
+import (
+	"encoding/json"
+)
+
+// FinishRegistrationReq 完成通行密钥注册请求参数
+type FinishRegistrationReq struct {
+	SessionID  string          `json:"session_id" validate:"required"`
+	Name       string          `json:"name,omitempty"`                 // 通行密钥名称，为空时使用默认名称
+	Credential json.RawMessage `json:"credential" validate:"required"` // navigator.credentials.create() 的结果
+}
+
+// FinishLoginReq 完成通行密钥登录请求参数
+type FinishLoginReq struct {
+	SessionID  string          `json:"session_id" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"` // navigator.credentials.get() 的结果
+}
@@ -0,0 +1,60 @@
+package auth_passkey
+
+import (
+	"time"
+
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&CorePasskey{},
+		&CorePasskeySession{},
+	)
+}
+
+// RegisterRoutes 注册通行密钥路由
+func RegisterRoutes(app *app.App, secondFactor auth_password.SecondFactor) {
+	cfg := app.Config.Auth.Passkey
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: timeout},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	repo := NewRepository(app.Db.DB)
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(app.Db.DB), role.NewRoleRepo(app.Db.DB), app.Jwt, app.Config.Auth.RequireEmailVerified, secondFactor, time.Duration(app.Config.Auth.TwoFA.ChallengeTTL)*time.Second)
+	handler := NewPasskeyHandler(repo, user.NewRepository(app.Db.DB), issuer, wa, timeout)
+
+	e := app.Server.Engine()
+
+	// 通行密钥登录
+	authGroup := e.Group("/api/core/auth/login/passkey")
+	{
+		authGroup.POST("/begin", handler.BeginLogin)
+		authGroup.POST("/finish", handler.FinishLogin)
+	}
+
+	// 当前用户管理自己的通行密钥
+	passkeyGroup := e.Group("/api/core/auth/passkeys", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		passkeyGroup.GET("", handler.ListPasskeys)
+		passkeyGroup.DELETE("/:id", handler.DeletePasskey)
+		passkeyGroup.POST("/register/begin", handler.BeginRegistration)
+		passkeyGroup.POST("/register/finish", handler.FinishRegistration)
+	}
+}
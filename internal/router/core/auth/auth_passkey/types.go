@@ -0,0 +1,15 @@
+package auth_passkey
+
+const (
+	AuthTypePasskey string = "passkey" // 通行密钥认证
+)
+
+const (
+	LoginTypeSuccess string = "success" // 登录成功
+	LoginTypeFailed  string = "failed"  // 登录失败
+)
+
+const (
+	CeremonyRegister string = "register" // 注册通行密钥
+	CeremonyLogin    string = "login"    // 通行密钥登录
+)
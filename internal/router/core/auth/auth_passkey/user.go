@@ -0,0 +1,61 @@
+package auth_passkey
+
+import (
+	"encoding/json"
+
+	"king-starter/internal/router/core/user"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// webauthnUser 将 user.CoreUser 适配为 webauthn.User
+// 用户句柄（user handle）使用用户ID，可发现凭证登录时据此找回用户
+type webauthnUser struct {
+	user        *user.CoreUser
+	passkeys    []CorePasskey
+	credentials []webauthn.Credential
+}
+
+var _ webauthn.User = (*webauthnUser)(nil)
+
+// newWebauthnUser 创建 webauthn.User，解析已保存的凭证
+func newWebauthnUser(u *user.CoreUser, passkeys []CorePasskey) (*webauthnUser, error) {
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, p := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(p.Credential), &credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return &webauthnUser{user: u, passkeys: passkeys, credentials: credentials}, nil
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	if u.user.Nickname != "" {
+		return u.user.Nickname
+	}
+	return u.user.Username
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// passkeyByCredentialID 根据凭证ID找到对应的通行密钥记录
+func (u *webauthnUser) passkeyByCredentialID(credentialID string) *CorePasskey {
+	for i := range u.passkeys {
+		if u.passkeys[i].CredentialID == credentialID {
+			return &u.passkeys[i]
+		}
+	}
+	return nil
+}
@@ -1,45 +1,79 @@
 package auth_password
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
 	"time"
 
+	"king-starter/config"
 	"king-starter/internal/response"
+	"king-starter/internal/router/core/role"
 	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/echoutil"
 	"king-starter/pkg/goutils/idutil"
+	"king-starter/pkg/http/middleware"
+	"king-starter/pkg/http/resp"
+	"king-starter/pkg/jwt"
+	"king-starter/pkg/logx"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// phonePattern 手机号格式，允许带国际区号前缀 +
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{6,20}$`)
+
 // LoginHandler 密码登录处理器
 type LoginHandler struct {
-	repo     *Repository
-	userRepo *user.Repository
+	repo         *Repository
+	userRepo     *user.Repository
+	issuer       *TokenIssuer
+	jwt          *jwt.JWT
+	lockout      *Lockout
+	verifier     *PasswordVerifier
+	policy       *PasswordPolicy
+	secondFactor SecondFactor
+	twoFA        config.TwoFAConfig
 }
 
-// NewLoginHandler 创建密码登录处理器实例
-func NewLoginHandler(repo *Repository, userRepo *user.Repository) *LoginHandler {
+// NewLoginHandler 创建密码登录处理器实例，secondFactor 为 nil 时不要求两步验证
+func NewLoginHandler(repo *Repository, userRepo *user.Repository, roleRepo *role.RoleRepo, jwt *jwt.JWT, lockout *Lockout, policy *PasswordPolicy, requireEmailVerified bool, secondFactor SecondFactor, twoFA config.TwoFAConfig) *LoginHandler {
 	return &LoginHandler{
-		repo:     repo,
-		userRepo: userRepo,
+		repo:         repo,
+		userRepo:     userRepo,
+		issuer:       NewTokenIssuer(repo, roleRepo, jwt, requireEmailVerified, secondFactor, seconds(twoFA.ChallengeTTL)),
+		jwt:          jwt,
+		lockout:      lockout,
+		verifier:     NewPasswordVerifier(repo, userRepo, lockout, requireEmailVerified),
+		policy:       policy,
+		secondFactor: secondFactor,
+		twoFA:        twoFA,
 	}
 }
 
-// Claims 自定义 JWT Claims
-type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
-
 func (h *LoginHandler) Register(c echo.Context) error {
 	var req RegisterReq
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
+	// 邮箱和手机号需要格式正确，注册后均为未验证状态
+	req.Email = user.NormalizeEmail(req.Email)
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		return response.Error(c, http.StatusBadRequest, "邮箱格式错误")
+	}
+	if !phonePattern.MatchString(req.Phone) {
+		return response.Error(c, http.StatusBadRequest, "手机号格式错误")
+	}
+	if err := h.policy.Check(req.Password); err != nil {
+		return response.Error(c, http.StatusBadRequest, err.Error())
+	}
 	// 检查用户邮箱是否已存在
 	var existingUser user.CoreUser
 	if err := h.userRepo.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
@@ -49,14 +83,21 @@ func (h *LoginHandler) Register(c echo.Context) error {
 	if err := h.userRepo.DB.Where("phone = ?", req.Phone).First(&existingUser).Error; err == nil {
 		return response.Error(c, http.StatusBadRequest, "手机号已被注册")
 	}
+	// 密码加密，与 user.Handler.Create 保持一致
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "密码加密失败")
+	}
 	// 创建新用户
 	newUser := &user.CoreUser{
 		ID:       idutil.ShortUUIDv7(),
 		Username: req.Username,
+		Password: string(hashedBytes),
 		Email:    req.Email,
 		Phone:    req.Phone,
+		Status:   1, // 默认启用
 	}
-	err := h.userRepo.Create(c.Request().Context(), newUser)
+	err = h.userRepo.Create(c.Request().Context(), newUser)
 	if err != nil {
 		return response.Error(c, http.StatusInternalServerError, "注册用户失败")
 	}
@@ -70,85 +111,106 @@ func (h *LoginHandler) Login(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
+	if req.Username == "" || req.Password == "" {
+		return response.Error(c, http.StatusBadRequest, "用户名和密码不能为空")
+	}
+
+	u, err := h.verifier.Verify(c, req.Username, req.Password)
+	if err != nil {
+		return VerifyError(c, err)
+	}
+
+	return h.issuer.Complete(c, u, func(loginType, message string) {
+		h.createLoginLog(c, u.ID, u.Username, AuthTypePassword, loginType, message)
+	})
+}
+
+// LoginTwoFA 两步验证登录第二步：使用挑战令牌和 TOTP 验证码（或恢复码）换取访问令牌和刷新令牌
+func (h *LoginHandler) LoginTwoFA(c echo.Context) error {
+	var req LoginTwoFAReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return response.Error(c, http.StatusBadRequest, "验证码不能为空")
+	}
+	if h.secondFactor == nil {
+		return response.Error(c, http.StatusBadRequest, "未启用两步验证")
+	}
+
+	ctx := c.Request().Context()
 
-	// 模拟用户验证（实际应该从数据库查询并验证密码）
-	// 在实际应用中，应从用户服务获取用户信息并验证密码
-	userID := "1" // 示例用户ID
-	username := req.Username
-
-	// 这里应该实际验证用户凭据
-	// 示例：验证密码是否正确
-	if req.Username != "admin" || req.Password != "123456" {
-		// 记录登录失败日志
-		loginLog := &CoreLoginLog{
-			ID:        uuid.New().String(),
-			UserID:    "", // 登录失败时没有用户ID
-			Username:  req.Username,
-			AuthType:  AuthTypePassword,
-			LoginType: LoginTypeFailed,
-			IP:        c.RealIP(),
-			UserAgent: c.Request().UserAgent(),
-			Message:   "用户名或密码错误",
+	challenge, err := h.repo.GetLoginChallengeByToken(ctx, cryptoutil.SHA256Hex(req.ChallengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "登录挑战已失效，请重新登录")
 		}
-		h.repo.CreateLoginLog(c.Request().Context(), loginLog)
+		return response.Error(c, http.StatusInternalServerError, "查询登录挑战失败")
+	}
+	if challenge.UsedAt != nil || challenge.ExpiresAt.Before(time.Now()) || challenge.Attempts >= h.twoFA.ChallengeMaxAttempts {
+		return response.Error(c, http.StatusUnauthorized, "登录挑战已失效，请重新登录")
+	}
 
-		return response.Error(c, http.StatusUnauthorized, "用户名或密码错误")
+	u, err := h.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u.Status == 0 {
+		return response.Error(c, http.StatusForbidden, "用户已被禁用")
 	}
 
-	// 生成 JWT Token
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "king-starter",
-			Subject:   "user-token",
-		},
+	// 挑战签发后账号可能已被锁定
+	lockedUntil, err := h.lockout.LockedUntil(ctx, u.ID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询登录记录失败")
+	}
+	if !lockedUntil.IsZero() {
+		h.createLoginLog(c, u.ID, u.Username, AuthType2FA, LoginTypeBlocked, "账号已锁定")
+		return lockedError(c, lockedUntil)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("your-secret-key"))
+	// 验证之前先占用验证次数，避免并发猜测绕过次数限制
+	claimed, err := h.repo.ClaimLoginChallengeAttempt(ctx, challenge.ID, h.twoFA.ChallengeMaxAttempts)
 	if err != nil {
-		return response.Error(c, http.StatusInternalServerError, "生成令牌失败")
+		return response.Error(c, http.StatusInternalServerError, "更新登录挑战失败")
+	}
+	if !claimed {
+		return response.Error(c, http.StatusUnauthorized, "登录挑战已失效，请重新登录")
 	}
 
-	// 生成刷新令牌
-	refreshToken := &CoreRefreshToken{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		Token:     uuid.New().String(),
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+	authType, ok, err := h.secondFactor.Verify(ctx, u.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "验证失败")
+	}
+	if !ok {
+		h.createLoginLog(c, u.ID, u.Username, authType, LoginTypeFailed, "验证码错误")
+		if d, err := h.lockout.NextLock(ctx, u.ID); err == nil && d > 0 {
+			h.createLoginLog(c, u.ID, u.Username, authType, LoginTypeLocked, fmt.Sprintf("连续验证失败，锁定 %s", d))
+			return lockedError(c, time.Now().Add(d))
+		}
+		return response.Error(c, http.StatusUnauthorized, "验证码错误")
 	}
 
-	if err := h.repo.CreateRefreshToken(c.Request().Context(), refreshToken); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "生成刷新令牌失败")
+	// 挑战只能使用一次
+	used, err := h.repo.UseLoginChallenge(ctx, challenge.ID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新登录挑战失败")
+	}
+	if !used {
+		return response.Error(c, http.StatusUnauthorized, "登录挑战已失效，请重新登录")
 	}
 
-	// 记录登录成功日志
-	loginLog := &CoreLoginLog{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		Username:  username,
-		AuthType:  AuthTypePassword,
-		LoginType: LoginTypeSuccess,
-		IP:        c.RealIP(),
-		UserAgent: c.Request().UserAgent(),
-		Message:   "登录成功",
+	data, err := h.issuer.Issue(c, u)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成令牌失败")
 	}
-	h.repo.CreateLoginLog(c.Request().Context(), loginLog)
 
-	return response.Success[any](c, map[string]interface{}{
-		"access_token":  tokenString,
-		"refresh_token": refreshToken.Token,
-		"expires_at":    claims.ExpiresAt,
-		"user": map[string]interface{}{
-			"id":       userID,
-			"username": username,
-			"name":     "管理员",
-		},
-	})
+	h.createLoginLog(c, u.ID, u.Username, authType, LoginTypeSuccess, "登录成功")
+
+	return response.Success[any](c, data)
 }
 
 // Logout 用户登出
@@ -158,16 +220,75 @@ func (h *LoginHandler) Logout(c echo.Context) error {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
 
-	// 清除刷新令牌
+	ctx := c.Request().Context()
+
+	// 吊销当前访问令牌
+	if tokenString, ok := middleware.BearerToken(c); ok {
+		if claims, err := h.jwt.ParseTokenWithContext(ctx, tokenString); err == nil {
+			if err := h.jwt.RevokeToken(ctx, claims); err != nil {
+				logx.Error("revoke access token failed", "jti", claims.ID, "error", err)
+			}
+		}
+	}
+
+	// 吊销刷新令牌所在的令牌族
 	if req.RefreshToken != "" {
-		if err := h.repo.DeleteRefreshToken(c.Request().Context(), req.RefreshToken); err != nil {
-			// 即使删除失败也继续执行
+		if token, err := h.repo.GetRefreshTokenByToken(ctx, cryptoutil.SHA256Hex(req.RefreshToken)); err == nil {
+			if err := h.repo.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+				logx.Error("revoke refresh token family failed", "family_id", token.FamilyID, "error", err)
+			}
 		}
 	}
 
 	return response.SuccessWithMsg[any](c, "登出成功", nil)
 }
 
+// ChangePassword 修改当前用户密码
+// 修改成功后吊销该用户所有访问令牌和刷新令牌，所有设备需重新登录
+func (h *LoginHandler) ChangePassword(c echo.Context) error {
+	var req user.ChangePasswordReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.OldPassword == "" {
+		return response.Error(c, http.StatusBadRequest, "原密码不能为空")
+	}
+	if err := h.policy.Check(req.NewPassword); err != nil {
+		return response.Error(c, http.StatusBadRequest, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	u, err := h.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.OldPassword)); err != nil {
+		return response.Error(c, http.StatusBadRequest, "原密码错误")
+	}
+
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "密码加密失败")
+	}
+	if err := h.userRepo.UpdatePassword(ctx, u.ID, string(hashedBytes)); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "修改密码失败")
+	}
+
+	if err := h.jwt.RevokeUser(ctx, u.ID); err != nil {
+		logx.Error("revoke access tokens failed", "user_id", u.ID, "error", err)
+	}
+	if err := h.repo.RevokeUserRefreshTokens(ctx, u.ID); err != nil {
+		logx.Error("revoke refresh tokens failed", "user_id", u.ID, "error", err)
+	}
+
+	return response.SuccessWithMsg[any](c, "密码修改成功，请重新登录", nil)
+}
+
 // RefreshToken 刷新令牌
 func (h *LoginHandler) RefreshToken(c echo.Context) error {
 	var req RefreshTokenReq
@@ -175,8 +296,10 @@ func (h *LoginHandler) RefreshToken(c echo.Context) error {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
 
+	ctx := c.Request().Context()
+
 	// 获取刷新令牌
-	refreshToken, err := h.repo.GetRefreshTokenByToken(c.Request().Context(), req.RefreshToken)
+	refreshToken, err := h.repo.GetRefreshTokenByToken(ctx, cryptoutil.SHA256Hex(req.RefreshToken))
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return response.Error(c, http.StatusUnauthorized, "刷新令牌不存在")
@@ -184,50 +307,151 @@ func (h *LoginHandler) RefreshToken(c echo.Context) error {
 		return response.Error(c, http.StatusInternalServerError, "查询刷新令牌失败")
 	}
 
+	// 已轮换的令牌再次出现，说明令牌可能已被盗用
+	if refreshToken.RotatedAt != nil {
+		h.revokeReusedFamily(c, refreshToken)
+		return response.Error(c, http.StatusUnauthorized, "刷新令牌已失效")
+	}
+
+	// 检查刷新令牌是否已吊销
+	if refreshToken.RevokedAt != nil {
+		return response.Error(c, http.StatusUnauthorized, "刷新令牌已失效")
+	}
+
 	// 检查刷新令牌是否过期
 	if refreshToken.ExpiresAt.Before(time.Now()) {
 		return response.Error(c, http.StatusUnauthorized, "刷新令牌已过期")
 	}
 
-	// 生成新的访问令牌
-	claims := Claims{
-		UserID:   refreshToken.UserID,
-		Username: "admin", // 实际应该从数据库查询
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "king-starter",
-			Subject:   "user-token",
-		},
+	// 重新读取用户信息，确保用户仍然存在且未被禁用
+	u, err := h.userRepo.GetByID(ctx, refreshToken.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u.Status == 0 {
+		return response.Error(c, http.StatusForbidden, "用户已被禁用")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("your-secret-key"))
+	// 生成新的访问令牌，沿用会话的登录时间
+	accessToken, expiresAt, err := h.issuer.AccessToken(ctx, u, refreshToken.LoginAt)
 	if err != nil {
 		return response.Error(c, http.StatusInternalServerError, "生成令牌失败")
 	}
 
-	// 生成新的刷新令牌
-	newRefreshToken := &CoreRefreshToken{
+	// 轮换刷新令牌，新令牌沿用同一令牌族并记录来源
+	newToken, plainToken := newRefreshToken(c, u.ID, refreshToken)
+	rotated, err := h.repo.RotateRefreshToken(ctx, refreshToken.ID, newToken)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成刷新令牌失败")
+	}
+	// 并发请求抢先轮换了同一个令牌，同样按重放处理
+	if !rotated {
+		h.revokeReusedFamily(c, refreshToken)
+		return response.Error(c, http.StatusUnauthorized, "刷新令牌已失效")
+	}
+
+	return response.Success[any](c, map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": plainToken,
+		"expires_at":    expiresAt,
+	})
+}
+
+// revokeReusedFamily 吊销被重放令牌所在的令牌族，并记录安全事件
+func (h *LoginHandler) revokeReusedFamily(c echo.Context, token *CoreRefreshToken) {
+	ctx := c.Request().Context()
+	if err := h.repo.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		logx.Error("revoke refresh token family failed", "family_id", token.FamilyID, "error", err)
+	}
+
+	username := ""
+	if u, err := h.userRepo.GetByID(ctx, token.UserID); err == nil {
+		username = u.Username
+	}
+	loginLog := &CoreLoginLog{
 		ID:        uuid.New().String(),
-		UserID:    refreshToken.UserID,
-		Token:     uuid.New().String(),
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		UserID:    token.UserID,
+		Username:  username,
+		AuthType:  AuthTypeRefresh,
+		LoginType: LoginTypeReuse,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   "检测到刷新令牌重放，已吊销令牌族: " + token.FamilyID,
 	}
+	h.repo.CreateLoginLog(ctx, loginLog)
+}
 
-	if err := h.repo.CreateRefreshToken(c.Request().Context(), newRefreshToken); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "生成刷新令牌失败")
+// newRefreshToken 生成刷新令牌，返回待入库的模型（仅保存哈希）和返回给客户端的明文
+// parent 为空时开启新的令牌族（即新会话），否则沿用 parent 的令牌族并记录来源
+func newRefreshToken(c echo.Context, userID string, parent *CoreRefreshToken) (*CoreRefreshToken, string) {
+	plain := cryptoutil.RandomToken(32)
+	now := time.Now()
+	token := &CoreRefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Token:     cryptoutil.SHA256Hex(plain),
+		ExpiresAt: now.Add(7 * 24 * time.Hour),
+		IP:        c.RealIP(),
+		UserAgent: truncate(c.Request().UserAgent(), 255),
+		LoginAt:   now,
+	}
+	if parent == nil {
+		token.FamilyID = token.ID
+	} else {
+		token.FamilyID = parent.FamilyID
+		token.ParentID = parent.ID
+		token.LoginAt = parent.LoginAt
 	}
+	return token, plain
+}
 
-	// 删除旧的刷新令牌
-	if err := h.repo.DeleteRefreshToken(c.Request().Context(), req.RefreshToken); err != nil {
-		// 即使删除失败也继续执行
+// truncate 按字节截断字符串，避免超出字段长度
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
+	return strings.ToValidUTF8(s[:n], "")
+}
 
-	return response.Success[any](c, map[string]interface{}{
-		"access_token":  tokenString,
-		"refresh_token": newRefreshToken.Token,
-		"expires_at":    claims.ExpiresAt,
-	})
+// UnlockUser 管理员解锁账号
+func (h *LoginHandler) UnlockUser(c echo.Context) error {
+	userID := c.Param("user_id")
+	u, err := h.userRepo.GetByID(c.Request().Context(), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+
+	// 写入解锁日志即可重置失败计数和锁定退避
+	if err := h.createLoginLog(c, u.ID, u.Username, AuthTypePassword, LoginTypeUnlock, "管理员解锁，操作人: "+echoutil.GetUserID(c)); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "解锁失败")
+	}
+
+	return response.SuccessWithMsg[any](c, "解锁成功", nil)
+}
+
+// lockedError 返回账号锁定错误
+func lockedError(c echo.Context, until time.Time) error {
+	msg := fmt.Sprintf("%s，解锁时间: %s", resp.ErrAccountLocked.Msg, until.Format(time.DateTime))
+	return response.Error(c, http.StatusLocked, msg)
+}
+
+// createLoginLog 记录登录日志
+func (h *LoginHandler) createLoginLog(c echo.Context, userID, username, authType, loginType, message string) error {
+	loginLog := &CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Username:  username,
+		AuthType:  authType,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	}
+	return h.repo.CreateLoginLog(c.Request().Context(), loginLog)
 }
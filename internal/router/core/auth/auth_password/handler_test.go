@@ -0,0 +1,158 @@
+package auth_password
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/jwt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "Passw0rd!"
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	jwt     *jwt.JWT
+	handler *LoginHandler
+}
+
+// newTestEnv 创建测试环境，secondFactor 为 nil 时不要求两步验证
+func newTestEnv(t *testing.T, secondFactor SecondFactor) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&CoreLoginLog{}, &CoreRefreshToken{}, &CoreLoginChallenge{},
+	)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Password: string(hash), Email: "alice@example.com", Phone: "13800000001", Status: 1}).Error)
+
+	// 锁定时长依次为 1 分钟、2 分钟、2 分 30 秒（上限）
+	lockoutCfg := config.DefaultAuthConfig().Lockout
+	lockoutCfg.MaxFailures = 3
+	lockoutCfg.LockDuration = 60
+	lockoutCfg.MaxLockDuration = 150
+	lockoutCfg.IPMaxFailures = 5
+	twoFA := config.DefaultAuthConfig().TwoFA
+	twoFA.ChallengeMaxAttempts = 2
+
+	j := testutil.NewJWT()
+	j.SetRevocationStore(jwt.NewMemoryRevocationStore())
+	repo := NewRepository(db)
+	handler := NewLoginHandler(repo, user.NewRepository(db), role.NewRoleRepo(db), j, NewLockout(repo, lockoutCfg), NewPasswordPolicy(config.DefaultAuthConfig().PasswordPolicy), false, secondFactor, twoFA)
+	return &testEnv{db: db, jwt: j, handler: handler}
+}
+
+// login 从指定 IP 发起密码登录
+func (env *testEnv) login(t *testing.T, ip, username, password string) testutil.Response {
+	req := testutil.NewRequest(t, map[string]string{"username": username, "password": password})
+	req.Header.Set(echo.HeaderXRealIP, ip)
+	return testutil.Do(t, env.handler.Login, req, "")
+}
+
+// tokens 登录成功并返回访问令牌和刷新令牌
+func (env *testEnv) tokens(t *testing.T) (string, string) {
+	resp := env.login(t, "10.0.0.1", "alice", testPassword)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	data := resp.Data.(map[string]interface{})
+	return data["access_token"].(string), data["refresh_token"].(string)
+}
+
+// lockMessages 按时间顺序返回锁定日志的描述
+func (env *testEnv) lockMessages(t *testing.T) []string {
+	var messages []string
+	require.NoError(t, env.db.Model(&CoreLoginLog{}).Where("user_id = ? AND login_type = ?", "u1", LoginTypeLocked).Order("created_at").Pluck("message", &messages).Error)
+	return messages
+}
+
+// elapse 将用户的登录日志整体提前，模拟时间流逝
+func (env *testEnv) elapse(t *testing.T, d time.Duration) {
+	var logs []CoreLoginLog
+	require.NoError(t, env.db.Where("user_id = ?", "u1").Find(&logs).Error)
+	for _, log := range logs {
+		require.NoError(t, env.db.Model(&CoreLoginLog{}).Where("id = ?", log.ID).Update("created_at", log.CreatedAt.Add(-d)).Error)
+	}
+}
+
+// TestLockoutBackoff 连续失败锁定账号，每次锁定时长翻倍且不超过上限，解锁后重置
+func TestLockoutBackoff(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	// 每轮使用不同的 IP，避免用完 IP 维度的失败次数
+	fail := func(ip string) {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusUnauthorized, env.login(t, ip, "alice", "wrong").Code)
+		}
+		resp := env.login(t, ip, "alice", "wrong")
+		assert.Equal(t, http.StatusLocked, resp.Code)
+		assert.Contains(t, resp.Msg, "解锁时间")
+	}
+
+	fail("10.0.1.1")
+	// 锁定期间正确的密码同样被拒绝
+	assert.Equal(t, http.StatusLocked, env.login(t, "10.0.1.1", "alice", testPassword).Code)
+
+	// 锁定过期后再次连续失败，锁定时长翻倍，直至上限
+	env.elapse(t, 3*time.Minute)
+	fail("10.0.1.2")
+	env.elapse(t, 3*time.Minute)
+	fail("10.0.1.3")
+	assert.Equal(t, []string{"连续登录失败，锁定 1m0s", "连续登录失败，锁定 2m0s", "连续登录失败，锁定 2m30s"}, env.lockMessages(t))
+
+	// 管理员解锁后可以立即登录，失败计数和退避周期重置
+	assert.Equal(t, http.StatusOK, testutil.Call(t, env.handler.UnlockUser, "admin", nil, "user_id", "u1").Code)
+	env.tokens(t)
+	fail("10.0.1.4")
+	assert.Equal(t, "连续登录失败，锁定 1m0s", env.lockMessages(t)[3])
+}
+
+// TestIPBudget 单个 IP 的失败次数用完后拒绝该 IP 的所有登录，不影响其他 IP
+func TestIPBudget(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, env.login(t, "10.0.0.9", "nobody", "wrong").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, env.login(t, "10.0.0.9", "alice", testPassword).Code)
+	assert.Equal(t, http.StatusOK, env.login(t, "10.0.0.2", "alice", testPassword).Code)
+
+	// 被拦截的请求不计入账号失败次数
+	var failed int64
+	require.NoError(t, env.db.Model(&CoreLoginLog{}).Where("user_id = ? AND login_type = ?", "u1", LoginTypeFailed).Count(&failed).Error)
+	assert.Zero(t, failed)
+}
+
+// TestRequireEmailVerified 要求邮箱验证时，邮箱未验证的用户不能登录，不影响使用默认配置的处理器
+func TestRequireEmailVerified(t *testing.T) {
+	env := newTestEnv(t, nil)
+	cfg := config.DefaultAuthConfig()
+	repo := NewRepository(env.db)
+	strict := NewLoginHandler(repo, user.NewRepository(env.db), role.NewRoleRepo(env.db), env.jwt, NewLockout(repo, cfg.Lockout), NewPasswordPolicy(cfg.PasswordPolicy), true, nil, cfg.TwoFA)
+
+	login := func(h *LoginHandler) testutil.Response {
+		return testutil.Call(t, h.Login, "", map[string]string{"username": "alice", "password": testPassword})
+	}
+	resp := login(strict)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, "邮箱未验证，请先完成邮箱验证", resp.Msg)
+	assert.Equal(t, http.StatusOK, login(env.handler).Code)
+
+	ok, err := user.NewRepository(env.db).MarkEmailVerified(context.Background(), "u1", "alice@example.com")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusOK, login(strict).Code)
+}
@@ -0,0 +1,147 @@
+package auth_password
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/jwt"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// TokenIssuer 登录成功后签发访问令牌和刷新令牌
+// 各种登录方式共用，保证返回给客户端的令牌结构一致
+type TokenIssuer struct {
+	repo                 *Repository
+	roleRepo             *role.RoleRepo
+	jwt                  *jwt.JWT
+	requireEmailVerified bool          // 是否要求邮箱验证后才能登录
+	secondFactor         SecondFactor  // 为 nil 时不要求两步验证
+	challengeTTL         time.Duration // 两步验证登录挑战有效期
+}
+
+// NewTokenIssuer 创建令牌签发器实例
+func NewTokenIssuer(repo *Repository, roleRepo *role.RoleRepo, jwt *jwt.JWT, requireEmailVerified bool, secondFactor SecondFactor, challengeTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		repo:                 repo,
+		roleRepo:             roleRepo,
+		jwt:                  jwt,
+		requireEmailVerified: requireEmailVerified,
+		secondFactor:         secondFactor,
+		challengeTTL:         challengeTTL,
+	}
+}
+
+// LoginLogFunc 记录登录日志，由各登录方式提供，写入各自的认证方式
+type LoginLogFunc func(loginType, message string)
+
+// Complete 第一因素校验通过后完成登录，各种登录方式共用同一套登录策略：
+// 校验用户状态和邮箱验证要求，启用了两步验证时只返回挑战令牌，由 /login/2fa 换取正式令牌，否则直接签发令牌
+func (t *TokenIssuer) Complete(c echo.Context, u *user.CoreUser, logFn LoginLogFunc) error {
+	if u.Status == 0 {
+		logFn(LoginTypeFailed, "用户已被禁用")
+		return response.Error(c, http.StatusForbidden, "用户已被禁用")
+	}
+	if emailUnverified(t.requireEmailVerified, u) {
+		logFn(LoginTypeFailed, "邮箱未验证")
+		return response.Error(c, http.StatusForbidden, "邮箱未验证，请先完成邮箱验证")
+	}
+
+	required, err := SecondFactorRequired(c.Request().Context(), t.secondFactor, u.ID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询 2FA 配置失败")
+	}
+	if required {
+		data, err := t.Challenge(c, u)
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "生成登录挑战失败")
+		}
+		logFn(LoginTypeChallenge, "认证通过，等待两步验证")
+		return response.SuccessWithMsg[any](c, "请完成两步验证", data)
+	}
+
+	data, err := t.Issue(c, u)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成令牌失败")
+	}
+	logFn(LoginTypeSuccess, "登录成功")
+	return response.Success[any](c, data)
+}
+
+// Issue 为用户开启一个新会话（新的刷新令牌族），返回登录响应数据
+func (t *TokenIssuer) Issue(c echo.Context, u *user.CoreUser) (map[string]interface{}, error) {
+	ctx := c.Request().Context()
+
+	// 生成刷新令牌，每次登录开启一个新的令牌族
+	refreshToken, plainRefreshToken := newRefreshToken(c, u.ID, nil)
+
+	// 生成访问令牌
+	accessToken, expiresAt, err := t.AccessToken(ctx, u, refreshToken.LoginAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.repo.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": plainRefreshToken,
+		"expires_at":    expiresAt,
+		"user": map[string]interface{}{
+			"id":       u.ID,
+			"username": u.Username,
+			"name":     u.Nickname,
+		},
+	}, nil
+}
+
+// Challenge 第一因素校验通过但用户启用了两步验证时签发登录挑战，
+// 客户端凭挑战令牌和验证码调用 LoginTwoFA 换取正式令牌
+func (t *TokenIssuer) Challenge(c echo.Context, u *user.CoreUser) (map[string]interface{}, error) {
+	plain := cryptoutil.RandomToken(32)
+	challenge := &CoreLoginChallenge{
+		ID:        uuid.New().String(),
+		UserID:    u.ID,
+		Token:     cryptoutil.SHA256Hex(plain),
+		ExpiresAt: time.Now().Add(t.challengeTTL),
+		IP:        c.RealIP(),
+	}
+	if err := t.repo.CreateLoginChallenge(c.Request().Context(), challenge); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"two_factor_required": true,
+		"challenge_token":     plain,
+		"expires_at":          challenge.ExpiresAt,
+	}, nil
+}
+
+// AccessToken 使用 app 持有的 JWT 实例为用户签发访问令牌，roles 为逗号分隔的角色编码
+// authTime 为会话的登录时间，写入令牌的 auth_time
+func (t *TokenIssuer) AccessToken(ctx context.Context, u *user.CoreUser, authTime time.Time) (string, time.Time, error) {
+	roles, err := t.roleRepo.GetUserRolesWithDetails(ctx, u.ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	codes := make([]string, 0, len(roles))
+	for _, r := range roles {
+		codes = append(codes, r.Code)
+	}
+
+	expiresAt := time.Now().Add(time.Duration(t.jwt.Expire))
+	token, err := t.jwt.GenerateTokenWithAuthTime(u.ID, u.Username, strings.Join(codes, ","), authTime)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
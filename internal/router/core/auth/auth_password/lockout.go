@@ -0,0 +1,150 @@
+package auth_password
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"king-starter/config"
+
+	"gorm.io/gorm"
+)
+
+// Lockout 登录锁定策略，锁定状态完全由 CoreLoginLog 推导：
+//   - failed 日志用于统计失败次数（账号维度和 IP 维度）
+//   - locked 日志表示一次锁定，锁定时长由它在当前退避周期内的序号决定
+//   - success / unlock 日志重置失败计数和退避周期
+type Lockout struct {
+	repo *Repository
+	cfg  config.LockoutConfig
+}
+
+// NewLockout 创建登录锁定策略实例
+func NewLockout(repo *Repository, cfg config.LockoutConfig) *Lockout {
+	return &Lockout{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// IPBlocked 判断 IP 在统计窗口内的失败次数是否已用完
+func (l *Lockout) IPBlocked(ctx context.Context, ip string) (bool, error) {
+	if !l.cfg.Enabled || l.cfg.IPMaxFailures <= 0 {
+		return false, nil
+	}
+	since := time.Now().Add(-seconds(l.cfg.IPWindow))
+	count, err := l.repo.CountLoginLogsByIP(ctx, ip, AuthTypePassword, LoginTypeFailed, since)
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(l.cfg.IPMaxFailures), nil
+}
+
+// FailuresExceeded 判断某种认证方式在统计窗口内的失败次数是否已用完，
+// 账号维度沿用 MaxFailures/Window，IP 维度沿用 IPMaxFailures/IPWindow。
+// 用于设备授权用户码这类只需限制猜测次数、不锁定账号的场景
+func (l *Lockout) FailuresExceeded(ctx context.Context, authType, userID, ip string) (bool, error) {
+	if !l.cfg.Enabled {
+		return false, nil
+	}
+	if l.cfg.MaxFailures > 0 && userID != "" {
+		since := time.Now().Add(-seconds(l.cfg.Window))
+		count, err := l.repo.CountLoginLogs(ctx, userID, []string{authType}, LoginTypeFailed, since)
+		if err != nil {
+			return false, err
+		}
+		if count >= int64(l.cfg.MaxFailures) {
+			return true, nil
+		}
+	}
+	if l.cfg.IPMaxFailures > 0 {
+		since := time.Now().Add(-seconds(l.cfg.IPWindow))
+		count, err := l.repo.CountLoginLogsByIP(ctx, ip, authType, LoginTypeFailed, since)
+		if err != nil {
+			return false, err
+		}
+		if count >= int64(l.cfg.IPMaxFailures) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LockedUntil 返回账号锁定的截止时间，未锁定时返回零值
+func (l *Lockout) LockedUntil(ctx context.Context, userID string) (time.Time, error) {
+	if !l.cfg.Enabled {
+		return time.Time{}, nil
+	}
+	locks, err := l.recentLocks(ctx, userID)
+	if err != nil || len(locks) == 0 {
+		return time.Time{}, err
+	}
+	last := locks[len(locks)-1]
+	until := last.CreatedAt.Add(l.lockDuration(len(locks)))
+	if until.After(time.Now()) {
+		return until, nil
+	}
+	return time.Time{}, nil
+}
+
+// NextLock 在记录一次登录失败之后调用，返回本次需要锁定的时长，0 表示无需锁定
+func (l *Lockout) NextLock(ctx context.Context, userID string) (time.Duration, error) {
+	if !l.cfg.Enabled || l.cfg.MaxFailures <= 0 {
+		return 0, nil
+	}
+
+	// 只统计窗口内、且在最近一次成功、解锁或锁定之后的失败
+	since := time.Now().Add(-seconds(l.cfg.Window))
+	last, err := l.repo.GetLatestLoginLog(ctx, userID, []string{LoginTypeSuccess, LoginTypeUnlock, LoginTypeLocked})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	if last != nil && last.CreatedAt.After(since) {
+		since = last.CreatedAt
+	}
+
+	// 两步验证失败同样计入，防止拿到密码后通过反复发起登录挑战暴力破解验证码
+	count, err := l.repo.CountLoginLogs(ctx, userID, []string{AuthTypePassword, AuthType2FA, AuthTypeRecovery}, LoginTypeFailed, since)
+	if err != nil {
+		return 0, err
+	}
+	if count < int64(l.cfg.MaxFailures) {
+		return 0, nil
+	}
+
+	locks, err := l.recentLocks(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return l.lockDuration(len(locks) + 1), nil
+}
+
+// recentLocks 查询当前退避周期内的锁定记录
+func (l *Lockout) recentLocks(ctx context.Context, userID string) ([]CoreLoginLog, error) {
+	since := time.Now().Add(-seconds(l.cfg.BackoffReset))
+	last, err := l.repo.GetLatestLoginLog(ctx, userID, []string{LoginTypeSuccess, LoginTypeUnlock})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if last != nil && last.CreatedAt.After(since) {
+		since = last.CreatedAt
+	}
+	return l.repo.ListLoginLogs(ctx, userID, []string{LoginTypeLocked}, since)
+}
+
+// lockDuration 第 n 次锁定的时长：LockDuration * 2^(n-1)，不超过 MaxLockDuration
+func (l *Lockout) lockDuration(n int) time.Duration {
+	d := seconds(l.cfg.LockDuration)
+	max := seconds(l.cfg.MaxLockDuration)
+	for i := 1; i < n && (max <= 0 || d < max); i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,11 @@
+package auth_password
+
+import (
+	"king-starter/internal/router/core/user"
+)
+
+// emailUnverified 要求邮箱验证后才能登录时，用户是否因邮箱未验证而不能登录
+// 各种登录方式在第一因素校验通过后检查，requireEmailVerified 来自配置 Auth.RequireEmailVerified
+func emailUnverified(requireEmailVerified bool, u *user.CoreUser) bool {
+	return requireEmailVerified && u.EmailVerifiedAt == nil
+}
@@ -11,14 +11,14 @@ type CoreLoginLog struct {
 	Username   string    `gorm:"type:varchar(50)" json:"username"`
 	AuthType   string    `gorm:"type:varchar(20);index" json:"auth_type"`            // 认证类型
 	LoginType  string    `gorm:"type:varchar(10);index" json:"login_type"`           // 登录类型
-	IP         string    `gorm:"type:varchar(50)" json:"ip"`                         // IP地址
+	IP         string    `gorm:"type:varchar(50);index" json:"ip"`                   // IP地址
 	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent"`                // 用户代理
 	DeviceInfo string    `gorm:"embedded;embeddedPrefix:device_" json:"device_info"` // 设备信息
 	Country    string    `gorm:"type:varchar(50)" json:"country"`                    // 国家
 	Province   string    `gorm:"type:varchar(50)" json:"province"`                   // 省份
 	City       string    `gorm:"type:varchar(50)" json:"city"`                       // 城市
 	Message    string    `gorm:"type:varchar(255)" json:"message"`                   // 描述信息
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"created_at"`
 }
 
 // TableName 指定表名
@@ -27,16 +27,44 @@ func (CoreLoginLog) TableName() string {
 }
 
 // CoreRefreshToken 刷新令牌模型
+//
+// 同一次登录签发的刷新令牌及其轮换出的后代属于同一个令牌族（FamilyID）。
+// 轮换时旧令牌只标记 RotatedAt 而不删除，若已轮换的令牌再次出现则视为重放，整个令牌族被吊销。
 type CoreRefreshToken struct {
-	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	UserID    string    `gorm:"type:varchar(36);index" json:"user_id"`
-	Token     string    `gorm:"type:varchar(255);uniqueIndex" json:"token"`
-	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string     `gorm:"type:varchar(36);index" json:"user_id"`
+	FamilyID  string     `gorm:"type:varchar(36);index" json:"family_id"` // 令牌族ID
+	ParentID  string     `gorm:"type:varchar(36)" json:"parent_id"`       // 轮换来源令牌ID，族内首个令牌为空
+	Token     string     `gorm:"type:varchar(255);uniqueIndex" json:"-"`  // 令牌的 SHA-256 哈希
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`                // 轮换时间
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`                // 吊销时间
+	IP        string     `gorm:"type:varchar(50)" json:"ip"`          // 签发时的IP
+	UserAgent string     `gorm:"type:varchar(255)" json:"user_agent"` // 签发时的用户代理
+	LoginAt   time.Time  `json:"login_at"`                            // 会话登录时间，令牌族内保持不变
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
 func (CoreRefreshToken) TableName() string {
 	return "core_refresh_tokens"
 }
+
+// CoreLoginChallenge 两步验证登录挑战
+// 密码校验通过但启用了 2FA 时签发，客户端凭挑战令牌和验证码换取正式令牌
+type CoreLoginChallenge struct {
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string     `gorm:"type:varchar(36);index" json:"user_id"`
+	Token     string     `gorm:"type:varchar(64);uniqueIndex" json:"-"` // 挑战令牌的 SHA-256 哈希
+	Attempts  int        `gorm:"default:0" json:"attempts"`             // 已验证次数
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	IP        string     `gorm:"type:varchar(50)" json:"ip"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CoreLoginChallenge) TableName() string {
+	return "core_login_challenges"
+}
@@ -0,0 +1,57 @@
+package auth_password
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+
+	"king-starter/config"
+)
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	cfg config.PasswordPolicyConfig
+}
+
+// NewPasswordPolicy 创建密码策略实例
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig) *PasswordPolicy {
+	return &PasswordPolicy{cfg: cfg}
+}
+
+// Check 校验密码是否满足策略，返回的错误信息可直接展示给用户
+func (p *PasswordPolicy) Check(password string) error {
+	if len([]rune(password)) < p.cfg.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", p.cfg.MinLength)
+	}
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		return fmt.Errorf("密码长度不能超过%d位", p.cfg.MaxLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsSpace(r):
+		default:
+			symbol = true
+		}
+	}
+	if p.cfg.RequireLetter && !lower && !upper {
+		return errors.New("密码必须包含字母")
+	}
+	if p.cfg.RequireUpper && !(lower && upper) {
+		return errors.New("密码必须同时包含大写和小写字母")
+	}
+	if p.cfg.RequireDigit && !digit {
+		return errors.New("密码必须包含数字")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		return errors.New("密码必须包含特殊字符")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package auth_password
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"king-starter/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (env *testEnv) refresh(t *testing.T, refreshToken string) testutil.Response {
+	return testutil.Call(t, env.handler.RefreshToken, "", map[string]string{"refresh_token": refreshToken})
+}
+
+// TestRefreshReuseRevokesFamily 已轮换的刷新令牌被重放时吊销整个令牌族，不影响其他会话
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	env := newTestEnv(t, nil)
+	_, r1 := env.tokens(t)
+	_, other := env.tokens(t)
+
+	resp := env.refresh(t, r1)
+	require.Equal(t, http.StatusOK, resp.Code)
+	r2 := resp.Data.(map[string]interface{})["refresh_token"].(string)
+	assert.NotEqual(t, r1, r2)
+
+	// 重放已轮换的令牌，整个令牌族失效
+	assert.Equal(t, http.StatusUnauthorized, env.refresh(t, r1).Code)
+	assert.Equal(t, http.StatusUnauthorized, env.refresh(t, r2).Code)
+
+	var reuse int64
+	require.NoError(t, env.db.Model(&CoreLoginLog{}).Where("user_id = ? AND login_type = ?", "u1", LoginTypeReuse).Count(&reuse).Error)
+	assert.EqualValues(t, 1, reuse)
+
+	// 其他会话不受影响
+	assert.Equal(t, http.StatusOK, env.refresh(t, other).Code)
+}
+
+// TestRefreshConcurrentRotation 并发使用同一刷新令牌时只有一个请求成功
+func TestRefreshConcurrentRotation(t *testing.T) {
+	env := newTestEnv(t, nil)
+	_, refreshToken := env.tokens(t)
+
+	var (
+		wg sync.WaitGroup
+		ok atomic.Int32
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if env.refresh(t, refreshToken).Code == http.StatusOK {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, ok.Load())
+}
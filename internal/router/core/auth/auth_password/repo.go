@@ -2,6 +2,7 @@ package auth_password
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -21,22 +22,124 @@ func (r *Repository) CreateRefreshToken(ctx context.Context, token *CoreRefreshT
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
-// GetRefreshTokenByToken 根据令牌获取刷新令牌
-func (r *Repository) GetRefreshTokenByToken(ctx context.Context, token string) (*CoreRefreshToken, error) {
+// GetRefreshTokenByToken 根据令牌哈希获取刷新令牌
+func (r *Repository) GetRefreshTokenByToken(ctx context.Context, tokenHash string) (*CoreRefreshToken, error) {
 	var refreshToken CoreRefreshToken
-	err := r.db.WithContext(ctx).Where("token = ?", token).First(&refreshToken).Error
+	err := r.db.WithContext(ctx).Where("token = ?", tokenHash).First(&refreshToken).Error
 	if err != nil {
 		return nil, err
 	}
 	return &refreshToken, nil
 }
 
-// DeleteRefreshToken 删除刷新令牌
-func (r *Repository) DeleteRefreshToken(ctx context.Context, token string) error {
-	return r.db.WithContext(ctx).Where("token = ?", token).Delete(&CoreRefreshToken{}).Error
+// RotateRefreshToken 轮换刷新令牌：将旧令牌标记为已轮换并创建新令牌
+// 旧令牌已被轮换或吊销（例如并发刷新）时返回 false，由调用方按令牌重放处理
+func (r *Repository) RotateRefreshToken(ctx context.Context, oldID string, newToken *CoreRefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&CoreRefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", oldID).
+			Update("rotated_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		rotated = true
+		return tx.Create(newToken).Error
+	})
+	return rotated, err
+}
+
+// RevokeRefreshTokenFamily 吊销整个令牌族
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&CoreRefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserRefreshTokens 吊销用户的所有刷新令牌
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&CoreRefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// CreateLoginChallenge 创建登录挑战
+func (r *Repository) CreateLoginChallenge(ctx context.Context, challenge *CoreLoginChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+// GetLoginChallengeByToken 根据令牌哈希获取登录挑战
+func (r *Repository) GetLoginChallengeByToken(ctx context.Context, tokenHash string) (*CoreLoginChallenge, error) {
+	var challenge CoreLoginChallenge
+	err := r.db.WithContext(ctx).Where("token = ?", tokenHash).First(&challenge).Error
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// ClaimLoginChallengeAttempt 占用一次登录挑战的验证次数，返回 false 表示次数已用完或挑战已使用
+// 以条件更新原子地计数，并发验证同一挑战时总次数不会超过 maxAttempts
+func (r *Repository) ClaimLoginChallengeAttempt(ctx context.Context, id string, maxAttempts int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&CoreLoginChallenge{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+// UseLoginChallenge 标记登录挑战已使用，返回是否标记成功（并发请求中只有一个能成功）
+func (r *Repository) UseLoginChallenge(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&CoreLoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
 }
 
 // CreateLoginLog 创建登录日志
 func (r *Repository) CreateLoginLog(ctx context.Context, log *CoreLoginLog) error {
 	return r.db.WithContext(ctx).Create(log).Error
 }
+
+// CountLoginLogs 统计用户在指定时间之后若干认证方式、某种登录类型的日志数量
+func (r *Repository) CountLoginLogs(ctx context.Context, userID string, authTypes []string, loginType string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&CoreLoginLog{}).
+		Where("user_id = ? AND auth_type IN ? AND login_type = ? AND created_at > ?", userID, authTypes, loginType, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountLoginLogsByIP 统计 IP 在指定时间之后某种认证方式、某种登录类型的日志数量
+func (r *Repository) CountLoginLogsByIP(ctx context.Context, ip, authType, loginType string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&CoreLoginLog{}).
+		Where("ip = ? AND auth_type = ? AND login_type = ? AND created_at > ?", ip, authType, loginType, since).
+		Count(&count).Error
+	return count, err
+}
+
+// ListLoginLogs 查询用户在指定时间之后某些登录类型的日志，按时间升序
+func (r *Repository) ListLoginLogs(ctx context.Context, userID string, loginTypes []string, since time.Time) ([]CoreLoginLog, error) {
+	var logs []CoreLoginLog
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND login_type IN ? AND created_at > ?", userID, loginTypes, since).
+		Order("created_at ASC").
+		Find(&logs).Error
+	return logs, err
+}
+
+// GetLatestLoginLog 获取用户最近一条属于指定登录类型的日志
+func (r *Repository) GetLatestLoginLog(ctx context.Context, userID string, loginTypes []string) (*CoreLoginLog, error) {
+	var log CoreLoginLog
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND login_type IN ?", userID, loginTypes).
+		Order("created_at DESC").
+		First(&log).Error
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
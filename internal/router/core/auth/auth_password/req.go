@@ -25,3 +25,10 @@ type LogoutReq struct {
 type RefreshTokenReq struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
+
+// LoginTwoFAReq 两步验证登录请求参数，Code 和 RecoveryCode 二选一
+type LoginTwoFAReq struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code,omitempty"`          // TOTP 验证码
+	RecoveryCode   string `json:"recovery_code,omitempty"` // 恢复码
+}
@@ -0,0 +1,60 @@
+package auth_password
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/jwt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLogoutRevokesTokens 登出吊销当前访问令牌和刷新令牌所在的令牌族
+func TestLogoutRevokesTokens(t *testing.T) {
+	env := newTestEnv(t, nil)
+	accessToken, refreshToken := env.tokens(t)
+
+	req := testutil.NewRequest(t, map[string]string{"refresh_token": refreshToken})
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	require.Equal(t, http.StatusOK, testutil.Do(t, env.handler.Logout, req, "").Code)
+
+	_, err := env.jwt.ParseTokenWithContext(context.Background(), accessToken)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	assert.Equal(t, http.StatusUnauthorized, env.refresh(t, refreshToken).Code)
+}
+
+// TestChangePasswordRevokesTokens 修改密码后吊销该用户所有会话
+func TestChangePasswordRevokesTokens(t *testing.T) {
+	env := newTestEnv(t, nil)
+	a1, r1 := env.tokens(t)
+	a2, r2 := env.tokens(t)
+
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.ChangePassword, "u1", map[string]string{"old_password": "wrong", "new_password": "NewPassw0rd"}).Code)
+	require.Equal(t, http.StatusOK, testutil.Call(t, env.handler.ChangePassword, "u1", map[string]string{"old_password": testPassword, "new_password": "NewPassw0rd"}).Code)
+
+	for _, accessToken := range []string{a1, a2} {
+		_, err := env.jwt.ParseTokenWithContext(context.Background(), accessToken)
+		assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	}
+	for _, refreshToken := range []string{r1, r2} {
+		assert.Equal(t, http.StatusUnauthorized, env.refresh(t, refreshToken).Code)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, env.login(t, "10.0.0.1", "alice", testPassword).Code)
+	assert.Equal(t, http.StatusOK, env.login(t, "10.0.0.1", "alice", "NewPassw0rd").Code)
+}
+
+// TestRefreshDisabledUser 用户被禁用后不能再刷新令牌
+func TestRefreshDisabledUser(t *testing.T) {
+	env := newTestEnv(t, nil)
+	_, refreshToken := env.tokens(t)
+
+	require.NoError(t, user.NewRepository(env.db).UpdateStatus(context.Background(), "u1", 0))
+	assert.Equal(t, http.StatusForbidden, env.refresh(t, refreshToken).Code)
+	assert.Equal(t, http.StatusForbidden, env.login(t, "10.0.0.1", "alice", testPassword).Code)
+}
@@ -2,29 +2,42 @@ package auth_password
 
 import (
 	"king-starter/internal/app"
+	"king-starter/internal/common"
+	"king-starter/internal/router/core/role"
 	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
 )
 
 func RegisterAutoMigrate(app *app.App) {
 	app.Db.AutoMigrate(
 		&CoreLoginLog{},
 		&CoreRefreshToken{},
+		&CoreLoginChallenge{},
 	)
 }
 
-// RegisterRoutes 注册密码认证路由
-func RegisterRoutes(app *app.App) {
+// RegisterRoutes 注册密码认证路由，启用 2FA 的用户密码登录后需提交验证码（POST /api/core/auth/login/2fa）
+func RegisterRoutes(app *app.App, secondFactor SecondFactor) {
 	repo := NewRepository(app.Db.DB)
-	handler := NewLoginHandler(repo, user.NewRepository(app.Db.DB))
+	lockout := NewLockout(repo, app.Config.Auth.Lockout)
+	handler := NewLoginHandler(repo, user.NewRepository(app.Db.DB), role.NewRoleRepo(app.Db.DB), app.Jwt, lockout, NewPasswordPolicy(app.Config.Auth.PasswordPolicy), app.Config.Auth.RequireEmailVerified, secondFactor, app.Config.Auth.TwoFA)
 
 	e := app.Server.Engine()
 
 	// 密码认证路由组
 	authGroup := e.Group("/api/core/auth")
 	{
-		authGroup.POST("/register", handler.Register) // 注册
-		authGroup.POST("/login", handler.Login)       // 密码登录
+		authGroup.POST("/register", handler.Register)    // 注册
+		authGroup.POST("/login", handler.Login)          // 密码登录
+		authGroup.POST("/login/2fa", handler.LoginTwoFA) // 两步验证登录
 		authGroup.POST("/logout", handler.Logout)
 		authGroup.POST("/refresh", handler.RefreshToken)
+		authGroup.POST("/password", handler.ChangePassword, middleware.JWTAuthMiddleware(app.Jwt)) // 修改密码
+	}
+
+	// 管理员接口
+	adminGroup := e.Group("/api/core/auth/admin", middleware.JWTAuthMiddleware(app.Jwt), middleware.RequireRoles(common.AdminRoleCode))
+	{
+		adminGroup.POST("/users/:user_id/unlock", handler.UnlockUser) // 解锁账号
 	}
 }
@@ -0,0 +1,23 @@
+package auth_password
+
+import (
+	"context"
+)
+
+// SecondFactor 第二因素认证
+// 由 auth_2fa 模块实现，在认证路由注册阶段通过构造函数注入，避免 auth_password 反向依赖 auth_2fa
+type SecondFactor interface {
+	// Enabled 用户是否启用了第二因素认证
+	Enabled(ctx context.Context, userID string) (bool, error)
+	// Verify 校验验证码或恢复码，返回实际使用的认证类型（写入登录日志）
+	Verify(ctx context.Context, userID, code, recoveryCode string) (authType string, ok bool, err error)
+}
+
+// SecondFactorRequired 用户登录是否需要第二因素认证，sf 为 nil 时表示未启用两步验证
+// 第一因素登录方式通过 TokenIssuer.Complete 统一处理，无法完成两步验证的方式（如 OAuth2 密码模式）直接调用此方法拒绝登录
+func SecondFactorRequired(ctx context.Context, sf SecondFactor, userID string) (bool, error) {
+	if sf == nil {
+		return false, nil
+	}
+	return sf.Enabled(ctx, userID)
+}
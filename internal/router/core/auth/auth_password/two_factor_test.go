@@ -0,0 +1,98 @@
+package auth_password
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"king-starter/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecondFactor 验证码固定为 123456，恢复码固定为 recovery-code
+type fakeSecondFactor struct {
+	verified atomic.Int32 // Verify 调用次数
+}
+
+func (f *fakeSecondFactor) Enabled(ctx context.Context, userID string) (bool, error) {
+	return userID == "u1", nil
+}
+
+func (f *fakeSecondFactor) Verify(ctx context.Context, userID, code, recoveryCode string) (string, bool, error) {
+	f.verified.Add(1)
+	if recoveryCode != "" {
+		return AuthTypeRecovery, recoveryCode == "recovery-code", nil
+	}
+	return AuthType2FA, code == "123456", nil
+}
+
+// challenge 密码登录并返回两步验证挑战令牌
+func (env *testEnv) challenge(t *testing.T) string {
+	resp := env.login(t, "10.0.0.1", "alice", testPassword)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	data := resp.Data.(map[string]interface{})
+	require.Equal(t, true, data["two_factor_required"])
+	assert.Nil(t, data["access_token"])
+	return data["challenge_token"].(string)
+}
+
+func (env *testEnv) loginTwoFA(t *testing.T, challengeToken, code, recoveryCode string) testutil.Response {
+	return testutil.Call(t, env.handler.LoginTwoFA, "", map[string]string{"challenge_token": challengeToken, "code": code, "recovery_code": recoveryCode})
+}
+
+// TestLoginTwoFA 启用两步验证时密码登录只返回挑战令牌，凭验证码或恢复码换取正式令牌
+func TestLoginTwoFA(t *testing.T) {
+	env := newTestEnv(t, &fakeSecondFactor{})
+
+	challengeToken := env.challenge(t)
+	assert.Equal(t, http.StatusUnauthorized, env.loginTwoFA(t, challengeToken, "000000", "").Code)
+	resp := env.loginTwoFA(t, challengeToken, "123456", "")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	assert.NotEmpty(t, resp.Data.(map[string]interface{})["access_token"])
+
+	// 挑战只能使用一次
+	assert.Equal(t, http.StatusUnauthorized, env.loginTwoFA(t, challengeToken, "123456", "").Code)
+
+	// 恢复码登录
+	resp = env.loginTwoFA(t, env.challenge(t), "", "recovery-code")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	var recovery int64
+	require.NoError(t, env.db.Model(&CoreLoginLog{}).Where("auth_type = ? AND login_type = ?", AuthTypeRecovery, LoginTypeSuccess).Count(&recovery).Error)
+	assert.EqualValues(t, 1, recovery)
+
+	// 超过最大验证次数后挑战失效，正确的验证码也不再接受
+	challengeToken = env.challenge(t)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, env.loginTwoFA(t, challengeToken, "000000", "").Code)
+	}
+	assert.Equal(t, http.StatusUnauthorized, env.loginTwoFA(t, challengeToken, "123456", "").Code)
+
+	// 两步验证失败计入账号锁定
+	assert.Equal(t, http.StatusLocked, env.loginTwoFA(t, env.challenge(t), "000000", "").Code)
+}
+
+// TestLoginTwoFAConcurrentAttempts 并发猜测同一挑战时，验证次数不超过上限
+func TestLoginTwoFAConcurrentAttempts(t *testing.T) {
+	sf := &fakeSecondFactor{}
+	env := newTestEnv(t, sf)
+	challengeToken := env.challenge(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env.loginTwoFA(t, challengeToken, "000000", "")
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, sf.verified.Load(), int32(2))
+	var challenge CoreLoginChallenge
+	require.NoError(t, env.db.First(&challenge).Error)
+	assert.Equal(t, 2, challenge.Attempts)
+}
@@ -5,11 +5,19 @@ const (
 	AuthTypeEmail    string = "email"    // 邮箱认证
 	AuthTypePhone    string = "phone"    // 手机认证
 	AuthType2FA      string = "2fa"      // 两步验证
+	AuthTypeRecovery string = "recovery" // 2FA 恢复码
 	AuthTypeOAuth2   string = "oauth2"   // OAuth2 认证
 	AuthTypeSSO      string = "sso"      // 单点登录
+	AuthTypeRefresh  string = "refresh"  // 刷新令牌
+	AuthTypeDevice   string = "device"   // 设备授权用户码
 )
 
 const (
-	LoginTypeSuccess string = "success" // 登录成功
-	LoginTypeFailed  string = "failed"  // 登录失败
+	LoginTypeSuccess   string = "success"   // 登录成功
+	LoginTypeFailed    string = "failed"    // 登录失败
+	LoginTypeBlocked   string = "blocked"   // 被锁定策略拦截（不计入失败次数）
+	LoginTypeLocked    string = "locked"    // 账号被锁定
+	LoginTypeUnlock    string = "unlock"    // 管理员解锁
+	LoginTypeReuse     string = "reuse"     // 已轮换的刷新令牌被重放
+	LoginTypeChallenge string = "challenge" // 第一因素校验通过，等待两步验证
 )
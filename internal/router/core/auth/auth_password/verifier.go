@@ -0,0 +1,145 @@
+package auth_password
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/resp"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIPBlocked          = errors.New("too many failed logins from ip")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserDisabled       = errors.New("user disabled")
+	ErrEmailUnverified    = errors.New("email not verified")
+)
+
+// LockedError 账号处于锁定期
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.DateTime))
+}
+
+// PasswordVerifier 校验账号密码，包含 IP 限流、账号锁定和用户状态检查
+// 密码登录和 OAuth2 密码模式共用，失败次数统一计入锁定策略
+type PasswordVerifier struct {
+	repo                 *Repository
+	userRepo             *user.Repository
+	lockout              *Lockout
+	requireEmailVerified bool // 是否要求邮箱验证后才能登录
+}
+
+// NewPasswordVerifier 创建账号密码校验器
+func NewPasswordVerifier(repo *Repository, userRepo *user.Repository, lockout *Lockout, requireEmailVerified bool) *PasswordVerifier {
+	return &PasswordVerifier{
+		repo:                 repo,
+		userRepo:             userRepo,
+		lockout:              lockout,
+		requireEmailVerified: requireEmailVerified,
+	}
+}
+
+// Verify 校验账号密码，账号可以是用户名、邮箱或手机号
+// 失败时记录登录日志并返回 ErrIPBlocked、*LockedError、ErrInvalidCredentials、ErrUserDisabled 或 ErrEmailUnverified，
+// 成功日志由调用方在签发令牌后记录
+func (v *PasswordVerifier) Verify(c echo.Context, account, password string) (*user.CoreUser, error) {
+	ctx := c.Request().Context()
+
+	// IP 维度的失败次数预算，防止撞库
+	blocked, err := v.lockout.IPBlocked(ctx, c.RealIP())
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		v.createLoginLog(c, "", account, LoginTypeBlocked, "IP 登录失败次数过多")
+		return nil, ErrIPBlocked
+	}
+
+	// 支持用户名、邮箱、手机号登录
+	u, err := v.userRepo.GetByAccount(ctx, account)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// 用户不存在时与密码错误返回相同提示，避免泄露账号是否存在
+		v.createLoginLog(c, "", account, LoginTypeFailed, "用户不存在")
+		return nil, ErrInvalidCredentials
+	}
+
+	// 账号锁定期间不再校验密码
+	lockedUntil, err := v.lockout.LockedUntil(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !lockedUntil.IsZero() {
+		v.createLoginLog(c, u.ID, u.Username, LoginTypeBlocked, "账号已锁定")
+		return nil, &LockedError{Until: lockedUntil}
+	}
+
+	// 校验密码哈希
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+		v.createLoginLog(c, u.ID, u.Username, LoginTypeFailed, "用户名或密码错误")
+		// 失败次数达到阈值时锁定账号
+		if d, err := v.lockout.NextLock(ctx, u.ID); err == nil && d > 0 {
+			v.createLoginLog(c, u.ID, u.Username, LoginTypeLocked, fmt.Sprintf("连续登录失败，锁定 %s", d))
+			return nil, &LockedError{Until: time.Now().Add(d)}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// 检查用户状态
+	if u.Status == 0 {
+		v.createLoginLog(c, u.ID, u.Username, LoginTypeFailed, "用户已被禁用")
+		return nil, ErrUserDisabled
+	}
+	if emailUnverified(v.requireEmailVerified, u) {
+		v.createLoginLog(c, u.ID, u.Username, LoginTypeFailed, "邮箱未验证")
+		return nil, ErrEmailUnverified
+	}
+	return u, nil
+}
+
+// createLoginLog 记录密码校验日志
+func (v *PasswordVerifier) createLoginLog(c echo.Context, userID, username, loginType, message string) {
+	v.repo.CreateLoginLog(c.Request().Context(), &CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Username:  username,
+		AuthType:  AuthTypePassword,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	})
+}
+
+// VerifyError 将 Verify 返回的错误转换为响应
+func VerifyError(c echo.Context, err error) error {
+	var lockedErr *LockedError
+	switch {
+	case errors.As(err, &lockedErr):
+		return lockedError(c, lockedErr.Until)
+	case errors.Is(err, ErrIPBlocked):
+		return response.Error(c, http.StatusTooManyRequests, resp.ErrTooManyRequests.Msg)
+	case errors.Is(err, ErrInvalidCredentials):
+		return response.Error(c, http.StatusUnauthorized, "用户名或密码错误")
+	case errors.Is(err, ErrUserDisabled):
+		return response.Error(c, http.StatusForbidden, "用户已被禁用")
+	case errors.Is(err, ErrEmailUnverified):
+		return response.Error(c, http.StatusForbidden, "邮箱未验证，请先完成邮箱验证")
+	default:
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+}
@@ -0,0 +1,22 @@
+package auth_revocation
+
+import (
+	"time"
+)
+
+// CoreTokenRevocation 访问令牌吊销记录
+//
+// 单个令牌的记录以 jti 为主键；用户级记录以 "user:<用户ID>" 为主键，
+// RevokedBefore 之前签发的该用户令牌均视为已吊销。ExpiresAt 之后记录可被清理。
+type CoreTokenRevocation struct {
+	ID            string     `gorm:"primaryKey;type:varchar(100)" json:"id"`
+	UserID        string     `gorm:"type:varchar(36);index" json:"user_id"`
+	RevokedBefore *time.Time `json:"revoked_before,omitempty"` // 用户级吊销截止时间
+	ExpiresAt     time.Time  `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CoreTokenRevocation) TableName() string {
+	return "core_token_revocations"
+}
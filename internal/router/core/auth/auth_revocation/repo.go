@@ -0,0 +1,79 @@
+package auth_revocation
+
+import (
+	"context"
+	"time"
+
+	"king-starter/pkg/jwt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userKeyPrefix 用户级吊销记录的主键前缀
+const userKeyPrefix = "user:"
+
+// Repository 访问令牌吊销仓库，jwt.RevocationStore 的数据库实现
+type Repository struct {
+	db *gorm.DB
+}
+
+var _ jwt.RevocationStore = (*Repository)(nil)
+
+// NewRepository 创建访问令牌吊销仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Revoke 吊销单个令牌
+func (r *Repository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	record := CoreTokenRevocation{ID: jti, ExpiresAt: expiresAt}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&record).Error
+}
+
+// RevokeUser 吊销用户在 before 之前签发的所有令牌，已有记录时更新截止时间
+func (r *Repository) RevokeUser(ctx context.Context, userID string, before, expiresAt time.Time) error {
+	record := CoreTokenRevocation{
+		ID:            userKeyPrefix + userID,
+		UserID:        userID,
+		RevokedBefore: &before,
+		ExpiresAt:     expiresAt,
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at"}),
+		}).
+		Create(&record).Error
+}
+
+// IsRevoked 检查令牌是否已被吊销
+func (r *Repository) IsRevoked(ctx context.Context, jti, userID string, issuedAt time.Time) (bool, error) {
+	ids := []string{userKeyPrefix + userID}
+	if jti != "" {
+		ids = append(ids, jti)
+	}
+
+	var records []CoreTokenRevocation
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&records).Error; err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if record.RevokedBefore == nil {
+			return true, nil
+		}
+		if jwt.IssuedBefore(issuedAt, *record.RevokedBefore) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Purge 清理已过期的记录
+func (r *Repository) Purge(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Delete(&CoreTokenRevocation{}).Error
+}
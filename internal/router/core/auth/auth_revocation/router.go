@@ -0,0 +1,60 @@
+package auth_revocation
+
+import (
+	"context"
+	"time"
+
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/jwt"
+	"king-starter/pkg/logx"
+)
+
+func RegisterAutoMigrate(app *app.App) {
+	app.Db.AutoMigrate(
+		&CoreTokenRevocation{},
+	)
+}
+
+// RegisterRoutes 挂载访问令牌吊销存储
+// 本模块没有 HTTP 路由：设置 app.Jwt 的吊销存储、启动过期记录清理（随 App 关闭退出），并在禁用用户时吊销其访问令牌
+func RegisterRoutes(app *app.App, users *user.Repository) {
+	cfg := app.Config.Auth.Revocation
+
+	var store jwt.RevocationStore
+	if cfg.Store == "memory" {
+		store = jwt.NewMemoryRevocationStore()
+	} else {
+		store = NewRepository(app.Db.DB)
+	}
+	app.Jwt.SetRevocationStore(store)
+
+	// 禁用用户时吊销其所有访问令牌
+	users.OnStatusChange(func(ctx context.Context, userID string, status int) error {
+		if status != 0 {
+			return nil
+		}
+		return app.Jwt.RevokeUser(ctx, userID)
+	})
+
+	go purge(app.Context(), store, time.Duration(cfg.PurgeInterval)*time.Second)
+}
+
+// purge 定期清理过期的吊销记录，ctx 取消（应用关闭）时退出
+func purge(ctx context.Context, store jwt.RevocationStore, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.Purge(ctx); err != nil {
+				logx.Error("purge token revocations failed", "error", err)
+			}
+		}
+	}
+}
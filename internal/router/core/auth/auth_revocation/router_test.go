@@ -0,0 +1,72 @@
+package auth_revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/database"
+	"king-starter/pkg/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// TestDisableRevokesTokens 禁用用户时吊销其访问令牌，每个 App 各自挂载吊销存储和钩子
+func TestDisableRevokesTokens(t *testing.T) {
+	cfg := config.DefaultConfig()
+
+	// 同一进程内的两个 App 互不影响，后创建的 App 同样启用吊销
+	for i := 0; i < 2; i++ {
+		db := testutil.NewDB(t, &user.CoreUser{}, &CoreTokenRevocation{})
+		require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Status: 1}).Error)
+
+		// 统计写入的吊销记录次数
+		var revocations int
+		require.NoError(t, db.Callback().Create().After("gorm:create").Register("test:count_revocations", func(tx *gorm.DB) {
+			if tx.Statement.Table == (CoreTokenRevocation{}).TableName() {
+				revocations++
+			}
+		}))
+
+		a := &app.App{Config: &cfg, Db: &database.DB{DB: db}, Jwt: testutil.NewJWT()}
+		users := user.NewRepository(db)
+		RegisterRoutes(a, users)
+
+		token, err := a.Jwt.GenerateToken("u1", "alice", "")
+		require.NoError(t, err)
+		_, err = a.Jwt.ParseToken(token)
+		require.NoError(t, err)
+
+		require.NoError(t, users.UpdateStatus(context.Background(), "u1", 0))
+		assert.Equal(t, 1, revocations)
+		_, err = a.Jwt.ParseToken(token)
+		assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	}
+}
+
+// TestPurgeStopsOnCancel 上下文取消后清理任务退出
+func TestPurgeStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		purge(ctx, jwt.NewMemoryRevocationStore(), time.Millisecond)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purge did not stop after context cancel")
+	}
+}
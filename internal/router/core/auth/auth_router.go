@@ -2,27 +2,73 @@ package auth
 
 import (
 	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_2fa"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_email"
+	"king-starter/internal/router/core/auth/auth_federated"
+	"king-starter/internal/router/core/auth/auth_oauth2"
+	"king-starter/internal/router/core/auth/auth_passkey"
 	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/auth/auth_revocation"
+	"king-starter/internal/router/core/auth/auth_session"
+	"king-starter/internal/router/core/auth/auth_sms"
+	"king-starter/internal/router/core/auth/auth_verify"
+	"king-starter/internal/router/core/auth/auth_wellknown"
+	"king-starter/internal/router/core/user"
 )
 
 func RegisterAutoMigrate(app *app.App) {
 	auth_password.RegisterAutoMigrate(app)
-	// auth_email.RegisterAutoMigrate(app)
-	// auth_2fa.RegisterAutoMigrate(app)
-	// auth_oauth2.RegisterAutoMigrate(app)
+	auth_revocation.RegisterAutoMigrate(app)
+	auth_code.RegisterAutoMigrate(app)
+	auth_2fa.RegisterAutoMigrate(app)
+	auth_passkey.RegisterAutoMigrate(app)
+	auth_oauth2.RegisterAutoMigrate(app)
+	auth_federated.RegisterAutoMigrate(app)
+}
+
+// RegisterScopes 登记配置文件中的 OAuth2 业务权限范围
+// 需在注册任何路由之前调用，其他模块才能在注册路由时通过 RequireScopes 使用这些权限范围
+func RegisterScopes(app *app.App) {
+	auth_oauth2.RegisterConfigScopes(app.Config.Auth.OAuth2.Scopes)
 }
 
 // RegisterAuthRoutes 注册所有认证相关路由
-func RegisterAuthRoutes(app *app.App) {
+// users 为 user 模块路由使用的 Repository，禁用用户时由各认证模块挂载的回调吊销令牌和会话
+func RegisterAuthRoutes(app *app.App, users *user.Repository) {
+	// 挂载访问令牌吊销存储，需在其他认证路由之前
+	auth_revocation.RegisterRoutes(app, users)
+
+	// 两步验证，各种登录方式在第一因素校验通过后检查
+	secondFactor := auth_2fa.NewVerifier(auth_2fa.NewRepository(app.Db.DB))
+
 	// 注册密码认证路由
-	auth_password.RegisterRoutes(app)
+	auth_password.RegisterRoutes(app, secondFactor)
+
+	// 注册会话管理路由
+	auth_session.RegisterRoutes(app, users)
+
+	// 注册公开元数据路由（JWKS）
+	auth_wellknown.RegisterRoutes(app)
+
+	// 注册邮箱验证码认证路由
+	auth_email.RegisterRoutes(app, secondFactor)
+
+	// 注册手机号验证码认证路由
+	auth_sms.RegisterRoutes(app, secondFactor)
+
+	// 注册邮箱和手机号验证路由
+	auth_verify.RegisterRoutes(app)
+
+	// 注册 2FA 认证路由
+	auth_2fa.RegisterRoutes(app)
 
-	// // 注册邮箱认证路由
-	// auth_email.RegisterRoutes(app)
+	// 注册通行密钥（WebAuthn）认证路由
+	auth_passkey.RegisterRoutes(app, secondFactor)
 
-	// // 注册 2FA 认证路由
-	// auth_2fa.RegisterRoutes(app)
+	// 注册 OAuth2 认证路由
+	auth_oauth2.RegisterRoutes(app, users, secondFactor)
 
-	// // 注册 OAuth2 认证路由
-	// auth_oauth2.RegisterRoutes(app)
+	// 注册第三方身份提供方登录路由
+	auth_federated.RegisterRoutes(app, secondFactor)
 }
@@ -0,0 +1,106 @@
+package auth_session
+
+import (
+	"errors"
+	"net/http"
+
+	"king-starter/internal/response"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/goutils/echoutil"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// SessionHandler 会话管理处理器
+type SessionHandler struct {
+	repo *Repository
+}
+
+// NewSessionHandler 创建会话管理处理器实例
+func NewSessionHandler(repo *Repository) *SessionHandler {
+	return &SessionHandler{
+		repo: repo,
+	}
+}
+
+// ListMySessions 查询当前用户的会话
+func (h *SessionHandler) ListMySessions(c echo.Context) error {
+	return h.listSessions(c, echoutil.GetUserID(c))
+}
+
+// RevokeMySession 吊销当前用户的某个会话
+func (h *SessionHandler) RevokeMySession(c echo.Context) error {
+	return h.revokeSession(c, echoutil.GetUserID(c), c.Param("session_id"))
+}
+
+// RevokeOtherSessions 吊销当前用户除当前会话外的所有会话
+func (h *SessionHandler) RevokeOtherSessions(c echo.Context) error {
+	var req RevokeOtherSessionsReq
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+
+	ctx := c.Request().Context()
+	userID := echoutil.GetUserID(c)
+
+	// 通过刷新令牌确认当前会话，只接受当前用户未失效的令牌
+	current, err := h.repo.GetRefreshTokenByToken(ctx, cryptoutil.SHA256Hex(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusBadRequest, "刷新令牌无效")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询会话失败")
+	}
+	if current.UserID != userID || current.RevokedAt != nil {
+		return response.Error(c, http.StatusBadRequest, "刷新令牌无效")
+	}
+
+	if err := h.repo.RevokeOtherSessions(ctx, userID, current.FamilyID); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "吊销会话失败")
+	}
+
+	return response.SuccessWithMsg[any](c, "已退出其他会话", nil)
+}
+
+// ListUserSessions 管理员查询指定用户的会话
+func (h *SessionHandler) ListUserSessions(c echo.Context) error {
+	return h.listSessions(c, c.Param("user_id"))
+}
+
+// RevokeUserSession 管理员吊销指定用户的某个会话
+func (h *SessionHandler) RevokeUserSession(c echo.Context) error {
+	return h.revokeSession(c, c.Param("user_id"), c.Param("session_id"))
+}
+
+// RevokeAllUserSessions 管理员吊销指定用户的所有会话
+func (h *SessionHandler) RevokeAllUserSessions(c echo.Context) error {
+	if err := h.repo.RevokeAllSessions(c.Request().Context(), c.Param("user_id")); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "吊销会话失败")
+	}
+	return response.SuccessWithMsg[any](c, "已吊销全部会话", nil)
+}
+
+func (h *SessionHandler) listSessions(c echo.Context, userID string) error {
+	tokens, err := h.repo.ListActiveSessions(c.Request().Context(), userID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "查询会话失败")
+	}
+
+	sessions := make([]SessionResp, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, NewSessionResp(token))
+	}
+	return response.Success(c, sessions)
+}
+
+func (h *SessionHandler) revokeSession(c echo.Context, userID, sessionID string) error {
+	affected, err := h.repo.RevokeSession(c.Request().Context(), userID, sessionID)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "吊销会话失败")
+	}
+	if affected == 0 {
+		return response.Error(c, http.StatusNotFound, "会话不存在")
+	}
+	return response.SuccessWithMsg[any](c, "会话已吊销", nil)
+}
@@ -0,0 +1,116 @@
+package auth_session
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/goutils/cryptoutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	handler *SessionHandler
+}
+
+// newTestEnv 准备会话：u1 有 f1、f2 两个有效会话（f1 已轮换过一次）以及已吊销、已过期的会话，u2 有 f9
+func newTestEnv(t *testing.T) *testEnv {
+	db := testutil.NewDB(t, &auth_password.CoreRefreshToken{})
+
+	now := time.Now()
+	rotatedAt := now.Add(-time.Hour)
+	revokedAt := now.Add(-time.Minute)
+	require.NoError(t, db.Create(&[]auth_password.CoreRefreshToken{
+		{ID: "t1", UserID: "u1", FamilyID: "f1", Token: cryptoutil.SHA256Hex("r1"), ExpiresAt: now.Add(time.Hour), RotatedAt: &rotatedAt},
+		{ID: "t2", UserID: "u1", FamilyID: "f1", ParentID: "t1", Token: cryptoutil.SHA256Hex("r2"), ExpiresAt: now.Add(time.Hour)},
+		{ID: "t3", UserID: "u1", FamilyID: "f2", Token: cryptoutil.SHA256Hex("r3"), ExpiresAt: now.Add(time.Hour), UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+		{ID: "t4", UserID: "u1", FamilyID: "f3", Token: cryptoutil.SHA256Hex("r4"), ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt},
+		{ID: "t5", UserID: "u1", FamilyID: "f4", Token: cryptoutil.SHA256Hex("r5"), ExpiresAt: now.Add(-time.Minute)},
+		{ID: "t9", UserID: "u2", FamilyID: "f9", Token: cryptoutil.SHA256Hex("r9"), ExpiresAt: now.Add(time.Hour)},
+	}).Error)
+
+	return &testEnv{db: db, handler: NewSessionHandler(NewRepository(db))}
+}
+
+// sessionIDs 返回用户当前有效的会话ID
+func (env *testEnv) sessionIDs(t *testing.T, userID string) []string {
+	tokens, err := NewRepository(env.db).ListActiveSessions(context.Background(), userID)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.FamilyID)
+	}
+	return ids
+}
+
+func TestListMySessions(t *testing.T) {
+	env := newTestEnv(t)
+
+	resp := testutil.Call(t, env.handler.ListMySessions, "u1", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	sessions := resp.Data.([]interface{})
+	require.Len(t, sessions, 2)
+
+	devices := map[string]string{}
+	for _, s := range sessions {
+		session := s.(map[string]interface{})
+		devices[session["id"].(string)] = session["device"].(string)
+	}
+	assert.Equal(t, "Chrome 120.0.0.0 / Windows 10", devices["f2"])
+	assert.Equal(t, "未知设备", devices["f1"])
+}
+
+// TestRevokeMySession 只能吊销自己的有效会话
+func TestRevokeMySession(t *testing.T) {
+	env := newTestEnv(t)
+
+	assert.Equal(t, http.StatusNotFound, testutil.Call(t, env.handler.RevokeMySession, "u1", nil, "session_id", "f9").Code)
+	assert.Equal(t, http.StatusNotFound, testutil.Call(t, env.handler.RevokeMySession, "u1", nil, "session_id", "f3").Code)
+	assert.Equal(t, http.StatusOK, testutil.Call(t, env.handler.RevokeMySession, "u1", nil, "session_id", "f1").Code)
+
+	assert.Equal(t, []string{"f2"}, env.sessionIDs(t, "u1"))
+	assert.Equal(t, []string{"f9"}, env.sessionIDs(t, "u2"))
+}
+
+// TestRevokeOtherSessions 保留刷新令牌所在的会话，吊销其余会话
+func TestRevokeOtherSessions(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 其他用户或已失效的刷新令牌不能用于识别当前会话
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.RevokeOtherSessions, "u1", map[string]string{"refresh_token": "r9"}).Code)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.RevokeOtherSessions, "u1", map[string]string{"refresh_token": "r4"}).Code)
+	assert.Equal(t, http.StatusBadRequest, testutil.Call(t, env.handler.RevokeOtherSessions, "u1", map[string]string{"refresh_token": "unknown"}).Code)
+
+	require.Equal(t, http.StatusOK, testutil.Call(t, env.handler.RevokeOtherSessions, "u1", map[string]string{"refresh_token": "r3"}).Code)
+	assert.Equal(t, []string{"f2"}, env.sessionIDs(t, "u1"))
+	assert.Equal(t, []string{"f9"}, env.sessionIDs(t, "u2"))
+}
+
+// TestAdminRevokeSessions 管理员吊销指定用户的会话
+func TestAdminRevokeSessions(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 会话不属于该用户
+	assert.Equal(t, http.StatusNotFound, testutil.Call(t, env.handler.RevokeUserSession, "admin", nil, "user_id", "u2", "session_id", "f1").Code)
+	assert.Equal(t, http.StatusOK, testutil.Call(t, env.handler.RevokeUserSession, "admin", nil, "user_id", "u1", "session_id", "f2").Code)
+	assert.Equal(t, []string{"f1"}, env.sessionIDs(t, "u1"))
+
+	assert.Equal(t, http.StatusOK, testutil.Call(t, env.handler.RevokeAllUserSessions, "admin", nil, "user_id", "u1").Code)
+	assert.Empty(t, env.sessionIDs(t, "u1"))
+	assert.Equal(t, []string{"f9"}, env.sessionIDs(t, "u2"))
+
+	resp := testutil.Call(t, env.handler.ListUserSessions, "admin", nil, "user_id", "u2")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Len(t, resp.Data, 1)
+}
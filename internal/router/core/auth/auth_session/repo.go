@@ -0,0 +1,68 @@
+package auth_session
+
+import (
+	"context"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+
+	"gorm.io/gorm"
+)
+
+// Repository 会话仓库，会话即 auth_password.CoreRefreshToken 中尚未失效的令牌族
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建会话仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// activeScope 未轮换、未吊销且未过期的刷新令牌，每个令牌族最多一条
+func activeScope(db *gorm.DB) *gorm.DB {
+	return db.Where("rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// ListActiveSessions 查询用户当前有效的会话，按最近使用时间倒序
+func (r *Repository) ListActiveSessions(ctx context.Context, userID string) ([]auth_password.CoreRefreshToken, error) {
+	var tokens []auth_password.CoreRefreshToken
+	err := r.db.WithContext(ctx).
+		Scopes(activeScope).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// GetRefreshTokenByToken 根据令牌哈希获取刷新令牌
+func (r *Repository) GetRefreshTokenByToken(ctx context.Context, tokenHash string) (*auth_password.CoreRefreshToken, error) {
+	var token auth_password.CoreRefreshToken
+	err := r.db.WithContext(ctx).Where("token = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeSession 吊销用户的某个会话，返回被吊销的令牌数量
+func (r *Repository) RevokeSession(ctx context.Context, userID, sessionID string) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&auth_password.CoreRefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, sessionID).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// RevokeOtherSessions 吊销用户除指定会话外的所有会话
+func (r *Repository) RevokeOtherSessions(ctx context.Context, userID, keepSessionID string) error {
+	return r.db.WithContext(ctx).Model(&auth_password.CoreRefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllSessions 吊销用户的所有会话
+func (r *Repository) RevokeAllSessions(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&auth_password.CoreRefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
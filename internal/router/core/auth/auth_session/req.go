@@ -0,0 +1,6 @@
+package auth_session
+
+// RevokeOtherSessionsReq 吊销其他会话请求参数，通过当前会话的刷新令牌识别需要保留的会话
+type RevokeOtherSessionsReq struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
@@ -0,0 +1,57 @@
+package auth_session
+
+import (
+	"fmt"
+	"time"
+
+	"king-starter/internal/router/core/auth/auth_password"
+
+	"github.com/mssola/useragent"
+)
+
+// SessionResp 会话信息
+type SessionResp struct {
+	ID         string    `json:"id"`           // 会话ID（令牌族ID）
+	Device     string    `json:"device"`       // 设备描述，如 "Chrome 120.0 / Windows 10"
+	Browser    string    `json:"browser"`      // 浏览器
+	OS         string    `json:"os"`           // 操作系统
+	Mobile     bool      `json:"mobile"`       // 是否移动设备
+	IP         string    `json:"ip"`           // 最近一次使用的IP
+	UserAgent  string    `json:"user_agent"`   // 原始用户代理
+	CreatedAt  time.Time `json:"created_at"`   // 登录时间
+	LastUsedAt time.Time `json:"last_used_at"` // 最近一次刷新时间
+	ExpiresAt  time.Time `json:"expires_at"`   // 过期时间
+}
+
+// NewSessionResp 将有效的刷新令牌转换为会话信息
+func NewSessionResp(token auth_password.CoreRefreshToken) SessionResp {
+	ua := useragent.New(token.UserAgent)
+	browser, version := ua.Browser()
+	if version != "" {
+		browser = browser + " " + version
+	}
+	os := ua.OS()
+
+	device := "未知设备"
+	switch {
+	case browser != "" && os != "":
+		device = fmt.Sprintf("%s / %s", browser, os)
+	case browser != "":
+		device = browser
+	case os != "":
+		device = os
+	}
+
+	return SessionResp{
+		ID:         token.FamilyID,
+		Device:     device,
+		Browser:    browser,
+		OS:         os,
+		Mobile:     ua.Mobile(),
+		IP:         token.IP,
+		UserAgent:  token.UserAgent,
+		CreatedAt:  token.LoginAt,
+		LastUsedAt: token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+	}
+}
@@ -0,0 +1,42 @@
+package auth_session
+
+import (
+	"context"
+
+	"king-starter/internal/app"
+	"king-starter/internal/common"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+)
+
+// RegisterRoutes 注册会话管理路由
+func RegisterRoutes(app *app.App, users *user.Repository) {
+	repo := NewRepository(app.Db.DB)
+	handler := NewSessionHandler(repo)
+
+	// 禁用用户时吊销其所有会话
+	users.OnStatusChange(func(ctx context.Context, userID string, status int) error {
+		if status != 0 {
+			return nil
+		}
+		return repo.RevokeAllSessions(ctx, userID)
+	})
+
+	e := app.Server.Engine()
+
+	// 当前用户的会话
+	sessionGroup := e.Group("/api/core/auth/sessions", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		sessionGroup.GET("", handler.ListMySessions)
+		sessionGroup.POST("/revoke-others", handler.RevokeOtherSessions) // 退出其他会话
+		sessionGroup.DELETE("/:session_id", handler.RevokeMySession)
+	}
+
+	// 管理员管理任意用户的会话
+	adminGroup := e.Group("/api/core/auth/admin/users/:user_id/sessions", middleware.JWTAuthMiddleware(app.Jwt), middleware.RequireRoles(common.AdminRoleCode))
+	{
+		adminGroup.GET("", handler.ListUserSessions)
+		adminGroup.DELETE("", handler.RevokeAllUserSessions)
+		adminGroup.DELETE("/:session_id", handler.RevokeUserSession)
+	}
+}
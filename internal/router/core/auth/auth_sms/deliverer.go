@@ -0,0 +1,55 @@
+package auth_sms
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/pkg/sms"
+
+	"gorm.io/gorm"
+)
+
+// SMSDeliverer 通过短信发送验证码
+type SMSDeliverer struct {
+	sender   sms.SMSSender
+	template string
+}
+
+// NewSMSDeliverer 创建短信验证码发送器
+func NewSMSDeliverer(sender sms.SMSSender, template string) *SMSDeliverer {
+	return &SMSDeliverer{sender: sender, template: template}
+}
+
+// Deliver 按用途生成短信内容并发送
+func (d *SMSDeliverer) Deliver(ctx context.Context, phone, purpose, code string, ttl time.Duration) error {
+	action := "登录"
+	if purpose == auth_code.PurposeVerify {
+		action = "验证手机号"
+	}
+	minutes := int(ttl.Minutes())
+	return d.sender.Send(ctx, &sms.Message{
+		Phone:    phone,
+		Template: d.template,
+		Params: map[string]string{
+			"code":    code,
+			"minutes": strconv.Itoa(minutes),
+		},
+		Content: fmt.Sprintf("您正在%s，验证码为 %s，%d 分钟内有效。如非本人操作，请忽略本短信。", action, code, minutes),
+	})
+}
+
+// NewCodeManager 创建短信验证码管理器，手机号登录和手机号验证共用发送频率限制
+func NewCodeManager(db *gorm.DB, sender sms.SMSSender, cfg config.SMSCodeConfig) *auth_code.Manager {
+	limits := auth_code.Limits{
+		TTL:          time.Duration(cfg.TTL) * time.Second,
+		SendInterval: time.Duration(cfg.SendInterval) * time.Second,
+		SendWindow:   24 * time.Hour,
+		SendLimit:    cfg.DailyLimit,
+		MaxAttempts:  cfg.MaxAttempts,
+	}
+	return auth_code.NewManager(auth_code.NewRepository(db), auth_code.ChannelSMS, limits, NewSMSDeliverer(sender, cfg.Template))
+}
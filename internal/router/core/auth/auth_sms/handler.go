@@ -0,0 +1,146 @@
+package auth_sms
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/logx"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// phonePattern 手机号格式，与注册时的校验保持一致
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{6,20}$`)
+
+// SMSHandler 手机号验证码认证处理器
+type SMSHandler struct {
+	repo     *Repository
+	codes    *auth_code.Manager
+	userRepo *user.Repository
+	issuer   *auth_password.TokenIssuer
+}
+
+// NewSMSHandler 创建手机号验证码认证处理器实例
+func NewSMSHandler(repo *Repository, codes *auth_code.Manager, userRepo *user.Repository, issuer *auth_password.TokenIssuer) *SMSHandler {
+	return &SMSHandler{
+		repo:     repo,
+		codes:    codes,
+		userRepo: userRepo,
+		issuer:   issuer,
+	}
+}
+
+// SendCode 发送登录验证码
+func (h *SMSHandler) SendCode(c echo.Context) error {
+	var req SendCodeReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.Phone == "" {
+		return response.Error(c, http.StatusBadRequest, "手机号不能为空")
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "手机号格式错误")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByPhone(ctx, phone)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	// 手机号未注册或用户已禁用时同样返回成功，避免泄露账号是否存在
+	if u == nil || u.Status == 0 {
+		return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+	}
+
+	if err := h.codes.Send(ctx, phone, auth_code.PurposeLogin, c.RealIP()); err != nil {
+		status, msg := auth_code.Describe(err)
+		if status == http.StatusInternalServerError {
+			logx.Error("send sms code failed", "phone", phone, "error", err)
+			msg = "验证码发送失败"
+		}
+		return response.Error(c, status, msg)
+	}
+
+	return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+}
+
+// Login 校验短信验证码并登录，成功后签发与密码登录相同的令牌
+func (h *SMSHandler) Login(c echo.Context) error {
+	var req PhoneLoginReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.Phone == "" || req.Code == "" {
+		return response.Error(c, http.StatusBadRequest, "手机号和验证码不能为空")
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "手机号格式错误")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByPhone(ctx, phone)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+		}
+		h.createLoginLog(c, "", phone, LoginTypeFailed, "用户不存在")
+		return response.Error(c, http.StatusUnauthorized, "验证码错误")
+	}
+
+	if err := h.codes.Verify(ctx, phone, auth_code.PurposeLogin, req.Code); err != nil {
+		status, msg := auth_code.Describe(err)
+		if status == http.StatusInternalServerError {
+			return response.Error(c, status, "校验验证码失败")
+		}
+		h.createLoginLog(c, u.ID, u.Username, LoginTypeFailed, msg)
+		return response.Error(c, status, msg)
+	}
+
+	// 能收到验证码即证明拥有该手机号
+	if u.PhoneVerifiedAt == nil {
+		if _, err := h.userRepo.MarkPhoneVerified(ctx, u.ID, u.Phone); err != nil {
+			logx.Error("mark phone verified failed", "user_id", u.ID, "error", err)
+		}
+	}
+
+	return h.issuer.Complete(c, u, func(loginType, message string) {
+		h.createLoginLog(c, u.ID, u.Username, loginType, message)
+	})
+}
+
+// normalizePhone 去掉空格和连字符后校验手机号格式
+func normalizePhone(s string) (string, bool) {
+	s = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s))
+	if !phonePattern.MatchString(s) {
+		return "", false
+	}
+	return s, true
+}
+
+// createLoginLog 记录手机号验证码登录日志
+func (h *SMSHandler) createLoginLog(c echo.Context, userID, username, loginType, message string) {
+	log := &auth_password.CoreLoginLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Username:  username,
+		AuthType:  auth_password.AuthTypePhone,
+		LoginType: loginType,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Message:   message,
+	}
+	h.repo.CreateLoginLog(c.Request().Context(), log)
+}
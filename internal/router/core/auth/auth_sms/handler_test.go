@@ -0,0 +1,146 @@
+package auth_sms
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	sender  *testutil.SMSSender
+	handler *SMSHandler
+}
+
+func newTestEnv(t *testing.T, cfg config.SMSCodeConfig) *testEnv {
+	db := testutil.NewDB(t,
+		&user.CoreUser{}, &role.CoreRole{}, &role.CoreUserRole{},
+		&auth_password.CoreLoginLog{}, &auth_password.CoreRefreshToken{}, &auth_password.CoreLoginChallenge{},
+		&auth_code.CoreVerifyCode{},
+	)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Phone: "+8613800000000", Status: 1}).Error)
+
+	sender := &testutil.SMSSender{}
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(db), role.NewRoleRepo(db), testutil.NewJWT(), false, nil, 5*time.Minute)
+	return &testEnv{
+		db:      db,
+		sender:  sender,
+		handler: NewSMSHandler(NewRepository(db), NewCodeManager(db, sender, cfg), user.NewRepository(db), issuer),
+	}
+}
+
+func (env *testEnv) call(t *testing.T, h echo.HandlerFunc, body interface{}) (int, interface{}) {
+	resp := testutil.Call(t, h, "", body)
+	return resp.Code, resp.Data
+}
+
+func (env *testEnv) send(t *testing.T, phone string) int {
+	code, _ := env.call(t, env.handler.SendCode, map[string]string{"phone": phone})
+	return code
+}
+
+func (env *testEnv) login(t *testing.T, phone, code string) (int, interface{}) {
+	return env.call(t, env.handler.Login, map[string]string{"phone": phone, "code": code})
+}
+
+func TestPhoneCodeLogin(t *testing.T) {
+	cfg := config.DefaultAuthConfig().SMSCode
+	cfg.Template = "SMS_LOGIN"
+	env := newTestEnv(t, cfg)
+
+	require.Equal(t, http.StatusOK, env.send(t, "+86 138-0000-0000"))
+	msg := env.sender.Sent()[0]
+	assert.Equal(t, "+8613800000000", msg.Phone)
+	assert.Equal(t, "SMS_LOGIN", msg.Template)
+	assert.Equal(t, "5", msg.Params["minutes"])
+	code := env.sender.LastCode(t)
+	require.Len(t, code, 6)
+	assert.Contains(t, msg.Content, code)
+
+	var stored auth_code.CoreVerifyCode
+	require.NoError(t, env.db.First(&stored).Error)
+	assert.Equal(t, auth_code.ChannelSMS, stored.Channel)
+	assert.NotContains(t, stored.CodeHash, code)
+
+	status, data := env.login(t, "+8613800000000", code)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, data.(map[string]interface{})["access_token"])
+
+	var log auth_password.CoreLoginLog
+	require.NoError(t, env.db.Where("login_type = ?", LoginTypeSuccess).First(&log).Error)
+	assert.Equal(t, auth_password.AuthTypePhone, log.AuthType)
+
+	// 验证码登录同时完成手机号验证
+	var u user.CoreUser
+	require.NoError(t, env.db.First(&u, "id = ?", "u1").Error)
+	assert.NotNil(t, u.PhoneVerifiedAt)
+
+	// 验证码只能使用一次
+	status, _ = env.login(t, "+8613800000000", code)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestPhoneCodeUnknownNumber(t *testing.T) {
+	env := newTestEnv(t, config.DefaultAuthConfig().SMSCode)
+
+	assert.Equal(t, http.StatusOK, env.send(t, "13900000000"))
+	assert.Empty(t, env.sender.Sent())
+
+	assert.Equal(t, http.StatusBadRequest, env.send(t, "not-a-phone"))
+
+	status, _ := env.login(t, "13900000000", "123456")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestPhoneCodeRateLimit(t *testing.T) {
+	cfg := config.DefaultAuthConfig().SMSCode
+	env := newTestEnv(t, cfg)
+
+	require.Equal(t, http.StatusOK, env.send(t, "+8613800000000"))
+	assert.Equal(t, http.StatusTooManyRequests, env.send(t, "+8613800000000"))
+
+	// 超过每日发送次数
+	cfg.SendInterval = 0
+	cfg.DailyLimit = 2
+	env.handler.codes = NewCodeManager(env.db, env.sender, cfg)
+	require.Equal(t, http.StatusOK, env.send(t, "+8613800000000"))
+	assert.Equal(t, http.StatusTooManyRequests, env.send(t, "+8613800000000"))
+	assert.Len(t, env.sender.Sent(), 2)
+}
+
+func TestPhoneCodeAttemptLimit(t *testing.T) {
+	cfg := config.DefaultAuthConfig().SMSCode
+	cfg.MaxAttempts = 3
+	env := newTestEnv(t, cfg)
+
+	require.Equal(t, http.StatusOK, env.send(t, "+8613800000000"))
+	code := env.sender.LastCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		status, _ := env.login(t, "+8613800000000", wrong)
+		require.Equal(t, http.StatusUnauthorized, status)
+	}
+	// 次数用尽后正确的验证码也不再有效
+	status, _ := env.login(t, "+8613800000000", code)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
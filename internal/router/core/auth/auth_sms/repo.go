@@ -0,0 +1,24 @@
+package auth_sms
+
+import (
+	"context"
+
+	"king-starter/internal/router/core/auth/auth_password"
+
+	"gorm.io/gorm"
+)
+
+// Repository 短信认证仓库
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建短信认证仓库实例
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateLoginLog 创建登录日志
+func (r *Repository) CreateLoginLog(ctx context.Context, log *auth_password.CoreLoginLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
@@ -0,0 +1,12 @@
+package auth_sms
+
+// SendCodeReq 发送短信验证码请求参数
+type SendCodeReq struct {
+	Phone string `json:"phone" validate:"required"`
+}
+
+// PhoneLoginReq 手机号验证码登录请求参数
+type PhoneLoginReq struct {
+	Phone string `json:"phone" validate:"required"`
+	Code  string `json:"code" validate:"required,len=6"`
+}
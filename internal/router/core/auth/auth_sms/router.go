@@ -0,0 +1,31 @@
+package auth_sms
+
+import (
+	"time"
+
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/auth/auth_verify"
+	"king-starter/internal/router/core/role"
+	"king-starter/internal/router/core/user"
+)
+
+// RegisterRoutes 注册手机号验证码认证路由
+func RegisterRoutes(app *app.App, secondFactor auth_password.SecondFactor) {
+	repo := NewRepository(app.Db.DB)
+	codes := NewCodeManager(app.Db.DB, app.SMS, app.Config.Auth.SMSCode)
+	issuer := auth_password.NewTokenIssuer(auth_password.NewRepository(app.Db.DB), role.NewRoleRepo(app.Db.DB), app.Jwt, app.Config.Auth.RequireEmailVerified, secondFactor, time.Duration(app.Config.Auth.TwoFA.ChallengeTTL)*time.Second)
+	handler := NewSMSHandler(repo, codes, user.NewRepository(app.Db.DB), issuer)
+
+	// 手机号验证与登录共用验证码管理器和发送频率限制
+	auth_verify.RegisterPhoneCodes(codes)
+
+	e := app.Server.Engine()
+
+	// 手机号认证路由组
+	authGroup := e.Group("/api/core/auth")
+	{
+		authGroup.POST("/phone/send-code", handler.SendCode) // 发送登录验证码
+		authGroup.POST("/login/phone", handler.Login)        // 手机号验证码登录
+	}
+}
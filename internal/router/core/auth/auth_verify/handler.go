@@ -0,0 +1,188 @@
+package auth_verify
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/logx"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// VerifyHandler 邮箱和手机号验证处理器
+type VerifyHandler struct {
+	userRepo   *user.Repository
+	emailCodes *auth_code.Manager
+}
+
+// NewVerifyHandler 创建验证处理器实例
+func NewVerifyHandler(userRepo *user.Repository, emailCodes *auth_code.Manager) *VerifyHandler {
+	return &VerifyHandler{
+		userRepo:   userRepo,
+		emailCodes: emailCodes,
+	}
+}
+
+// SendEmailCode 发送邮箱验证码
+// 无需登录，开启 require_email_verified 后未验证的用户需要先验证邮箱才能登录
+func (h *VerifyHandler) SendEmailCode(c echo.Context) error {
+	var req SendEmailCodeReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "邮箱格式错误")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	// 邮箱未注册或已验证时同样返回成功，避免泄露账号是否存在
+	if u == nil || u.EmailVerifiedAt != nil {
+		return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+	}
+
+	if err := h.emailCodes.Send(ctx, email, auth_code.PurposeVerify, c.RealIP()); err != nil {
+		return sendCodeError(c, email, err)
+	}
+	return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+}
+
+// ConfirmEmail 校验邮箱验证码，成功后标记邮箱已验证
+func (h *VerifyHandler) ConfirmEmail(c echo.Context) error {
+	var req ConfirmEmailReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.Code == "" {
+		return response.Error(c, http.StatusBadRequest, "验证码不能为空")
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		return response.Error(c, http.StatusBadRequest, "邮箱格式错误")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusUnauthorized, "验证码错误")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u.EmailVerifiedAt != nil {
+		return response.SuccessWithMsg[any](c, "邮箱已验证", nil)
+	}
+
+	if err := h.emailCodes.Verify(ctx, email, auth_code.PurposeVerify, req.Code); err != nil {
+		status, msg := auth_code.Describe(err)
+		return response.Error(c, status, msg)
+	}
+	if _, err := h.userRepo.MarkEmailVerified(ctx, u.ID, u.Email); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新验证状态失败")
+	}
+	return response.SuccessWithMsg[any](c, "邮箱验证成功", nil)
+}
+
+// SendPhoneCode 向当前用户的手机号发送验证码
+func (h *VerifyHandler) SendPhoneCode(c echo.Context) error {
+	if phoneCodes == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "短信服务未启用")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.currentUser(c)
+	if err != nil {
+		return userError(c, err)
+	}
+	if u.Phone == "" {
+		return response.Error(c, http.StatusBadRequest, "未设置手机号")
+	}
+	if u.PhoneVerifiedAt != nil {
+		return response.Error(c, http.StatusBadRequest, "手机号已验证")
+	}
+
+	if err := phoneCodes.Send(ctx, u.Phone, auth_code.PurposeVerify, c.RealIP()); err != nil {
+		return sendCodeError(c, u.Phone, err)
+	}
+	return response.SuccessWithMsg[any](c, "验证码已发送", nil)
+}
+
+// ConfirmPhone 校验手机验证码，成功后标记当前用户的手机号已验证
+func (h *VerifyHandler) ConfirmPhone(c echo.Context) error {
+	if phoneCodes == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "短信服务未启用")
+	}
+
+	var req ConfirmPhoneReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.Code == "" {
+		return response.Error(c, http.StatusBadRequest, "验证码不能为空")
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.currentUser(c)
+	if err != nil {
+		return userError(c, err)
+	}
+	if u.Phone == "" {
+		return response.Error(c, http.StatusBadRequest, "未设置手机号")
+	}
+
+	if err := phoneCodes.Verify(ctx, u.Phone, auth_code.PurposeVerify, req.Code); err != nil {
+		status, msg := auth_code.Describe(err)
+		return response.Error(c, status, msg)
+	}
+	if _, err := h.userRepo.MarkPhoneVerified(ctx, u.ID, u.Phone); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新验证状态失败")
+	}
+	return response.SuccessWithMsg[any](c, "手机号验证成功", nil)
+}
+
+func (h *VerifyHandler) currentUser(c echo.Context) (*user.CoreUser, error) {
+	return h.userRepo.GetByID(c.Request().Context(), echoutil.GetUserID(c))
+}
+
+// userError 查询当前用户失败时的响应
+func userError(c echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusNotFound, "用户不存在")
+	}
+	return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+}
+
+// sendCodeError 验证码发送失败时的响应
+func sendCodeError(c echo.Context, target string, err error) error {
+	status, msg := auth_code.Describe(err)
+	if status == http.StatusInternalServerError {
+		logx.Error("send verify code failed", "target", target, "error", err)
+		msg = "验证码发送失败"
+	}
+	return response.Error(c, status, msg)
+}
+
+// normalizeEmail 校验邮箱格式并统一转为小写
+func normalizeEmail(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", false
+	}
+	return s, true
+}
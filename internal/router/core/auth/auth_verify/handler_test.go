@@ -0,0 +1,111 @@
+package auth_verify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/router/core/auth/auth_code"
+	"king-starter/internal/router/core/auth/auth_email"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// recordingDeliverer 记录发送的短信验证码
+type recordingDeliverer struct {
+	targets []string
+	code    string
+}
+
+func (d *recordingDeliverer) Deliver(ctx context.Context, target, purpose, code string, ttl time.Duration) error {
+	d.targets = append(d.targets, target)
+	d.code = code
+	return nil
+}
+
+type testEnv struct {
+	db      *gorm.DB
+	mailer  *testutil.Mailer
+	handler *VerifyHandler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db := testutil.NewDB(t, &user.CoreUser{}, &auth_code.CoreVerifyCode{})
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Email: "alice@example.com", Phone: "13800000000", Status: 1}).Error)
+
+	mailer := &testutil.Mailer{}
+	return &testEnv{
+		db:      db,
+		mailer:  mailer,
+		handler: NewVerifyHandler(user.NewRepository(db), auth_email.NewCodeManager(db, mailer, config.DefaultAuthConfig().EmailCode)),
+	}
+}
+
+func (env *testEnv) call(t *testing.T, h echo.HandlerFunc, userID string, body interface{}) int {
+	return testutil.Call(t, h, userID, body).Code
+}
+
+func (env *testEnv) user(t *testing.T) *user.CoreUser {
+	var u user.CoreUser
+	require.NoError(t, env.db.First(&u, "id = ?", "u1").Error)
+	return &u
+}
+
+func TestVerifyEmail(t *testing.T) {
+	env := newTestEnv(t)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.SendEmailCode, "", map[string]string{"email": "Alice@Example.com"}))
+	code := env.mailer.LastCode(t)
+
+	assert.Equal(t, http.StatusUnauthorized, env.call(t, env.handler.ConfirmEmail, "", map[string]string{"email": "alice@example.com", "code": "abcdef"}))
+	assert.Nil(t, env.user(t).EmailVerifiedAt)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ConfirmEmail, "", map[string]string{"email": "alice@example.com", "code": code}))
+	assert.NotNil(t, env.user(t).EmailVerifiedAt)
+
+	// 已验证的邮箱不再发送验证码
+	require.NoError(t, env.db.Model(&auth_code.CoreVerifyCode{}).Where("1 = 1").Update("created_at", time.Now().Add(-time.Hour)).Error)
+	assert.Equal(t, http.StatusOK, env.call(t, env.handler.SendEmailCode, "", map[string]string{"email": "alice@example.com"}))
+	assert.Len(t, env.mailer.Sent(), 1)
+}
+
+func TestVerifyEmailUnknownAddress(t *testing.T) {
+	env := newTestEnv(t)
+
+	assert.Equal(t, http.StatusOK, env.call(t, env.handler.SendEmailCode, "", map[string]string{"email": "bob@example.com"}))
+	assert.Empty(t, env.mailer.Sent())
+	assert.Equal(t, http.StatusUnauthorized, env.call(t, env.handler.ConfirmEmail, "", map[string]string{"email": "bob@example.com", "code": "123456"}))
+}
+
+func TestVerifyPhone(t *testing.T) {
+	env := newTestEnv(t)
+
+	RegisterPhoneCodes(nil)
+	assert.Equal(t, http.StatusServiceUnavailable, env.call(t, env.handler.SendPhoneCode, "u1", nil))
+
+	deliverer := &recordingDeliverer{}
+	RegisterPhoneCodes(auth_code.NewManager(auth_code.NewRepository(env.db), auth_code.ChannelSMS, auth_code.Limits{
+		TTL:         5 * time.Minute,
+		MaxAttempts: 5,
+	}, deliverer))
+	t.Cleanup(func() { RegisterPhoneCodes(nil) })
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.SendPhoneCode, "u1", nil))
+	assert.Equal(t, []string{"13800000000"}, deliverer.targets)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ConfirmPhone, "u1", map[string]string{"code": deliverer.code}))
+	assert.NotNil(t, env.user(t).PhoneVerifiedAt)
+
+	assert.Equal(t, http.StatusBadRequest, env.call(t, env.handler.SendPhoneCode, "u1", nil))
+}
@@ -0,0 +1,13 @@
+package auth_verify
+
+import (
+	"king-starter/internal/router/core/auth/auth_code"
+)
+
+var phoneCodes *auth_code.Manager
+
+// RegisterPhoneCodes 挂载手机验证码管理器
+// 由短信模块在路由注册阶段调用，未挂载时手机号验证不可用
+func RegisterPhoneCodes(codes *auth_code.Manager) {
+	phoneCodes = codes
+}
@@ -0,0 +1,17 @@
+package auth_verify
+
+// SendEmailCodeReq 发送邮箱验证码请求参数
+type SendEmailCodeReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ConfirmEmailReq 确认邮箱验证请求参数
+type ConfirmEmailReq struct {
+	Email string `json:"email" validate:"required,email"`
+	Code  string `json:"code" validate:"required,len=6"`
+}
+
+// ConfirmPhoneReq 确认手机号验证请求参数
+type ConfirmPhoneReq struct {
+	Code string `json:"code" validate:"required,len=6"`
+}
@@ -0,0 +1,30 @@
+package auth_verify
+
+import (
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_email"
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/http/middleware"
+)
+
+// RegisterRoutes 注册邮箱和手机号验证路由
+func RegisterRoutes(app *app.App) {
+	emailCodes := auth_email.NewCodeManager(app.Db.DB, app.Mailer, app.Config.Auth.EmailCode)
+	handler := NewVerifyHandler(user.NewRepository(app.Db.DB), emailCodes)
+
+	e := app.Server.Engine()
+
+	// 邮箱验证无需登录，邮箱未验证时可能无法登录
+	emailGroup := e.Group("/api/core/auth/verify/email")
+	{
+		emailGroup.POST("/send", handler.SendEmailCode)   // 发送邮箱验证码
+		emailGroup.POST("/confirm", handler.ConfirmEmail) // 确认邮箱验证
+	}
+
+	// 手机号验证针对当前用户
+	phoneGroup := e.Group("/api/core/auth/verify/phone", middleware.JWTAuthMiddleware(app.Jwt))
+	{
+		phoneGroup.POST("/send", handler.SendPhoneCode)   // 发送手机验证码
+		phoneGroup.POST("/confirm", handler.ConfirmPhone) // 确认手机号验证
+	}
+}
@@ -0,0 +1,28 @@
+package auth_wellknown
+
+import (
+	"net/http"
+
+	"king-starter/pkg/jwt"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WellKnownHandler 公开元数据处理器（/.well-known/*）
+// 返回标准格式的 JSON，不使用统一响应包装，便于第三方库直接消费
+type WellKnownHandler struct {
+	jwt *jwt.JWT
+}
+
+// NewWellKnownHandler 创建公开元数据处理器实例
+func NewWellKnownHandler(jwt *jwt.JWT) *WellKnownHandler {
+	return &WellKnownHandler{
+		jwt: jwt,
+	}
+}
+
+// JWKS 公开 JWT 验证公钥，下游服务可据此离线验证令牌
+func (h *WellKnownHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.jwt.JWKS())
+}
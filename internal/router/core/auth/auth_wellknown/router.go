@@ -0,0 +1,16 @@
+package auth_wellknown
+
+import (
+	"king-starter/internal/app"
+)
+
+// RegisterRoutes 注册公开元数据路由
+func RegisterRoutes(app *app.App) {
+	handler := NewWellKnownHandler(app.Jwt)
+
+	e := app.Server.Engine()
+	wellKnownGroup := e.Group("/.well-known")
+	{
+		wellKnownGroup.GET("/jwks.json", handler.JWKS)
+	}
+}
@@ -1,11 +1,18 @@
 package identity
 
 import (
+	"errors"
 	"fmt"
 	"king-starter/internal/app"
 	"king-starter/internal/response"
+	"king-starter/internal/router/core/auth/auth_password"
 	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/cryptoutil"
+	"king-starter/pkg/logx"
+	"king-starter/pkg/mail"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -17,6 +24,9 @@ type RegisterHandler struct {
 	app          *app.App
 	registerRepo *RegisterRepo
 	userRepo     *user.Repository
+	resetRepo    *PasswordResetRepo
+	authRepo     *auth_password.Repository
+	policy       *auth_password.PasswordPolicy
 }
 
 func NewRegisterHandler(app *app.App) *RegisterHandler {
@@ -24,6 +34,9 @@ func NewRegisterHandler(app *app.App) *RegisterHandler {
 		app:          app,
 		registerRepo: NewRegisterRepo(app.Db.DB),
 		userRepo:     user.NewRepository(app.Db.DB),
+		resetRepo:    NewPasswordResetRepo(app.Db.DB),
+		authRepo:     auth_password.NewRepository(app.Db.DB),
+		policy:       auth_password.NewPasswordPolicy(app.Config.Auth.PasswordPolicy),
 	}
 }
 
@@ -59,7 +72,7 @@ func (h *RegisterHandler) Register(c echo.Context) error {
 		Username:  req.Username,
 		Password:  string(passwordHash),
 		Nickname:  req.Nickname,
-		Email:     req.Email,
+		Email:     user.NormalizeEmail(req.Email),
 		Phone:     req.Phone,
 		Status:    1,        // 默认启用状态
 		CreatedBy: "system", // 注册时默认创建者
@@ -88,47 +101,145 @@ func (h *RegisterHandler) Register(c echo.Context) error {
 	return response.Success(c, resp)
 }
 
-// ResetPassword 重置密码
+// ForgotPassword 找回密码，向用户邮箱发送一次性的重置链接
+// 无论邮箱是否注册都返回相同结果，避免泄露账号是否存在
+func (h *RegisterHandler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	email := user.NormalizeEmail(req.Email)
+	if email == "" {
+		return response.Error(c, http.StatusBadRequest, "邮箱不能为空")
+	}
+
+	ctx := c.Request().Context()
+	cfg := h.app.Config.Auth.PasswordReset
+	const msg = "如果该邮箱已注册，重置密码链接已发送，请查收邮件"
+
+	u, err := h.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.SuccessWithMsg[any](c, msg, nil)
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if u.Status == 0 {
+		return response.SuccessWithMsg[any](c, msg, nil)
+	}
+
+	// 发送间隔内不重复发送
+	latest, err := h.resetRepo.GetLatestByUserID(ctx, u.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.Error(c, http.StatusInternalServerError, "查询重置记录失败")
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < time.Duration(cfg.SendInterval)*time.Second {
+		return response.SuccessWithMsg[any](c, msg, nil)
+	}
+
+	plain := cryptoutil.RandomToken(32)
+	ttl := time.Duration(cfg.TokenTTL) * time.Second
+	reset := &CorePasswordReset{
+		ID:        uuid.NewString(),
+		UserID:    u.ID,
+		TokenHash: cryptoutil.SHA256Hex(plain),
+		ExpiresAt: time.Now().Add(ttl),
+		IP:        c.RealIP(),
+	}
+	if err := h.resetRepo.Replace(ctx, reset); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "生成重置链接失败")
+	}
+
+	link, err := resetLink(cfg.LinkURL, plain)
+	if err != nil {
+		logx.Error("build password reset link failed", "link_url", cfg.LinkURL, "error", err)
+		return response.Error(c, http.StatusInternalServerError, "生成重置链接失败")
+	}
+	err = h.app.Mailer.Send(ctx, &mail.Message{
+		To:      []string{u.Email},
+		Subject: "重置密码",
+		Text: fmt.Sprintf("您好 %s：\n\n请在 %d 分钟内打开以下链接重置密码，链接只能使用一次：\n\n%s\n\n如非本人操作，请忽略本邮件，您的密码不会被修改。",
+			u.Username, int(ttl.Minutes()), link),
+	})
+	if err != nil {
+		// 与未注册邮箱返回相同的提示，避免通过发送失败判断账号是否存在
+		logx.Error("send password reset mail failed", "user_id", u.ID, "error", err)
+		h.resetRepo.DeleteByID(ctx, reset.ID)
+	}
+
+	return response.SuccessWithMsg[any](c, msg, nil)
+}
+
+// ResetPassword 使用重置链接中的令牌设置新密码，成功后该用户的所有会话失效
 func (h *RegisterHandler) ResetPassword(c echo.Context) error {
 	var req ResetPasswordReq
 	if err := c.Bind(&req); err != nil {
 		return response.Error(c, http.StatusBadRequest, "请求参数错误")
 	}
-
-	if err := c.Validate(&req); err != nil {
+	if req.Token == "" {
+		return response.Error(c, http.StatusBadRequest, "重置令牌不能为空")
+	}
+	if err := h.policy.Check(req.NewPassword); err != nil {
 		return response.Error(c, http.StatusBadRequest, err.Error())
 	}
 
-	// 获取当前用户信息
-	currentUser, err := h.userRepo.GetByID(c.Request().Context(), req.UserID)
+	ctx := c.Request().Context()
+
+	reset, err := h.resetRepo.GetByTokenHash(ctx, cryptoutil.SHA256Hex(req.Token))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.Error(c, http.StatusNotFound, "用户不存在")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusBadRequest, "重置链接无效或已过期")
 		}
-		return response.Error(c, http.StatusInternalServerError, fmt.Sprintf("查询用户失败: %v", err))
+		return response.Error(c, http.StatusInternalServerError, "查询重置记录失败")
 	}
-
-	// 验证旧密码
-	if err := bcrypt.CompareHashAndPassword([]byte(currentUser.Password), []byte(req.OldPassword)); err != nil {
-		return response.Error(c, http.StatusBadRequest, "原密码错误")
+	if reset.UsedAt != nil || reset.ExpiresAt.Before(time.Now()) {
+		return response.Error(c, http.StatusBadRequest, "重置链接无效或已过期")
 	}
 
-	// 新密码不能与旧密码相同
-	if req.OldPassword == req.NewPassword {
-		return response.Error(c, http.StatusBadRequest, "新密码不能与原密码相同")
+	currentUser, err := h.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusBadRequest, "重置链接无效或已过期")
+		}
+		return response.Error(c, http.StatusInternalServerError, "查询用户失败")
+	}
+	if currentUser.Status == 0 {
+		return response.Error(c, http.StatusForbidden, "用户已被禁用")
 	}
 
-	// 加密新密码
 	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return response.Error(c, http.StatusInternalServerError, fmt.Sprintf("新密码加密失败: %v", err))
+		return response.Error(c, http.StatusInternalServerError, "密码加密失败")
+	}
+
+	// 令牌只能使用一次，标记已使用和更新密码在同一事务中完成
+	used, err := h.resetRepo.ResetPassword(ctx, reset.ID, currentUser.ID, string(newPasswordHash))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "更新密码失败")
+	}
+	if !used {
+		return response.Error(c, http.StatusBadRequest, "重置链接无效或已过期")
 	}
 
-	// 更新密码
-	currentUser.Password = string(newPasswordHash)
-	if err := h.userRepo.Update(c.Request().Context(), currentUser); err != nil {
-		return response.Error(c, http.StatusInternalServerError, fmt.Sprintf("更新密码失败: %v", err))
+	// 密码可能已泄露，吊销该用户的所有访问令牌和刷新令牌
+	if err := h.app.Jwt.RevokeUser(ctx, currentUser.ID); err != nil {
+		logx.Error("revoke access tokens failed", "user_id", currentUser.ID, "error", err)
 	}
+	if err := h.authRepo.RevokeUserRefreshTokens(ctx, currentUser.ID); err != nil {
+		logx.Error("revoke refresh tokens failed", "user_id", currentUser.ID, "error", err)
+	}
+
+	return response.SuccessWithMsg[any](c, "密码重置成功，请重新登录", nil)
+}
 
-	return response.SuccessWithMsg[any](c, "密码重置成功", nil)
+// resetLink 将令牌以 token 查询参数附加到前端重置密码页面地址
+func resetLink(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
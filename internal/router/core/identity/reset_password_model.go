@@ -0,0 +1,22 @@
+package identity
+
+import (
+	"time"
+)
+
+// CorePasswordReset 找回密码令牌模型
+// 只保存令牌哈希，令牌只能使用一次，同一用户只有最近签发的令牌有效
+type CorePasswordReset struct {
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	UserID    string     `gorm:"type:varchar(36);index" json:"user_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex" json:"-"` // 令牌的 SHA-256 哈希
+	ExpiresAt time.Time  `json:"expires_at"`                            // 过期时间
+	UsedAt    *time.Time `json:"used_at,omitempty"`                     // 使用时间
+	IP        string     `gorm:"type:varchar(50)" json:"ip"`            // 申请找回密码的IP
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CorePasswordReset) TableName() string {
+	return "core_password_resets"
+}
@@ -0,0 +1,76 @@
+package identity
+
+import (
+	"context"
+	"time"
+
+	"king-starter/internal/router/core/user"
+	"king-starter/pkg/goutils/gormutil"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetRepo 找回密码令牌的数据访问层
+type PasswordResetRepo struct {
+	*gormutil.BaseRepo[CorePasswordReset]
+}
+
+// NewPasswordResetRepo 创建找回密码令牌数据访问层实例
+func NewPasswordResetRepo(db *gorm.DB) *PasswordResetRepo {
+	return &PasswordResetRepo{BaseRepo: gormutil.NewBaseRepo[CorePasswordReset](db)}
+}
+
+// GetLatestByUserID 查询用户最近签发的重置令牌
+func (r *PasswordResetRepo) GetLatestByUserID(ctx context.Context, userID string) (*CorePasswordReset, error) {
+	var reset CorePasswordReset
+	err := r.GetDB(ctx).Where("user_id = ?", userID).Order("created_at DESC").First(&reset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// Replace 签发新令牌并删除用户之前的令牌，旧链接随之失效
+func (r *PasswordResetRepo) Replace(ctx context.Context, reset *CorePasswordReset) error {
+	return r.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", reset.UserID).Delete(&CorePasswordReset{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(reset).Error
+	})
+}
+
+// GetByTokenHash 根据令牌哈希查询
+func (r *PasswordResetRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*CorePasswordReset, error) {
+	var reset CorePasswordReset
+	err := r.GetDB(ctx).Where("token_hash = ?", tokenHash).First(&reset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// ResetPassword 在同一事务中标记令牌已使用并更新用户密码，返回 false 表示令牌已被并发请求使用
+// 任一步失败都会回滚，令牌不会在密码未更新时被消耗
+func (r *PasswordResetRepo) ResetPassword(ctx context.Context, id, userID, passwordHash string) (bool, error) {
+	used := false
+	err := r.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&CorePasswordReset{}).
+			Where("id = ? AND used_at IS NULL", id).
+			Update("used_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Model(&user.CoreUser{}).Where("id = ?", userID).Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+// DeleteByID 删除令牌（物理删除）
+func (r *PasswordResetRepo) DeleteByID(ctx context.Context, id string) error {
+	return r.GetDB(ctx).Where("id = ?", id).Delete(&CorePasswordReset{}).Error
+}
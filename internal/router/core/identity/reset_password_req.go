@@ -1,8 +1,12 @@
 package identity
 
-// ResetPasswordReq 重置密码请求
+// ForgotPasswordReq 找回密码请求，向邮箱发送重置链接
+type ForgotPasswordReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordReq 重置密码请求，令牌来自重置链接
 type ResetPasswordReq struct {
-	UserID      string `json:"user_id" validate:"required"`
-	OldPassword string `json:"old_password" validate:"required,min=6"`
-	NewPassword string `json:"new_password" validate:"required,min=6,max=20"`
-}
\ No newline at end of file
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
@@ -0,0 +1,173 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"king-starter/config"
+	"king-starter/internal/app"
+	"king-starter/internal/router/core/auth/auth_password"
+	"king-starter/internal/router/core/user"
+	"king-starter/internal/testutil"
+	"king-starter/pkg/database"
+	"king-starter/pkg/mail"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+type resetEnv struct {
+	db      *gorm.DB
+	mailer  *testutil.Mailer
+	handler *RegisterHandler
+}
+
+func newResetEnv(t *testing.T) *resetEnv {
+	db := testutil.NewDB(t, &user.CoreUser{}, &auth_password.CoreRefreshToken{}, &CorePasswordReset{})
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("oldpass123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&user.CoreUser{ID: "u1", Username: "alice", Password: string(hash), Email: "alice@example.com", Status: 1}).Error)
+	require.NoError(t, db.Create(&auth_password.CoreRefreshToken{ID: "rt1", UserID: "u1", Token: "h1", FamilyID: "f1", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	cfg := config.DefaultConfig()
+	mailer := &testutil.Mailer{}
+	a := &app.App{
+		Config: &cfg,
+		Db:     &database.DB{DB: db},
+		Jwt:    testutil.NewJWT(),
+		Mailer: mailer,
+	}
+	return &resetEnv{db: db, mailer: mailer, handler: NewRegisterHandler(a)}
+}
+
+func (env *resetEnv) call(t *testing.T, h echo.HandlerFunc, body interface{}) int {
+	return testutil.Call(t, h, "", body).Code
+}
+
+// lastToken 从最近一封邮件的重置链接中取出令牌
+func (env *resetEnv) lastToken(t *testing.T) string {
+	link, err := url.Parse(linkPattern.FindString(env.mailer.Last(t).Text))
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := newResetEnv(t)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "Alice@example.com"}))
+	token := env.lastToken(t)
+	require.NotEmpty(t, token)
+
+	var reset CorePasswordReset
+	require.NoError(t, env.db.First(&reset).Error)
+	assert.NotEqual(t, token, reset.TokenHash)
+
+	// 新密码需满足密码策略
+	assert.Equal(t, http.StatusBadRequest, env.call(t, env.handler.ResetPassword, map[string]string{"token": token, "new_password": "short"}))
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ResetPassword, map[string]string{"token": token, "new_password": "newpass456"}))
+
+	var u user.CoreUser
+	require.NoError(t, env.db.First(&u, "id = ?", "u1").Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("newpass456")))
+
+	// 已有的刷新令牌全部吊销
+	var rt auth_password.CoreRefreshToken
+	require.NoError(t, env.db.First(&rt, "id = ?", "rt1").Error)
+	assert.NotNil(t, rt.RevokedAt)
+
+	// 令牌只能使用一次
+	assert.Equal(t, http.StatusBadRequest, env.call(t, env.handler.ResetPassword, map[string]string{"token": token, "new_password": "another789"}))
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	env := newResetEnv(t)
+
+	assert.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "bob@example.com"}))
+	assert.Empty(t, env.mailer.Sent())
+}
+
+// failingMailer 发送总是失败的邮件发送器
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, msg *mail.Message) error {
+	return errors.New("smtp unavailable")
+}
+
+// TestPasswordResetMailFailure 邮件发送失败时与未注册邮箱的响应相同，且不留下无法送达的令牌
+func TestPasswordResetMailFailure(t *testing.T) {
+	env := newResetEnv(t)
+	env.handler.app.Mailer = failingMailer{}
+
+	resp := testutil.Call(t, env.handler.ForgotPassword, "", map[string]string{"email": "alice@example.com"})
+	unknown := testutil.Call(t, env.handler.ForgotPassword, "", map[string]string{"email": "bob@example.com"})
+	assert.Equal(t, unknown, resp)
+
+	var count int64
+	require.NoError(t, env.db.Model(&CorePasswordReset{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+// TestPasswordResetKeptWhenUpdateFails 更新密码失败时令牌不被消耗，用户可以重试
+func TestPasswordResetKeptWhenUpdateFails(t *testing.T) {
+	env := newResetEnv(t)
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "alice@example.com"}))
+	token := env.lastToken(t)
+
+	failed := false
+	require.NoError(t, env.db.Callback().Update().Before("gorm:update").Register("test:fail_password", func(tx *gorm.DB) {
+		if tx.Statement.Table == (&user.CoreUser{}).TableName() && !failed {
+			failed = true
+			tx.AddError(errors.New("update failed"))
+		}
+	}))
+
+	assert.Equal(t, http.StatusInternalServerError, env.call(t, env.handler.ResetPassword, map[string]string{"token": token, "new_password": "newpass456"}))
+	var reset CorePasswordReset
+	require.NoError(t, env.db.First(&reset).Error)
+	assert.Nil(t, reset.UsedAt)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ResetPassword, map[string]string{"token": token, "new_password": "newpass456"}))
+	var u user.CoreUser
+	require.NoError(t, env.db.First(&u, "id = ?", "u1").Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("newpass456")))
+}
+
+func TestPasswordResetExpiredAndSuperseded(t *testing.T) {
+	env := newResetEnv(t)
+	env.handler.app.Config.Auth.PasswordReset.SendInterval = 0
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "alice@example.com"}))
+	first := env.lastToken(t)
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "alice@example.com"}))
+	second := env.lastToken(t)
+
+	// 重新申请后旧链接失效
+	assert.Equal(t, http.StatusBadRequest, env.call(t, env.handler.ResetPassword, map[string]string{"token": first, "new_password": "newpass456"}))
+
+	require.NoError(t, env.db.Model(&CorePasswordReset{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error)
+	assert.Equal(t, http.StatusBadRequest, env.call(t, env.handler.ResetPassword, map[string]string{"token": second, "new_password": "newpass456"}))
+}
+
+func TestPasswordResetSendInterval(t *testing.T) {
+	env := newResetEnv(t)
+
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "alice@example.com"}))
+	require.Equal(t, http.StatusOK, env.call(t, env.handler.ForgotPassword, map[string]string{"email": "alice@example.com"}))
+	assert.Len(t, env.mailer.Sent(), 1)
+}
@@ -15,6 +15,14 @@ func RegisterAutoMigrate(app *app.App) {
 		&CoreUserTwoFA{},
 		&CoreUserTwoFALog{},
 	)
+	RegisterPasswordAutoMigrate(app)
+}
+
+// RegisterPasswordAutoMigrate 迁移找回密码相关的表
+func RegisterPasswordAutoMigrate(app *app.App) {
+	app.Db.DB.AutoMigrate(
+		&CorePasswordReset{},
+	)
 }
 
 // RegisterRoutes 提供 Identity 模块的路由注册方法
@@ -37,8 +45,7 @@ func RegisterRoutes(app *app.App) {
 		authGroup.POST("/logout", loginHandler.Logout)
 		authGroup.POST("/refresh", loginHandler.RefreshToken)
 
-		// 手机认证路由
-		authGroup.POST("/login/phone", loginHandler.Login) // 手机号登录（需要扩展）
+		// 手机号验证码登录见 auth/auth_sms
 
 		// OAuth2 认证路由
 		authGroup.GET("/oauth/authorize", oauthHandler.Authorize)
@@ -57,9 +64,20 @@ func RegisterRoutes(app *app.App) {
 		registerGroup.POST("", registerHandler.Register)
 	}
 
+	RegisterPasswordRoutes(app)
+}
+
+// RegisterPasswordRoutes 注册找回密码路由
+// identity 的登录等路由与 auth 模块重复尚未启用，找回密码路由可单独挂载
+func RegisterPasswordRoutes(app *app.App) {
+	registerHandler := NewRegisterHandler(app)
+
+	e := app.Server.Engine()
+
 	// 重置密码路由
 	resetPasswordGroup := e.Group("/api/core/password")
 	{
-		resetPasswordGroup.PUT("/reset", registerHandler.ResetPassword)
+		resetPasswordGroup.POST("/forgot", registerHandler.ForgotPassword) // 发送重置密码邮件
+		resetPasswordGroup.PUT("/reset", registerHandler.ResetPassword)    // 使用重置令牌设置新密码
 	}
 }
@@ -107,7 +107,7 @@ func (h *PermissionHandler) DeletePermission(c echo.Context) error {
 	}
 
 	// 再删除权限
-	if err := h.repo.Delete(c.Request().Context(), id); err != nil {
+	if err := h.repo.Delete(c.Request().Context(), id, operatorID); err != nil {
 		return response.Error(c, http.StatusInternalServerError, "删除失败")
 	}
 
@@ -101,7 +101,7 @@ func (h *RoleHandler) DeleteRole(c echo.Context) error {
 	}
 
 	// 再删除角色
-	if err := h.roleRepo.Delete(c.Request().Context(), id); err != nil {
+	if err := h.roleRepo.Delete(c.Request().Context(), id, operatorID); err != nil {
 		return response.Error(c, http.StatusInternalServerError, "删除失败")
 	}
 
@@ -50,7 +50,7 @@ func (h *Handler) Create(c echo.Context) error {
 		Username:  req.Username,
 		Password:  string(hashedBytes),
 		Nickname:  req.Nickname,
-		Email:     req.Email,
+		Email:     NormalizeEmail(req.Email),
 		Phone:     req.Phone,
 		Status:    1, // 默认启用
 		CreatedBy: operatorID,
@@ -119,7 +119,7 @@ func (h *Handler) Update(c echo.Context) error {
 	}
 
 	// 检查是否存在
-	_, err := h.repo.GetByID(c.Request().Context(), id)
+	existing, err := h.repo.GetByID(c.Request().Context(), id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return response.Error(c, http.StatusInternalServerError, "用户不存在")
@@ -127,12 +127,20 @@ func (h *Handler) Update(c echo.Context) error {
 		return response.Error(c, http.StatusInternalServerError, err.Error())
 	}
 
+	email := NormalizeEmail(req.Email)
 	updates := map[string]interface{}{
 		"nickname":   req.Nickname,
-		"email":      req.Email,
+		"email":      email,
 		"phone":      req.Phone,
 		"updated_by": operatorID,
 	}
+	// 邮箱或手机号变更后需要重新验证，邮箱只改变大小写不算变更
+	if email != NormalizeEmail(existing.Email) {
+		updates["email_verified_at"] = nil
+	}
+	if req.Phone != existing.Phone {
+		updates["phone_verified_at"] = nil
+	}
 
 	if err := h.repo.GetDB(c.Request().Context()).Model(&CoreUser{}).Where("id = ?", id).Updates(updates).Error; err != nil {
 		return response.Error(c, http.StatusInternalServerError, err.Error())
@@ -141,6 +149,32 @@ func (h *Handler) Update(c echo.Context) error {
 	return response.SuccessWithMsg[any](c, "更新成功", nil)
 }
 
+// UpdateStatus 启用或禁用用户
+func (h *Handler) UpdateStatus(c echo.Context) error {
+	id := c.Param("id")
+	var req UpdateStatusReq
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "请求参数错误")
+	}
+	if req.Status != 0 && req.Status != 1 {
+		return response.Error(c, http.StatusBadRequest, "状态值无效")
+	}
+
+	// 检查是否存在
+	if _, err := h.repo.GetByID(c.Request().Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "用户不存在")
+		}
+		return response.Error(c, http.StatusInternalServerError, err.Error())
+	}
+
+	if err := h.repo.UpdateStatus(c.Request().Context(), id, req.Status); err != nil {
+		return response.Error(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return response.SuccessWithMsg[any](c, "更新成功", nil)
+}
+
 // Delete 删除用户
 func (h *Handler) Delete(c echo.Context) error {
 	id := c.Param("id")
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"king-starter/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// TestEmailNormalized 邮箱统一保存为小写，只改变大小写不会清除验证状态
+func TestEmailNormalized(t *testing.T) {
+	db := testutil.NewDB(t, &CoreUser{})
+	repo := NewRepository(db)
+	handler := NewHandler(repo)
+	ctx := context.Background()
+
+	resp := testutil.Call(t, handler.Create, "admin", map[string]string{"username": "alice", "password": "Passw0rd!", "email": " Alice@Example.com ", "phone": "13800000001"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+	id := resp.Data.(map[string]interface{})["id"].(string)
+
+	u, err := repo.GetByEmail(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+	u, err = repo.GetByAccount(ctx, "ALICE@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+
+	verifiedAt := time.Now()
+	require.NoError(t, db.Model(&CoreUser{}).Where("id = ?", id).Update("email_verified_at", verifiedAt).Error)
+
+	update := func(email string) *CoreUser {
+		resp := testutil.Call(t, handler.Update, "admin", map[string]string{"email": email, "phone": "13800000001"}, "id", id)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Msg)
+		u, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		return u
+	}
+
+	u = update("ALICE@example.COM")
+	assert.Equal(t, "alice@example.com", u.Email)
+	assert.NotNil(t, u.EmailVerifiedAt)
+
+	u = update("bob@example.com")
+	assert.Equal(t, "bob@example.com", u.Email)
+	assert.Nil(t, u.EmailVerifiedAt)
+}
@@ -1,27 +1,36 @@
 package user
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type CoreUser struct {
-	ID        string         `gorm:"type:varchar(32);primaryKey;comment:用户ID(UUID v7)" json:"id"`
-	Username  string         `gorm:"type:varchar(50);not null;comment:用户名" json:"username"`
-	Password  string         `gorm:"type:varchar(255);not null;comment:密码哈希" json:"-"` // 序列化时忽略
-	Nickname  string         `gorm:"type:varchar(50);comment:昵称" json:"nickname"`
-	Status    int            `gorm:"type:tinyint;default:1;comment:状态(1:正常 0:禁用)" json:"status"`
-	Email     string         `gorm:"type:varchar(100);uniqueIndex;not null;comment:邮箱" json:"email"`
-	Phone     string         `gorm:"type:varchar(20);uniqueIndex;not null;comment:手机号" json:"phone"`
-	CreatedAt time.Time      `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
-	CreatedBy string         `gorm:"type:varchar(32);comment:创建人ID" json:"created_by"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
-	UpdatedBy string         `gorm:"type:varchar(32);comment:更新人ID" json:"updated_by"`
-	DeletedAt gorm.DeletedAt `gorm:"index;comment:删除时间" json:"deleted_at,omitempty"`
-	DeletedBy string         `gorm:"type:varchar(32);comment:删除人ID" json:"deleted_by,omitempty"`
+	ID              string         `gorm:"type:varchar(32);primaryKey;comment:用户ID(UUID v7)" json:"id"`
+	Username        string         `gorm:"type:varchar(50);not null;comment:用户名" json:"username"`
+	Password        string         `gorm:"type:varchar(255);not null;comment:密码哈希" json:"-"` // 序列化时忽略
+	Nickname        string         `gorm:"type:varchar(50);comment:昵称" json:"nickname"`
+	Status          int            `gorm:"type:tinyint;default:1;comment:状态(1:正常 0:禁用)" json:"status"`
+	Email           string         `gorm:"type:varchar(100);uniqueIndex;not null;comment:邮箱" json:"email"`
+	Phone           string         `gorm:"type:varchar(20);uniqueIndex;not null;comment:手机号" json:"phone"`
+	EmailVerifiedAt *time.Time     `gorm:"comment:邮箱验证时间(为空表示未验证)" json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time     `gorm:"comment:手机号验证时间(为空表示未验证)" json:"phone_verified_at"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	CreatedBy       string         `gorm:"type:varchar(32);comment:创建人ID" json:"created_by"`
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
+	UpdatedBy       string         `gorm:"type:varchar(32);comment:更新人ID" json:"updated_by"`
+	DeletedAt       gorm.DeletedAt `gorm:"index;comment:删除时间" json:"deleted_at,omitempty"`
+	DeletedBy       string         `gorm:"type:varchar(32);comment:删除人ID" json:"deleted_by,omitempty"`
 }
 
 func (u *CoreUser) TableName() string {
 	return "core_user"
 }
+
+// NormalizeEmail 邮箱统一去除首尾空白并转为小写后保存和查询
+// 找回密码、邮箱验证码登录、第三方登录等都按小写邮箱精确匹配
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
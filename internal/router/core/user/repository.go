@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"king-starter/pkg/goutils/gormutil"
 
@@ -10,6 +12,7 @@ import (
 
 type Repository struct {
 	*gormutil.BaseRepo[CoreUser]
+	statusHooks []StatusChangeHook
 }
 
 // NewRepository 创建 User Repo
@@ -29,12 +32,78 @@ func (r *Repository) GetByUsername(ctx context.Context, username string) (*CoreU
 	return &user, nil
 }
 
+// GetByEmail 根据邮箱查询用户
+func (r *Repository) GetByEmail(ctx context.Context, email string) (*CoreUser, error) {
+	var user CoreUser
+	err := r.GetDB(ctx).Where("email = ?", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByPhone 根据手机号查询用户
+func (r *Repository) GetByPhone(ctx context.Context, phone string) (*CoreUser, error) {
+	var user CoreUser
+	err := r.GetDB(ctx).Where("phone = ?", phone).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByAccount 根据账号查询用户，账号可以是用户名、邮箱或手机号（用于登录校验），邮箱不区分大小写
+func (r *Repository) GetByAccount(ctx context.Context, account string) (*CoreUser, error) {
+	var user CoreUser
+	err := r.GetDB(ctx).
+		Where("username = ? OR email = ? OR phone = ?", account, NormalizeEmail(account), account).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // UpdatePassword 更新密码
 func (r *Repository) UpdatePassword(ctx context.Context, userID, newHash string) error {
 	return r.GetDB(ctx).Model(&CoreUser{}).Where("id = ?", userID).Update("password", newHash).Error
 }
 
-// UpdateStatus 更新状态
+// MarkEmailVerified 标记邮箱已验证，邮箱在验证期间被修改时不更新，返回是否更新成功
+func (r *Repository) MarkEmailVerified(ctx context.Context, userID, email string) (bool, error) {
+	result := r.GetDB(ctx).Model(&CoreUser{}).
+		Where("id = ? AND email = ?", userID, email).
+		Update("email_verified_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkPhoneVerified 标记手机号已验证，手机号在验证期间被修改时不更新，返回是否更新成功
+func (r *Repository) MarkPhoneVerified(ctx context.Context, userID, phone string) (bool, error) {
+	result := r.GetDB(ctx).Model(&CoreUser{}).
+		Where("id = ? AND phone = ?", userID, phone).
+		Update("phone_verified_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateStatus 更新状态，成功后依次执行通过 OnStatusChange 注册的回调
 func (r *Repository) UpdateStatus(ctx context.Context, userID string, status int) error {
-	return r.GetDB(ctx).Model(&CoreUser{}).Where("id = ?", userID).Update("status", status).Error
+	if err := r.GetDB(ctx).Model(&CoreUser{}).Where("id = ?", userID).Update("status", status).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, hook := range r.statusHooks {
+		if err := hook(ctx, userID, status); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StatusChangeHook 用户状态变更后的回调，例如禁用用户时吊销其所有会话
+type StatusChangeHook func(ctx context.Context, userID string, status int) error
+
+// OnStatusChange 注册用户状态变更回调，只对当前 Repository 生效
+// user 模块不能反向依赖认证模块，由认证模块在路由注册阶段向 user 模块路由使用的 Repository 挂载
+func (r *Repository) OnStatusChange(hook StatusChangeHook) {
+	r.statusHooks = append(r.statusHooks, hook)
 }
@@ -16,6 +16,11 @@ type UpdateUserReq struct {
 	Phone    string `json:"phone"`
 }
 
+// UpdateStatusReq 更新用户状态请求
+type UpdateStatusReq struct {
+	Status int `json:"status" validate:"oneof=0 1"` // 1: 正常 0: 禁用
+}
+
 // ChangePasswordReq 修改密码请求
 type ChangePasswordReq struct {
 	OldPassword string `json:"old_password" validate:"required"`
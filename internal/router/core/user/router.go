@@ -2,6 +2,8 @@ package user
 
 import (
 	"king-starter/internal/app"
+	"king-starter/internal/common"
+	"king-starter/pkg/http/middleware"
 	"king-starter/pkg/logx"
 )
 
@@ -12,7 +14,8 @@ func RegisterAutoMigrate(app *app.App) {
 	)
 }
 
-func RegisterRoutes(app *app.App, prefix string) {
+// RegisterRoutes 注册用户路由，返回路由使用的 Repository，供认证模块挂载状态变更回调
+func RegisterRoutes(app *app.App, prefix string) *Repository {
 	var repo = NewRepository(app.Db.DB)
 	var handler = NewHandler(repo)
 
@@ -26,5 +29,12 @@ func RegisterRoutes(app *app.App, prefix string) {
 		group.DELETE("/:id", handler.Delete)
 	}
 
+	// 禁用用户会吊销其所有令牌和会话，仅管理员可操作
+	adminGroup := e.Group(prefix+"/core/auth/admin/users", middleware.JWTAuthMiddleware(app.Jwt), middleware.RequireRoles(common.AdminRoleCode))
+	{
+		adminGroup.PUT("/:id/status", handler.UpdateStatus)
+	}
+
 	logx.Info("Registered user router")
+	return repo
 }
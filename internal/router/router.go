@@ -3,6 +3,7 @@ package router
 import (
 	"king-starter/internal/app"
 	"king-starter/internal/router/core/auth"
+	"king-starter/internal/router/core/identity"
 	"king-starter/internal/router/core/permission"
 	"king-starter/internal/router/core/role"
 	"king-starter/internal/router/core/user"
@@ -17,20 +18,26 @@ func RegisterAutoMigrate(app *app.App) {
 	role.RegisterAutoMigrate(app)
 	permission.RegisterAutoMigrate(app)
 	auth.RegisterAutoMigrate(app)
+	identity.RegisterPasswordAutoMigrate(app)
 }
 
 func RegisterAll(app *app.App) {
 	// 按需启用自动迁移数据库表结构
 	RegisterAutoMigrate(app)
 
+	// 登记配置文件中的 OAuth2 权限范围，需在注册路由之前
+	auth.RegisterScopes(app)
+
 	// hello 测试模块
 	hello.RegisterRoutes(app, prefix)
 
 	// core 模块
-	user.RegisterRoutes(app, prefix)
+	users := user.RegisterRoutes(app, prefix)
 	role.RegisterRoutes(app, prefix)
 	permission.RegisterRoutes(app, prefix)
 	// 认证模块
 	// identity.RegisterRoutes(app)
-	auth.RegisterAuthRoutes(app)
+	auth.RegisterAuthRoutes(app, users)
+	// 找回密码
+	identity.RegisterPasswordRoutes(app)
 }
@@ -0,0 +1,75 @@
+package testutil
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"king-starter/pkg/mail"
+	"king-starter/pkg/sms"
+
+	"github.com/stretchr/testify/require"
+)
+
+// codePattern 邮件正文中的六位验证码
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+// Mailer 记录发送的邮件，不实际发送
+type Mailer struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+}
+
+func (m *Mailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent 返回已发送的邮件
+func (m *Mailer) Sent() []*mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*mail.Message(nil), m.sent...)
+}
+
+// Last 返回最近发送的一封邮件
+func (m *Mailer) Last(t *testing.T) *mail.Message {
+	sent := m.Sent()
+	require.NotEmpty(t, sent)
+	return sent[len(sent)-1]
+}
+
+// LastCode 返回最近一封邮件中的验证码
+func (m *Mailer) LastCode(t *testing.T) string {
+	return codePattern.FindString(m.Last(t).Text)
+}
+
+// SMSSender 记录发送的短信，不实际发送
+type SMSSender struct {
+	mu   sync.Mutex
+	sent []*sms.Message
+}
+
+func (s *SMSSender) Send(ctx context.Context, msg *sms.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// Sent 返回已发送的短信
+func (s *SMSSender) Sent() []*sms.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*sms.Message(nil), s.sent...)
+}
+
+// LastCode 返回最近一条短信中的验证码
+func (s *SMSSender) LastCode(t *testing.T) string {
+	sent := s.Sent()
+	require.NotEmpty(t, sent)
+	return sent[len(sent)-1].Params["code"]
+}
@@ -0,0 +1,96 @@
+// Package testutil 各模块处理器测试共用的测试夹具：内存数据库、JWT、邮件和短信记录器以及处理器调用
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"king-starter/pkg/goutils/echoutil"
+	"king-starter/pkg/jwt"
+	"king-starter/pkg/logx"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Main 初始化日志后运行测试，在测试包的 TestMain 中调用
+func Main(m *testing.M) {
+	InitLogger()
+	os.Exit(m.Run())
+}
+
+// InitLogger 初始化默认日志，TestMain 需要额外准备时单独调用
+func InitLogger() {
+	logxCfg := logx.DefaultLoggerConfig()
+	logx.NewSlog(&logxCfg)
+}
+
+// NewDB 创建内存 sqlite 数据库并迁移指定模型
+// 内存数据库的每个连接相互独立，限制为单个连接，保证并发测试访问的是同一个数据库
+func NewDB(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(models...))
+	return db
+}
+
+// NewJWT 创建测试用 JWT 实例，访问令牌有效期为一小时
+func NewJWT() *jwt.JWT {
+	return jwt.New([]byte("test-secret"), "test", int(time.Hour))
+}
+
+// Response 统一响应结构
+type Response struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+// Call 以 JSON 请求体调用处理器并解析统一响应
+// userID 不为空时设置为当前登录用户，params 为交替排列的路径参数名和值
+func Call(t *testing.T, h echo.HandlerFunc, userID string, body interface{}, params ...string) Response {
+	return Do(t, h, NewRequest(t, body), userID, params...)
+}
+
+// NewRequest 创建 JSON 请求，需要设置请求头（如 Authorization、X-Real-IP）时配合 Do 使用
+func NewRequest(t *testing.T, body interface{}) *http.Request {
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(raw)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req
+}
+
+// Do 使用给定请求调用处理器并解析统一响应，参数含义同 Call
+func Do(t *testing.T, h echo.HandlerFunc, req *http.Request, userID string, params ...string) Response {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if userID != "" {
+		echoutil.SetUserID(c, userID)
+	}
+	if len(params) > 0 {
+		var names, values []string
+		for i := 0; i+1 < len(params); i += 2 {
+			names = append(names, params[i])
+			values = append(values, params[i+1])
+		}
+		c.SetParamNames(names...)
+		c.SetParamValues(values...)
+	}
+	require.NoError(t, h(c))
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+	return resp
+}
@@ -0,0 +1,41 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+)
+
+// RandomToken 生成 n 字节的随机令牌，使用 base64url（无填充）编码。
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read 从 Go 1.24 起不会返回错误
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// RandomString 从 alphabet 中均匀随机选取 n 个字符，适用于验证码、恢复码等需要人工输入的场景。
+func RandomString(alphabet string, n int) string {
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		idx, _ := rand.Int(rand.Reader, max)
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b)
+}
+
+// SHA256Hex 返回字符串的 SHA-256 十六进制摘要。
+// 适用于存储刷新令牌这类高熵秘密，低熵的口令请使用 bcrypt。
+func SHA256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// EqualHash 常量时间比较明文与已存储的 SHA256Hex 摘要是否匹配。
+func EqualHash(plain, hashed string) bool {
+	return subtle.ConstantTimeCompare([]byte(SHA256Hex(plain)), []byte(hashed)) == 1
+}
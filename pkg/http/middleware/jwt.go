@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"king-starter/pkg/goutils/echoutil"
 	"king-starter/pkg/logx"
 
 	// "king-starter/pkg/app"
@@ -15,6 +16,9 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// ClaimsKey 当前请求的 JWT Claims 在 echo.Context 中的键
+const ClaimsKey = "Claims"
+
 // JWTAuthMiddleware JWT 认证中间件
 func JWTAuthMiddleware(jwt *jwt.JWT) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -25,15 +29,14 @@ func JWTAuthMiddleware(jwt *jwt.JWT) echo.MiddlewareFunc {
 				return echoresp.Error(c, http.StatusUnauthorized, resp.ErrUnauthorized)
 			}
 
-			// 按空格分割
-			parts := strings.SplitN(authHeader, " ", 2)
-			if !(len(parts) == 2 && parts[0] == "Bearer") {
+			tokenString, ok := BearerToken(c)
+			if !ok {
 				logx.Warn("Authorization 格式错误 => " + "authHeader=" + authHeader)
 				return echoresp.Error(c, http.StatusUnauthorized, resp.ErrUnauthorized)
 			}
 
-			// parts[1] 是获取到的 tokenString，使用定义好的解析函数并校验过期时间
-			claims, err := jwt.ParseToken(parts[1])
+			// 使用定义好的解析函数校验签名、过期时间以及是否已被吊销
+			claims, err := jwt.ParseTokenWithContext(c.Request().Context(), tokenString)
 			if err != nil {
 				logx.Warn("JWT 解析失败", logger.Error(err))
 				return echoresp.Error(c, http.StatusUnauthorized, resp.ErrUnauthorized)
@@ -41,7 +44,48 @@ func JWTAuthMiddleware(jwt *jwt.JWT) echo.MiddlewareFunc {
 
 			// 将当前请求的 userID 保存到请求的上下文 c 上
 			c.Set("UserID", claims.UserID)
+			echoutil.SetUserID(c, claims.UserID)
+			c.Set(ClaimsKey, claims)
 			return next(c)
 		}
 	}
 }
+
+// BearerToken 从 Authorization 头中提取 Bearer 令牌
+func BearerToken(c echo.Context) (string, bool) {
+	// 按空格分割
+	parts := strings.SplitN(c.Request().Header.Get("Authorization"), " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// GetClaims 获取 JWTAuthMiddleware 解析出的 Claims，未认证时返回 nil
+func GetClaims(c echo.Context) *jwt.CustomClaims {
+	if claims, ok := c.Get(ClaimsKey).(*jwt.CustomClaims); ok {
+		return claims
+	}
+	return nil
+}
+
+// RequireRoles 角色校验中间件，需放在 JWTAuthMiddleware 之后
+// 当前用户拥有任意一个指定角色即可通过
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := GetClaims(c)
+			if claims == nil {
+				return echoresp.Error(c, http.StatusUnauthorized, resp.ErrUnauthorized)
+			}
+			for _, owned := range strings.Split(claims.Roles, ",") {
+				for _, role := range roles {
+					if owned == role {
+						return next(c)
+					}
+				}
+			}
+			return echoresp.Error(c, http.StatusForbidden, resp.ErrForbidden)
+		}
+	}
+}
@@ -35,6 +35,12 @@ func ToHTTPStatus(c resp.CodeMsg) int {
 		if c.Code == "404" {
 			return http.StatusNotFound
 		}
+		if c.Code == "423" {
+			return http.StatusLocked
+		}
+		if c.Code == "429" {
+			return http.StatusTooManyRequests
+		}
 		return http.StatusBadRequest
 	case resp.StatusServerError:
 		if c.Code == "500" {
@@ -12,6 +12,9 @@ var (
 	ErrUnauthorized = CodeMsg{Code: "401", Msg: "未登录或登录已过期", Status: StatusClientError}
 	ErrForbidden    = CodeMsg{Code: "403", Msg: "权限不足", Status: StatusClientError}
 	ErrNotFound     = CodeMsg{Code: "404", Msg: "资源不存在", Status: StatusClientError}
+	// 认证相关
+	ErrAccountLocked   = CodeMsg{Code: "423", Msg: "账号已锁定，请稍后再试", Status: StatusClientError}
+	ErrTooManyRequests = CodeMsg{Code: "429", Msg: "请求过于频繁，请稍后再试", Status: StatusClientError}
 	// 服务端错误
 	ErrInternalServer = CodeMsg{Code: "500", Msg: "内部服务错误", Status: StatusServerError}
 	ErrUnknown        = CodeMsg{Code: "500", Msg: "未知错误", Status: StatusServerError}
@@ -3,23 +3,125 @@ package jwt
 import (
 	"fmt"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // JwtConfig 简化配置
+//
+// 支持两种模式：
+//   - 单密钥：只配置 Secret，使用 HS256，密钥 kid 固定为 DefaultKid
+//   - 密钥环：配置 Keys 和 ActiveKid，ActiveKid 指定的密钥用于签名，其余密钥只用于验证；
+//     密钥可使用 RS256/ES256/EdDSA，公钥通过 /.well-known/jwks.json 公开，下游服务无需共享密钥即可离线验证
+//
+// 不停机轮换密钥的步骤：
+//  1. 将新密钥加入 Keys（不修改 ActiveKid）并发布到所有实例，此时所有实例都能验证新密钥签发的令牌
+//  2. 将 ActiveKid 改为新密钥并发布，新令牌开始使用新密钥签名
+//  3. 为旧密钥设置 NotAfter（不早于最后一个旧令牌的过期时间），到期后即可从配置中删除
 type JwtConfig struct {
-	Secret string `yaml:"secret"` // 必填
-	Expire int    `yaml:"expire"` // 过期时间（秒）
-	Issuer string `yaml:"issuer"` // 签发者
+	Secret    string      `yaml:"secret"`                               // 单密钥模式的签名密钥，配置了 Keys 时忽略
+	Expire    int         `yaml:"expire"`                               // 过期时间（秒）
+	Issuer    string      `yaml:"issuer"`                               // 签发者
+	ActiveKid string      `yaml:"active_kid" mapstructure:"active_kid"` // 密钥环模式下当前用于签名的密钥
+	Keys      []KeyConfig `yaml:"keys" mapstructure:"keys"`             // 密钥环
+}
+
+// KeyConfig 密钥环中的单个密钥
+type KeyConfig struct {
+	Kid            string `yaml:"kid" mapstructure:"kid"`                           // 密钥ID，写入令牌头部
+	Alg            string `yaml:"alg" mapstructure:"alg"`                           // 签名算法：HS256（默认）/RS256/ES256/EdDSA
+	Secret         string `yaml:"secret" mapstructure:"secret"`                     // HMAC 密钥
+	PrivateKeyFile string `yaml:"private_key_file" mapstructure:"private_key_file"` // 非对称算法的 PEM 私钥文件
+	PublicKeyFile  string `yaml:"public_key_file" mapstructure:"public_key_file"`   // 非对称算法的 PEM 公钥/证书文件，只用于验证的旧密钥可只配置公钥
+	NotAfter       string `yaml:"not_after" mapstructure:"not_after"`               // 停止验证的时间（RFC3339 或 2006-01-02），留空表示不过期
+}
+
+// load 根据配置加载密钥材料
+func (k KeyConfig) load() (Key, error) {
+	method, err := ParseMethod(k.Alg)
+	if err != nil {
+		return Key{}, fmt.Errorf("jwt key %q: %w", k.Kid, err)
+	}
+	notAfter, err := parseNotAfter(k.NotAfter)
+	if err != nil {
+		return Key{}, fmt.Errorf("jwt key %q not_after is invalid: %w", k.Kid, err)
+	}
+	key := Key{Kid: k.Kid, Method: method, Secret: []byte(k.Secret), NotAfter: notAfter}
+	if key.symmetric() {
+		return key, nil
+	}
+
+	key.Secret = nil
+	if k.PrivateKeyFile != "" {
+		if key.PrivateKey, err = LoadPrivateKeyFile(k.PrivateKeyFile); err != nil {
+			return Key{}, fmt.Errorf("jwt key %q: %w", k.Kid, err)
+		}
+	}
+	if k.PublicKeyFile != "" {
+		if key.PublicKey, err = LoadPublicKeyFile(k.PublicKeyFile); err != nil {
+			return Key{}, fmt.Errorf("jwt key %q: %w", k.Kid, err)
+		}
+	}
+	return key, nil
 }
 
 // Validate 简单验证
 func (c *JwtConfig) Validate() error {
-	if c.Secret == "" {
-		return fmt.Errorf("[jwtconfig] jwt secret is required")
+	if len(c.Keys) == 0 {
+		if c.Secret == "" {
+			return fmt.Errorf("[jwtconfig] jwt secret is required")
+		}
+		return nil
+	}
+
+	if c.ActiveKid == "" {
+		return fmt.Errorf("[jwtconfig] jwt active_kid is required when keys are configured")
+	}
+	seen := make(map[string]bool, len(c.Keys))
+	for _, k := range c.Keys {
+		if k.Kid == "" {
+			return fmt.Errorf("[jwtconfig] jwt key kid is required")
+		}
+		if seen[k.Kid] {
+			return fmt.Errorf("[jwtconfig] jwt key kid %q is duplicated", k.Kid)
+		}
+		seen[k.Kid] = true
+		method, err := ParseMethod(k.Alg)
+		if err != nil {
+			return fmt.Errorf("[jwtconfig] jwt key %q: %w", k.Kid, err)
+		}
+		if method == jwt.SigningMethodHS256 {
+			if k.Secret == "" {
+				return fmt.Errorf("[jwtconfig] jwt key %q secret is required", k.Kid)
+			}
+		} else if k.PrivateKeyFile == "" && (k.PublicKeyFile == "" || k.Kid == c.ActiveKid) {
+			return fmt.Errorf("[jwtconfig] jwt key %q private_key_file is required", k.Kid)
+		}
+		notAfter, err := parseNotAfter(k.NotAfter)
+		if err != nil {
+			return fmt.Errorf("[jwtconfig] jwt key %q not_after is invalid: %w", k.Kid, err)
+		}
+		if k.Kid == c.ActiveKid && !notAfter.IsZero() && notAfter.Before(time.Now()) {
+			return fmt.Errorf("[jwtconfig] jwt active key %q has expired", k.Kid)
+		}
+	}
+	if !seen[c.ActiveKid] {
+		return fmt.Errorf("[jwtconfig] jwt active_kid %q not found in keys", c.ActiveKid)
 	}
 	return nil
 }
 
+// parseNotAfter 解析密钥停止验证时间，空字符串返回零值
+func parseNotAfter(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
 func DefaultJwtConfig() JwtConfig {
 	return JwtConfig{
 		Secret: "your-32-char-secret-key-here",
@@ -33,4 +135,13 @@ jwt:
   secret: "your-32-char-secret-key-here"
   expires: 86400    # 24小时过期
   issuer: "myapp"
+  # 密钥环（可选），配置后忽略 secret
+  active_kid: "2025-06"
+  keys:
+    - kid: "2025-06"
+      alg: "ES256"
+      private_key_file: "/etc/king/jwt/2025-06.pem"
+    - kid: "2025-01"
+      secret: "old-32-char-secret-key-here"
+      not_after: "2025-06-08"
 */
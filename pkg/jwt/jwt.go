@@ -1,78 +1,232 @@
 package jwt
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// 令牌用途（token_use 声明），同一密钥签发的不同用途的令牌不能互相替代
+const (
+	TokenUseAccess = "access" // 登录签发的访问令牌
+	TokenUseID     = "id"     // OpenID Connect id_token
+)
+
+// ErrTokenUse 令牌不是访问令牌，如 id_token 被当作访问令牌使用
+var ErrTokenUse = errors.New("token is not an access token")
+
 // CustomClaims 自定义声明（按需扩展）
 type CustomClaims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username,omitempty"`
 	Roles    string `json:"roles,omitempty"`
+	// AuthTime 用户完成登录认证的时间，刷新得到的令牌沿用原值，用于判断是否需要重新登录
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// TokenUse 令牌用途，访问令牌为 TokenUseAccess，升级前签发的访问令牌没有该声明
+	TokenUse string `json:"token_use,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWT struct {
-	Secret []byte
-	Issuer string
-	Expire int
+	Issuer     string
+	Expire     int
+	keys       *Keyring
+	revocation RevocationStore
 }
 
-// New 创建 JWT 实例
+// New 创建单密钥 JWT 实例，密钥 kid 为 DefaultKid
 func New(secret []byte, issuer string, expire int) *JWT {
+	kr := NewKeyring()
+	_ = kr.AddKey(Key{Kid: DefaultKid, Secret: secret})
+	_ = kr.SetActive(DefaultKid)
+	return NewWithKeyring(kr, issuer, expire)
+}
+
+// NewWithKeyring 使用密钥环创建 JWT 实例
+func NewWithKeyring(kr *Keyring, issuer string, expire int) *JWT {
 	return &JWT{
-		Secret: secret,
 		Issuer: issuer,
 		Expire: expire,
+		keys:   kr,
 	}
 }
 
 // NewWithConfig 使用配置文件进行创建 JWT 实例
 func NewWithConfig(cfg *JwtConfig) (*JWT, error) {
-	secret := []byte(cfg.Secret)
-	issuer := cfg.Issuer
-	expire := cfg.Expire
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	kr, err := NewKeyringWithConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithKeyring(kr, cfg.Issuer, cfg.Expire), nil
+}
 
-	return New(secret, issuer, expire), cfg.Validate()
+// Keyring 返回密钥环，用于运行时添加/切换密钥
+func (j *JWT) Keyring() *Keyring {
+	return j.keys
 }
 
-// GenerateToken 生成 JWT 令牌
+// SetRevocationStore 设置吊销存储，设置后 ParseToken 会拒绝已吊销的令牌
+// 需在开始处理请求之前调用
+func (j *JWT) SetRevocationStore(store RevocationStore) {
+	j.revocation = store
+}
+
+// RevokeToken 吊销单个令牌，未设置吊销存储时不做任何操作
+func (j *JWT) RevokeToken(ctx context.Context, claims *CustomClaims) error {
+	if j.revocation == nil || claims.ID == "" {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(j.Expire))
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return j.revocation.Revoke(ctx, claims.ID, expiresAt)
+}
+
+// RevokeUser 吊销用户当前所有未过期的令牌，未设置吊销存储时不做任何操作
+func (j *JWT) RevokeUser(ctx context.Context, userID string) error {
+	if j.revocation == nil {
+		return nil
+	}
+	now := time.Now()
+	return j.revocation.RevokeUser(ctx, userID, now, now.Add(time.Duration(j.Expire)))
+}
+
+// JWKS 返回用于公开的非对称公钥集合
+func (j *JWT) JWKS() JWKSet {
+	return j.keys.JWKS()
+}
+
+// sign 使用当前签名密钥签名，并在头部写入 kid
+func (j *JWT) sign(claims jwt.Claims) (string, error) {
+	key, err := j.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	signingKey, err := key.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(signingKey)
+}
+
+// Sign 使用当前签名密钥签名任意声明，用于 id_token 等非登录令牌
+// tokenUse 写入 token_use 声明，不能为 TokenUseAccess，ParseToken 据此拒绝把这些令牌当作访问令牌使用
+func (j *JWT) Sign(tokenUse string, claims jwt.MapClaims) (string, error) {
+	if tokenUse == "" || tokenUse == TokenUseAccess {
+		return "", fmt.Errorf("jwt: invalid token use %q", tokenUse)
+	}
+	claims["token_use"] = tokenUse
+	return j.sign(claims)
+}
+
+// SigningAlg 返回当前签名密钥使用的算法
+func (j *JWT) SigningAlg() (string, error) {
+	key, err := j.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	return key.method().Alg(), nil
+}
+
+// keyFunc 根据令牌头部的 kid 选择验证密钥，并要求令牌算法与密钥算法一致
+// 轮换前签发的令牌没有 kid，使用当前签名密钥验证
+func (j *JWT) keyFunc(token *jwt.Token) (interface{}, error) {
+	var (
+		key Key
+		err error
+	)
+	if kid, _ := token.Header["kid"].(string); kid == "" {
+		key, err = j.keys.Active()
+	} else {
+		key, err = j.keys.Lookup(kid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", jwt.ErrTokenUnverifiable, err)
+	}
+	if token.Method.Alg() != key.method().Alg() {
+		return nil, fmt.Errorf("%w: unexpected signing method %s", jwt.ErrTokenSignatureInvalid, token.Method.Alg())
+	}
+	return key.verifyKey(), nil
+}
+
+// GenerateToken 生成 JWT 令牌，登录认证时间为当前时间
 func (j *JWT) GenerateToken(userID, username, roles string) (string, error) {
+	return j.GenerateTokenWithAuthTime(userID, username, roles, time.Now())
+}
+
+// GenerateTokenWithAuthTime 生成 JWT 令牌，authTime 为用户完成登录认证的时间
+// 使用刷新令牌换取访问令牌时传入会话的登录时间，避免刷新被当作重新登录
+func (j *JWT) GenerateTokenWithAuthTime(userID, username, roles string, authTime time.Time) (string, error) {
 	now := time.Now()
 	claims := CustomClaims{
 		UserID:   userID,
 		Username: username,
 		Roles:    roles,
+		AuthTime: jwt.NewNumericDate(authTime),
+		TokenUse: TokenUseAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.Issuer,
 			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(j.Expire))),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.Secret)
+	return j.sign(claims)
 }
 
 // ParseToken 解析并验证 JWT 令牌
 func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
+	return j.ParseTokenWithContext(context.Background(), tokenString)
+}
+
+// ParseTokenWithContext 解析并验证 JWT 令牌，设置了吊销存储时同时检查令牌是否已被吊销
+func (j *JWT) ParseTokenWithContext(ctx context.Context, tokenString string) (*CustomClaims, error) {
 	// 解析 JWT 令牌，验证签名和claims，返回 CustomClaims 结构体
 	// 如果令牌无效或claims不匹配，返回错误
-	// keyFunc 用于提供密钥，这里使用预定义的密钥
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.Secret, nil
-	})
+	// keyFunc 根据 kid 从密钥环中选择密钥，只接受密钥环中出现的算法防止算法混淆
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, j.keyFunc,
+		jwt.WithValidMethods(j.keys.Methods()))
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	// 只接受访问令牌：id_token 等使用同一密钥签发，带有其他用途；
+	// 升级前签发的 id_token 没有 token_use，但也没有 user_id
+	if (claims.TokenUse != "" && claims.TokenUse != TokenUseAccess) || claims.UserID == "" {
+		return nil, ErrTokenUse
+	}
+
+	if j.revocation != nil {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := j.revocation.IsRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 	}
-	return nil, jwt.ErrTokenInvalidClaims
+	return claims, nil
 }
 
 // RefreshToken 刷新 JWT 令牌（延长有效期）
@@ -82,10 +236,9 @@ func (j *JWT) RefreshToken(tokenString string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	// 更新过期时间
+	// 更新过期时间，升级前签发的令牌补上用途
 	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Duration(j.Expire)))
-	// 生成新令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// 签名并返回新令牌
-	return token.SignedString(j.Secret)
+	claims.TokenUse = TokenUseAccess
+	// 使用当前签名密钥生成新令牌
+	return j.sign(claims)
 }
@@ -0,0 +1,278 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyRotation 轮换后旧令牌仍可验证，旧密钥过期后拒绝
+func TestKeyRotation(t *testing.T) {
+	cfg := &JwtConfig{
+		Expire:    int(time.Hour),
+		Issuer:    "test",
+		ActiveKid: "k1",
+		Keys:      []KeyConfig{{Kid: "k1", Secret: "secret-1"}},
+	}
+	j, err := NewWithConfig(cfg)
+	assert.NoError(t, err)
+
+	oldToken, err := j.GenerateToken("u1", "alice", "admin")
+	assert.NoError(t, err)
+
+	// 加入新密钥并切换
+	kr := j.Keyring()
+	assert.NoError(t, kr.AddKey(Key{Kid: "k2", Secret: []byte("secret-2")}))
+	assert.NoError(t, kr.SetActive("k2"))
+
+	newToken, err := j.GenerateToken("u1", "alice", "admin")
+	assert.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &CustomClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", parsed.Header["kid"])
+
+	claims, err := j.ParseToken(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+
+	// 旧密钥过期
+	assert.NoError(t, kr.Retire("k1", time.Now().Add(-time.Second)))
+	_, err = j.ParseToken(oldToken)
+	assert.True(t, errors.Is(err, ErrKeyExpired))
+
+	_, err = j.ParseToken(newToken)
+	assert.NoError(t, err)
+
+	// 不能停用当前签名密钥
+	assert.Error(t, kr.Retire("k2", time.Now()))
+	assert.Error(t, kr.RemoveKey("k2"))
+}
+
+// TestUnknownKid 未知 kid 的令牌被拒绝
+func TestUnknownKid(t *testing.T) {
+	j1 := New([]byte("secret-1"), "test", int(time.Hour))
+	token, err := j1.GenerateToken("u1", "alice", "")
+	assert.NoError(t, err)
+
+	kr := NewKeyring()
+	assert.NoError(t, kr.AddKey(Key{Kid: "other", Secret: []byte("secret-1")}))
+	assert.NoError(t, kr.SetActive("other"))
+	j2 := NewWithKeyring(kr, "test", int(time.Hour))
+
+	_, err = j2.ParseToken(token)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+// TestLegacyTokenWithoutKid 轮换前签发的令牌没有 kid，使用当前签名密钥验证
+func TestLegacyTokenWithoutKid(t *testing.T) {
+	j := New([]byte("secret-1"), "test", int(time.Hour))
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{
+		UserID: "u1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte("secret-1"))
+	assert.NoError(t, err)
+
+	claims, err := j.ParseToken(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+}
+
+// TestConfigValidate 密钥环配置校验
+func TestConfigValidate(t *testing.T) {
+	assert.Error(t, (&JwtConfig{}).Validate())
+	assert.NoError(t, (&JwtConfig{Secret: "s"}).Validate())
+	assert.Error(t, (&JwtConfig{Keys: []KeyConfig{{Kid: "k1", Secret: "s"}}}).Validate())
+	assert.Error(t, (&JwtConfig{ActiveKid: "k2", Keys: []KeyConfig{{Kid: "k1", Secret: "s"}}}).Validate())
+	assert.Error(t, (&JwtConfig{ActiveKid: "k1", Keys: []KeyConfig{{Kid: "k1", Secret: "s", NotAfter: "2000-01-01"}}}).Validate())
+	assert.Error(t, (&JwtConfig{ActiveKid: "k1", Keys: []KeyConfig{{Kid: "k1", Secret: "s", NotAfter: "bad"}}}).Validate())
+	assert.NoError(t, (&JwtConfig{ActiveKid: "k1", Keys: []KeyConfig{
+		{Kid: "k1", Secret: "s1"},
+		{Kid: "k0", Secret: "s0", NotAfter: "2000-01-01T00:00:00Z"},
+	}}).Validate())
+}
+
+// TestAsymmetricKeys RS256/ES256/EdDSA 签名、验证与 JWKS 导出
+func TestAsymmetricKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		kid, alg string
+		key      any
+		kty      string
+	}{
+		{"rsa", "RS256", rsaKey, "RSA"},
+		{"ec", "ES256", ecKey, "EC"},
+		{"ed", "EdDSA", edKey, "OKP"},
+	}
+	for _, tc := range cases {
+		der, err := x509.MarshalPKCS8PrivateKey(tc.key)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, tc.kid+".pem")
+		assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+		j, err := NewWithConfig(&JwtConfig{
+			Expire:    int(time.Hour),
+			ActiveKid: tc.kid,
+			Keys: []KeyConfig{
+				{Kid: tc.kid, Alg: tc.alg, PrivateKeyFile: path},
+				{Kid: "hmac", Secret: "secret"},
+			},
+		})
+		assert.NoError(t, err, tc.alg)
+
+		token, err := j.GenerateToken("u1", "alice", "")
+		assert.NoError(t, err, tc.alg)
+		claims, err := j.ParseToken(token)
+		assert.NoError(t, err, tc.alg)
+		assert.Equal(t, "u1", claims.UserID)
+
+		// 签名任意声明使用当前签名密钥
+		alg, err := j.SigningAlg()
+		assert.NoError(t, err)
+		assert.Equal(t, tc.alg, alg)
+		signed, err := j.Sign(TokenUseID, jwt.MapClaims{"sub": "u1", "aud": "client"})
+		assert.NoError(t, err)
+		parsed, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) {
+			return tc.key.(interface{ Public() crypto.PublicKey }).Public(), nil
+		})
+		assert.NoError(t, err, tc.alg)
+		assert.Equal(t, tc.kid, parsed.Header["kid"])
+
+		// 只公开非对称公钥
+		set := j.JWKS()
+		assert.Len(t, set.Keys, 1)
+		assert.Equal(t, tc.kid, set.Keys[0].Kid)
+		assert.Equal(t, tc.alg, set.Keys[0].Alg)
+		assert.Equal(t, tc.kty, set.Keys[0].Kty)
+
+		// 伪造 kid 指向 HMAC 密钥的令牌不被接受
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{UserID: "u1"})
+		forged.Header["kid"] = tc.kid
+		forgedStr, err := forged.SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, err = j.ParseToken(forgedStr)
+		assert.Error(t, err, tc.alg)
+	}
+}
+
+// TestAsymmetricKeyMismatch 算法与密钥类型不匹配时拒绝加载
+func TestAsymmetricKeyMismatch(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	kr := NewKeyring()
+	assert.Error(t, kr.AddKey(Key{Kid: "k1", Method: jwt.SigningMethodRS256, PrivateKey: ecKey}))
+	assert.NoError(t, kr.AddKey(Key{Kid: "k1", Method: jwt.SigningMethodES256, PublicKey: ecKey.Public()}))
+	// 只有公钥的密钥不能用于签名
+	assert.Error(t, kr.SetActive("k1"))
+}
+
+// TestRevocation 吊销单个令牌和用户的所有令牌
+func TestRevocation(t *testing.T) {
+	ctx := context.Background()
+	j := New([]byte("secret-1"), "test", int(time.Hour))
+	store := NewMemoryRevocationStore()
+	j.SetRevocationStore(store)
+
+	t1, err := j.GenerateToken("u1", "alice", "")
+	assert.NoError(t, err)
+	t2, err := j.GenerateToken("u1", "alice", "")
+	assert.NoError(t, err)
+
+	c1, err := j.ParseToken(t1)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, c1.ID)
+
+	// 单个令牌吊销不影响其他令牌
+	assert.NoError(t, j.RevokeToken(ctx, c1))
+	_, err = j.ParseToken(t1)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, err = j.ParseToken(t2)
+	assert.NoError(t, err)
+
+	// 用户级吊销：之前签发的令牌失效，之后签发的令牌有效
+	assert.NoError(t, store.RevokeUser(ctx, "u1", time.Now().Add(time.Second), time.Now().Add(time.Hour)))
+	_, err = j.ParseToken(t2)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	// 与吊销同一秒内签发的令牌同样失效
+	t3, err := j.GenerateToken("u2", "bob", "")
+	assert.NoError(t, err)
+	assert.NoError(t, j.RevokeUser(ctx, "u2"))
+	_, err = j.ParseToken(t3)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	// 过期记录被清理
+	assert.NoError(t, store.Revoke(ctx, "expired", time.Now().Add(-time.Second)))
+	assert.NoError(t, store.Purge(ctx))
+	assert.Len(t, store.tokens, 1)
+}
+
+// TestAuthTime 令牌记录登录时间，刷新后保持不变
+func TestAuthTime(t *testing.T) {
+	j := New([]byte("secret-1"), "test", int(time.Hour))
+	loginAt := time.Now().Add(-time.Hour)
+
+	token, err := j.GenerateTokenWithAuthTime("u1", "alice", "", loginAt)
+	assert.NoError(t, err)
+	refreshed, err := j.RefreshToken(token)
+	assert.NoError(t, err)
+
+	claims, err := j.ParseToken(refreshed)
+	assert.NoError(t, err)
+	assert.Equal(t, loginAt.Unix(), claims.AuthTime.Unix())
+}
+
+// TestTokenUse 同一密钥签发的 id_token 不能当作访问令牌使用
+func TestTokenUse(t *testing.T) {
+	j := New([]byte("secret-1"), "test", int(time.Hour))
+
+	token, err := j.GenerateToken("u1", "alice", "")
+	assert.NoError(t, err)
+	claims, err := j.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, TokenUseAccess, claims.TokenUse)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	idToken, err := j.Sign(TokenUseID, jwt.MapClaims{"iss": "test", "sub": "u1", "user_id": "u1", "exp": exp})
+	assert.NoError(t, err)
+	_, err = j.ParseToken(idToken)
+	assert.ErrorIs(t, err, ErrTokenUse)
+
+	// 升级前签发的 id_token 没有 token_use，也没有 user_id
+	legacy, err := j.sign(jwt.MapClaims{"iss": "test", "sub": "u1", "exp": exp})
+	assert.NoError(t, err)
+	_, err = j.ParseToken(legacy)
+	assert.ErrorIs(t, err, ErrTokenUse)
+
+	// 升级前签发的访问令牌没有 token_use，仍然有效
+	legacy, err = j.sign(jwt.MapClaims{"iss": "test", "user_id": "u1", "exp": exp})
+	assert.NoError(t, err)
+	_, err = j.ParseToken(legacy)
+	assert.NoError(t, err)
+
+	_, err = j.Sign(TokenUseAccess, jwt.MapClaims{"sub": "u1"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKid 单密钥模式下使用的密钥ID
+const DefaultKid = "default"
+
+var (
+	ErrKeyNotFound = errors.New("jwt key not found")
+	ErrKeyExpired  = errors.New("jwt key expired")
+)
+
+// Key 签名/验证密钥
+// HMAC 密钥使用 Secret；RSA/ECDSA/Ed25519 密钥使用 PrivateKey 签名、PublicKey 验证，
+// 只用于验证的旧密钥可以不提供 PrivateKey
+type Key struct {
+	Kid        string
+	Method     jwt.SigningMethod // 签名算法，为空时使用 HS256
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	NotAfter   time.Time // 超过该时间后不再用于验证，零值表示不过期
+}
+
+// method 返回签名算法
+func (k Key) method() jwt.SigningMethod {
+	if k.Method == nil {
+		return jwt.SigningMethodHS256
+	}
+	return k.Method
+}
+
+// symmetric 是否为 HMAC 密钥
+func (k Key) symmetric() bool {
+	_, ok := k.method().(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// signingKey 返回签名用的密钥
+func (k Key) signingKey() (interface{}, error) {
+	if k.symmetric() {
+		return k.Secret, nil
+	}
+	if k.PrivateKey == nil {
+		return nil, fmt.Errorf("jwt key %q has no private key and cannot sign", k.Kid)
+	}
+	return k.PrivateKey, nil
+}
+
+// verifyKey 返回验证用的密钥
+func (k Key) verifyKey() interface{} {
+	if k.symmetric() {
+		return k.Secret
+	}
+	return k.PublicKey
+}
+
+// check 校验密钥材料与算法是否匹配
+func (k Key) check() error {
+	if k.Kid == "" {
+		return errors.New("jwt key kid is required")
+	}
+	if k.symmetric() {
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("jwt key %q secret is required", k.Kid)
+		}
+		return nil
+	}
+	if k.PublicKey == nil {
+		return fmt.Errorf("jwt key %q public key is required", k.Kid)
+	}
+	if err := checkKeyType(k.method(), k.PublicKey); err != nil {
+		return fmt.Errorf("jwt key %q: %w", k.Kid, err)
+	}
+	return nil
+}
+
+// expired 判断密钥在 now 时是否已停止验证
+func (k Key) expired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// Keyring 密钥环：一个当前签名密钥 + 若干仅用于验证的旧密钥
+// 所有方法并发安全，可在运行时添加/切换密钥而无需重启
+type Keyring struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]Key
+}
+
+// NewKeyring 创建空密钥环
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]Key)}
+}
+
+// NewKeyringWithConfig 根据配置创建密钥环
+func NewKeyringWithConfig(cfg *JwtConfig) (*Keyring, error) {
+	kr := NewKeyring()
+	if len(cfg.Keys) == 0 {
+		if err := kr.AddKey(Key{Kid: DefaultKid, Secret: []byte(cfg.Secret)}); err != nil {
+			return nil, err
+		}
+		return kr, kr.SetActive(DefaultKid)
+	}
+
+	for _, kc := range cfg.Keys {
+		key, err := kc.load()
+		if err != nil {
+			return nil, err
+		}
+		if err := kr.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return kr, kr.SetActive(cfg.ActiveKid)
+}
+
+// AddKey 添加或替换密钥，新密钥默认只用于验证，需 SetActive 后才用于签名
+func (kr *Keyring) AddKey(key Key) error {
+	if key.PublicKey == nil && key.PrivateKey != nil {
+		key.PublicKey = key.PrivateKey.Public()
+	}
+	if err := key.check(); err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if key.Kid == kr.active && key.expired(time.Now()) {
+		return fmt.Errorf("jwt key %q: %w", key.Kid, ErrKeyExpired)
+	}
+	kr.keys[key.Kid] = key
+	return nil
+}
+
+// SetActive 切换签名密钥，原签名密钥保留用于验证
+func (kr *Keyring) SetActive(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	key, ok := kr.keys[kid]
+	if !ok {
+		return fmt.Errorf("jwt key %q: %w", kid, ErrKeyNotFound)
+	}
+	if key.expired(time.Now()) {
+		return fmt.Errorf("jwt key %q: %w", kid, ErrKeyExpired)
+	}
+	if _, err := key.signingKey(); err != nil {
+		return err
+	}
+	kr.active = kid
+	return nil
+}
+
+// Retire 设置密钥的停止验证时间，不能作用于当前签名密钥
+func (kr *Keyring) Retire(kid string, notAfter time.Time) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	key, ok := kr.keys[kid]
+	if !ok {
+		return fmt.Errorf("jwt key %q: %w", kid, ErrKeyNotFound)
+	}
+	if kid == kr.active {
+		return fmt.Errorf("jwt key %q is active and cannot be retired", kid)
+	}
+	key.NotAfter = notAfter
+	kr.keys[kid] = key
+	return nil
+}
+
+// RemoveKey 删除密钥，不能删除当前签名密钥
+func (kr *Keyring) RemoveKey(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kid == kr.active {
+		return fmt.Errorf("jwt key %q is active and cannot be removed", kid)
+	}
+	delete(kr.keys, kid)
+	return nil
+}
+
+// Active 返回当前签名密钥
+func (kr *Keyring) Active() (Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[kr.active]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	if key.expired(time.Now()) {
+		return Key{}, fmt.Errorf("jwt key %q: %w", key.Kid, ErrKeyExpired)
+	}
+	return key, nil
+}
+
+// Lookup 根据 kid 查找可用于验证的密钥
+func (kr *Keyring) Lookup(kid string) (Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[kid]
+	if !ok {
+		return Key{}, fmt.Errorf("jwt key %q: %w", kid, ErrKeyNotFound)
+	}
+	if key.expired(time.Now()) {
+		return Key{}, fmt.Errorf("jwt key %q: %w", kid, ErrKeyExpired)
+	}
+	return key, nil
+}
+
+// Methods 返回密钥环中所有密钥使用的签名算法，用于限制解析时接受的算法
+func (kr *Keyring) Methods() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	seen := make(map[string]bool)
+	methods := make([]string, 0, 1)
+	for _, key := range kr.keys {
+		alg := key.method().Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// Kids 返回所有密钥ID
+func (kr *Keyring) Kids() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	kids := make([]string, 0, len(kr.keys))
+	for kid := range kr.keys {
+		kids = append(kids, kid)
+	}
+	return kids
+}
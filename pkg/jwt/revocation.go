@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked 令牌已被吊销
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore 访问令牌吊销存储（jti 黑名单）
+//
+// 记录在 expiresAt 之后失去意义（令牌本身已过期），由 Purge 清理
+type RevocationStore interface {
+	// Revoke 吊销单个令牌
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser 吊销用户在 before 之前签发的所有令牌
+	RevokeUser(ctx context.Context, userID string, before, expiresAt time.Time) error
+	// IsRevoked 检查令牌是否已被吊销
+	IsRevoked(ctx context.Context, jti, userID string, issuedAt time.Time) (bool, error)
+	// Purge 清理已过期的记录
+	Purge(ctx context.Context) error
+}
+
+// MemoryRevocationStore 内存实现，只适用于单实例部署
+type MemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time      // jti -> 过期时间
+	users  map[string]userRevocation // userID -> 吊销截止时间
+}
+
+type userRevocation struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+// NewMemoryRevocationStore 创建内存吊销存储
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]userRevocation),
+	}
+}
+
+// Revoke 吊销单个令牌
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+// RevokeUser 吊销用户在 before 之前签发的所有令牌，多次调用时保留最晚的截止时间
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID string, before, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.users[userID]; ok && old.before.After(before) {
+		return nil
+	}
+	s.users[userID] = userRevocation{before: before, expiresAt: expiresAt}
+	return nil
+}
+
+// IsRevoked 检查令牌是否已被吊销
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti, userID string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if r, ok := s.users[userID]; ok && IssuedBefore(issuedAt, r.before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Purge 清理已过期的记录
+func (s *MemoryRevocationStore) Purge(ctx context.Context) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, r := range s.users {
+		if r.expiresAt.Before(now) {
+			delete(s.users, userID)
+		}
+	}
+	return nil
+}
+
+// IssuedBefore 判断签发时间是否早于吊销截止时间
+// iat 只精确到秒，截止时间向上取整到整秒：与吊销同一秒内签发的令牌一并视为已吊销，
+// 宁可让吊销后一秒内重新登录拿到的令牌失效，也不能放过吊销前签发的令牌
+func IssuedBefore(issuedAt, before time.Time) bool {
+	cutoff := before.Truncate(time.Second)
+	if cutoff.Before(before) {
+		cutoff = cutoff.Add(time.Second)
+	}
+	return issuedAt.Before(cutoff)
+}
@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"fmt"
+)
+
+const (
+	DriverSMTP   = "smtp"   // 通过 SMTP 服务器发送
+	DriverFile   = "file"   // 写入本地目录，开发环境使用
+	DriverStdout = "stdout" // 输出到标准输出，开发环境使用
+)
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Driver  string     `yaml:"driver" mapstructure:"driver"`     // 发送驱动：smtp / file / stdout
+	From    string     `yaml:"from" mapstructure:"from"`         // 发件人，如 "King Starter <no-reply@example.com>"
+	SMTP    SMTPConfig `yaml:"smtp" mapstructure:"smtp"`         // SMTP 驱动配置
+	FileDir string     `yaml:"file_dir" mapstructure:"file_dir"` // file 驱动的输出目录
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string `yaml:"host" mapstructure:"host"`         // 服务器地址
+	Port     int    `yaml:"port" mapstructure:"port"`         // 服务器端口
+	Username string `yaml:"username" mapstructure:"username"` // 认证用户名，留空表示不认证
+	Password string `yaml:"password" mapstructure:"password"` // 认证密码
+	TLS      string `yaml:"tls" mapstructure:"tls"`           // 加密方式：starttls（默认，通常 587 端口）/ tls（通常 465 端口）/ none
+	Timeout  int    `yaml:"timeout" mapstructure:"timeout"`   // 连接超时（秒）
+}
+
+// Validate 配置校验
+func (c *MailConfig) Validate() error {
+	if c.From == "" {
+		return fmt.Errorf("[mail] MailConfig error: from is required")
+	}
+	switch c.Driver {
+	case DriverSMTP:
+		if c.SMTP.Host == "" {
+			return fmt.Errorf("[mail] MailConfig error: smtp.host is required")
+		}
+		if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+			return fmt.Errorf("[mail] MailConfig error: smtp.port %d is invalid", c.SMTP.Port)
+		}
+		switch c.SMTP.TLS {
+		case "", "starttls", "tls", "none":
+		default:
+			return fmt.Errorf("[mail] MailConfig error: smtp.tls %q is invalid", c.SMTP.TLS)
+		}
+	case DriverFile:
+		if c.FileDir == "" {
+			return fmt.Errorf("[mail] MailConfig error: file_dir is required")
+		}
+	case DriverStdout:
+	default:
+		return fmt.Errorf("[mail] MailConfig error: driver %q is invalid", c.Driver)
+	}
+	return nil
+}
+
+// DefaultMailConfig 默认配置，开发环境下邮件直接输出到标准输出
+func DefaultMailConfig() MailConfig {
+	return MailConfig{
+		Driver:  DriverStdout,
+		From:    "King Starter <no-reply@localhost>",
+		FileDir: "./data/mail",
+		SMTP: SMTPConfig{
+			Port:    587,
+			TLS:     "starttls",
+			Timeout: 10,
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer 将邮件写入本地目录（每封一个 .eml 文件），开发环境使用
+type FileMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+// NewFileMailer 创建文件邮件发送器，目录不存在时自动创建
+func NewFileMailer(dir string, from *mail.Address) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("[mail] create dir %s: %w", dir, err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send 写入邮件文件
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405") + "-" + uuid.NewString() + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
+
+// StdoutMailer 将邮件输出到标准输出，开发环境使用
+type StdoutMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from *mail.Address
+}
+
+// NewStdoutMailer 创建标准输出邮件发送器
+func NewStdoutMailer(from *mail.Address) *StdoutMailer {
+	return &StdoutMailer{w: os.Stdout, from: from}
+}
+
+// Send 输出邮件原文
+func (m *StdoutMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = fmt.Fprintf(m.w, "======== mail ========\n%s\n======================\n", data)
+	return err
+}
@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message 邮件内容
+type Message struct {
+	To      []string // 收件人
+	Subject string   // 主题
+	Text    string   // 纯文本正文
+	HTML    string   // HTML 正文（可选），同时提供时以 multipart/alternative 发送
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New 根据配置创建邮件发送器
+func New(cfg *MailConfig) (Mailer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("[mail] MailConfig error: from is invalid: %w", err)
+	}
+	switch cfg.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg.SMTP, from), nil
+	case DriverFile:
+		return NewFileMailer(cfg.FileDir, from)
+	default:
+		return NewStdoutMailer(from), nil
+	}
+}
+
+// build 生成 RFC 5322 格式的邮件原文
+func build(from *mail.Address, msg *Message) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("mail: no recipients")
+	}
+	for _, to := range msg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("mail: invalid recipient %q: %w", to, err)
+		}
+	}
+
+	var b strings.Builder
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", from.String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domain(from.Address)))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "8bit")
+		b.WriteString("\r\n" + msg.Text)
+		return []byte(b.String()), nil
+	}
+
+	boundary := strings.ReplaceAll(uuid.NewString(), "-", "")
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	b.WriteString("\r\n")
+	for _, part := range []struct{ typ, body string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		b.WriteString("--" + boundary + "\r\n")
+		header("Content-Type", part.typ+`; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "8bit")
+		b.WriteString("\r\n" + part.body + "\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return []byte(b.String()), nil
+}
+
+func domain(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() *Message {
+	return &Message{
+		To:      []string{"alice@example.com"},
+		Subject: "登录验证码",
+		Text:    "您的验证码为 123456",
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	cfg := DefaultMailConfig()
+	m, err := New(&cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &StdoutMailer{}, m)
+
+	cfg.Driver = "pigeon"
+	_, err = New(&cfg)
+	assert.Error(t, err)
+
+	cfg = DefaultMailConfig()
+	cfg.Driver = DriverSMTP
+	_, err = New(&cfg)
+	assert.Error(t, err, "smtp.host is required")
+
+	cfg = DefaultMailConfig()
+	cfg.From = "not an address"
+	_, err = New(&cfg)
+	assert.Error(t, err)
+}
+
+func TestBuildMessage(t *testing.T) {
+	from, _ := mail.ParseAddress("King <no-reply@example.com>")
+	data, err := build(from, testMessage())
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "登录验证码", subject)
+	assert.Equal(t, "alice@example.com", parsed.Header.Get("To"))
+	assert.Contains(t, parsed.Header.Get("Message-ID"), "@example.com>")
+
+	_, err = build(from, &Message{Subject: "x"})
+	assert.Error(t, err, "no recipients")
+	_, err = build(from, &Message{To: []string{"bad"}})
+	assert.Error(t, err)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	cfg := DefaultMailConfig()
+	cfg.Driver = DriverFile
+	cfg.FileDir = dir
+	m, err := New(&cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), testMessage()))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "您的验证码为 123456")
+}
+
+func TestStdoutMailer(t *testing.T) {
+	var b strings.Builder
+	from, _ := mail.ParseAddress("no-reply@example.com")
+	m := NewStdoutMailer(from)
+	m.w = &b
+
+	require.NoError(t, m.Send(context.Background(), testMessage()))
+	assert.Contains(t, b.String(), "To: alice@example.com")
+}
+
+func TestSMTPMailer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(ln, received)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	cfg := DefaultMailConfig()
+	cfg.Driver = DriverSMTP
+	cfg.SMTP.Host = "127.0.0.1"
+	cfg.SMTP.Port = addr.Port
+	cfg.SMTP.TLS = "none"
+	m, err := New(&cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), testMessage()))
+
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<no-reply@localhost>")
+	assert.Contains(t, lines, "RCPT TO:<alice@example.com>")
+	assert.Contains(t, lines, "您的验证码为 123456")
+}
+
+// serveSMTP 极简 SMTP 服务端，只处理一次会话，记录收到的所有行
+func serveSMTP(ln net.Listener, received chan<- []string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+	var lines []string
+	inData := false
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if inData {
+			if line == "." {
+				inData = false
+				reply("250 OK")
+			}
+			continue
+		}
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "DATA":
+			inData = true
+			reply("354 go ahead")
+		case "QUIT":
+			reply("221 bye")
+			received <- lines
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+	received <- lines
+}
@@ -0,0 +1,52 @@
+package sms
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	DriverLog  = "log"  // 只写日志不发送，开发环境使用
+	DriverHTTP = "http" // 通过 HTTP 接口调用短信服务商或自建短信网关
+)
+
+// SMSConfig 短信发送配置
+type SMSConfig struct {
+	Driver   string     `yaml:"driver" mapstructure:"driver"`       // 发送驱动：log / http
+	SignName string     `yaml:"sign_name" mapstructure:"sign_name"` // 短信签名
+	HTTP     HTTPConfig `yaml:"http" mapstructure:"http"`           // http 驱动配置
+}
+
+// HTTPConfig HTTP 短信网关配置
+type HTTPConfig struct {
+	URL     string            `yaml:"url" mapstructure:"url"`         // 发送接口地址
+	APIKey  string            `yaml:"api_key" mapstructure:"api_key"` // 以 Bearer 令牌放在 Authorization 头中，留空表示不认证
+	Headers map[string]string `yaml:"headers" mapstructure:"headers"` // 附加请求头
+	Timeout int               `yaml:"timeout" mapstructure:"timeout"` // 请求超时（秒）
+}
+
+// Validate 配置校验
+func (c *SMSConfig) Validate() error {
+	switch c.Driver {
+	case DriverHTTP:
+		u, err := url.Parse(c.HTTP.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("[sms] SMSConfig error: http.url %q is invalid", c.HTTP.URL)
+		}
+	case DriverLog:
+	default:
+		return fmt.Errorf("[sms] SMSConfig error: driver %q is invalid", c.Driver)
+	}
+	return nil
+}
+
+// DefaultSMSConfig 默认配置，开发环境下短信只写日志
+func DefaultSMSConfig() SMSConfig {
+	return SMSConfig{
+		Driver:   DriverLog,
+		SignName: "King Starter",
+		HTTP: HTTPConfig{
+			Timeout: 10,
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPSender 通过 HTTP 接口发送短信
+//
+// 以 JSON 格式 POST 到配置的地址，请求体为
+//
+//	{"phone": "...", "sign_name": "...", "template": "...", "params": {...}, "content": "..."}
+//
+// 返回 2xx 视为发送成功。各短信服务商的接口差异较大，通常由自建网关完成协议转换
+type HTTPSender struct {
+	cfg      HTTPConfig
+	signName string
+	client   *http.Client
+}
+
+// NewHTTPSender 创建 HTTP 短信发送器
+func NewHTTPSender(cfg HTTPConfig, signName string) *HTTPSender {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &HTTPSender{
+		cfg:      cfg,
+		signName: signName,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type httpPayload struct {
+	Phone    string            `json:"phone"`
+	SignName string            `json:"sign_name,omitempty"`
+	Template string            `json:"template,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Content  string            `json:"content"`
+}
+
+// Send 调用短信网关发送短信
+func (s *HTTPSender) Send(ctx context.Context, msg *Message) error {
+	if msg.Phone == "" {
+		return fmt.Errorf("sms: no recipient")
+	}
+	body, err := json.Marshal(httpPayload{
+		Phone:    msg.Phone,
+		SignName: s.signName,
+		Template: msg.Template,
+		Params:   msg.Params,
+		Content:  msg.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// 只读取部分响应体用于排查问题
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms: provider returned %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,25 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+
+	"king-starter/pkg/logx"
+)
+
+// LogSender 只把短信写入日志，不实际发送，开发环境使用
+type LogSender struct{}
+
+// NewLogSender 创建日志短信发送器
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send 记录短信内容
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	if msg.Phone == "" {
+		return fmt.Errorf("sms: no recipient")
+	}
+	logx.Info("sms sent by log driver", "phone", msg.Phone, "template", msg.Template, "content", msg.Content)
+	return nil
+}
@@ -0,0 +1,33 @@
+package sms
+
+import (
+	"context"
+)
+
+// Message 短信内容
+// 国内短信服务商通常要求使用预先审核的模板，Template 和 Params 用于模板短信，
+// Content 为渲染后的完整文本，供不支持模板的网关和日志驱动使用
+type Message struct {
+	Phone    string            // 接收手机号
+	Template string            // 模板编号（可选）
+	Params   map[string]string // 模板参数
+	Content  string            // 短信正文
+}
+
+// SMSSender 短信发送接口
+type SMSSender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New 根据配置创建短信发送器
+func New(cfg *SMSConfig) (SMSSender, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Driver {
+	case DriverHTTP:
+		return NewHTTPSender(cfg.HTTP, cfg.SignName), nil
+	default:
+		return NewLogSender(), nil
+	}
+}
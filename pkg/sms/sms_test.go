@@ -0,0 +1,81 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() *Message {
+	return &Message{
+		Phone:    "13800000000",
+		Template: "SMS_0001",
+		Params:   map[string]string{"code": "123456"},
+		Content:  "您的验证码为 123456",
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	cfg := DefaultSMSConfig()
+	s, err := New(&cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &LogSender{}, s)
+
+	cfg.Driver = "pigeon"
+	_, err = New(&cfg)
+	assert.Error(t, err)
+
+	cfg = DefaultSMSConfig()
+	cfg.Driver = DriverHTTP
+	_, err = New(&cfg)
+	assert.Error(t, err, "http.url is required")
+
+	cfg.HTTP.URL = "https://sms.example.com/send"
+	s, err = New(&cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &HTTPSender{}, s)
+}
+
+func TestHTTPSender(t *testing.T) {
+	var got httpPayload
+	var auth, custom string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		custom = r.Header.Get("X-App")
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSender(HTTPConfig{URL: srv.URL, APIKey: "secret", Headers: map[string]string{"X-App": "king"}}, "King")
+	require.NoError(t, s.Send(context.Background(), testMessage()))
+
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, "king", custom)
+	assert.Equal(t, "13800000000", got.Phone)
+	assert.Equal(t, "King", got.SignName)
+	assert.Equal(t, "SMS_0001", got.Template)
+	assert.Equal(t, "123456", got.Params["code"])
+	assert.Equal(t, "您的验证码为 123456", got.Content)
+}
+
+func TestHTTPSenderProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	err := NewHTTPSender(HTTPConfig{URL: srv.URL}, "").Send(context.Background(), testMessage())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "429")
+	assert.Contains(t, err.Error(), "quota exceeded")
+
+	assert.Error(t, NewHTTPSender(HTTPConfig{URL: srv.URL}, "").Send(context.Background(), &Message{}))
+}